@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/pkg/validate"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+var checker = validate.NewValidateRepo()
+
+// BindError lists every invalid field found by Bind.
+type BindError struct {
+	Key string
+
+	Errs []error
+}
+
+func (be *BindError) Error() string {
+	msgs := make([]string, 0, len(be.Errs))
+	for _, err := range be.Errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	key := be.Key
+	if key == "" {
+		key = "root"
+	}
+
+	return fmt.Sprintf("config [%s] has %d invalid fields: %s",
+		key, len(be.Errs), strings.Join(msgs, "; "))
+}
+
+// Bind reads the default config into out, see BindFrom.
+func Bind(key string, out interface{}) error {
+	return BindFrom(Default(), key, out)
+}
+
+// BindFrom fills the struct pointed by out from conf.
+// Each field is read from the key named by its mapstructure tag,
+// or the lower-case field name, under the section key (key may be empty).
+// The value of default tag is used when the key is missing or zero,
+// and the validate tag is checked at last.
+//
+//	type RedisConfig struct {
+//		Host      string `mapstructure:"redis_host" default:"0.0.0.0"`
+//		MaxActive int    `mapstructure:"redis_max_active" default:"500" validate:"gte=1"`
+//	}
+func BindFrom(conf Config, key string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: bind out must be a non-nil pointer to struct")
+	}
+
+	bindErr := &BindError{Key: key}
+	bindStruct(conf, key, rv.Elem(), bindErr)
+
+	bindErr.Errs = append(bindErr.Errs, checker.ValidateStructAll(out)...)
+
+	if len(bindErr.Errs) > 0 {
+		return bindErr
+	}
+
+	return nil
+}
+
+func bindStruct(conf Config, prefix string, rv reflect.Value, bindErr *BindError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := strings.SplitN(field.Tag.Get("mapstructure"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		if isStruct(field.Type) {
+			sub := prefix
+			if !field.Anonymous || name != "" {
+				sub = joinKey(prefix, fieldName(field, name))
+			}
+
+			if field.Type.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(field.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			bindStruct(conf, sub, fv, bindErr)
+			continue
+		}
+
+		fullKey := joinKey(prefix, fieldName(field, name))
+		if raw := conf.Get(fullKey); raw != nil {
+			if err := decodeValue(raw, fv); err != nil {
+				bindErr.Errs = append(bindErr.Errs,
+					fmt.Errorf("%s: can not use %v as %s", fullKey, raw, fv.Type()))
+				continue
+			}
+		}
+
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+
+		if err := decodeValue(def, fv); err != nil {
+			bindErr.Errs = append(bindErr.Errs,
+				fmt.Errorf("%s: can not use default %q as %s", fullKey, def, fv.Type()))
+		}
+	}
+}
+
+// decodeValue converts raw weakly, e.g. "300" => 300, "1s" => time.Second.
+func decodeValue(raw interface{}, fv reflect.Value) error {
+	target := reflect.New(fv.Type())
+	if err := decode(raw, target.Interface()); err != nil {
+		return err
+	}
+
+	fv.Set(target.Elem())
+	return nil
+}
+
+// decode works as viper's UnmarshalKey.
+func decode(input, output interface{}, opts ...viper.DecoderConfigOption) error {
+	dc := &mapstructure.DecoderConfig{
+		Metadata:         nil,
+		Result:           output,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	}
+	for _, opt := range opts {
+		opt(dc)
+	}
+
+	decoder, err := mapstructure.NewDecoder(dc)
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+func fieldName(field reflect.StructField, name string) string {
+	if name != "" {
+		return name
+	}
+
+	return strings.ToLower(field.Name)
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bindRedis struct {
+	Host      string        `mapstructure:"redis_host" default:"0.0.0.0"`
+	Port      int           `mapstructure:"redis_port" default:"6379" validate:"gte=1,lte=65535"`
+	MaxActive int           `mapstructure:"redis_max_active" default:"500"`
+	Timeout   time.Duration `mapstructure:"redis_timeout" default:"300ms"`
+	Tracer    bool          `mapstructure:"redis_tracer"`
+}
+
+type bindServer struct {
+	Addr  string   `mapstructure:"addr" validate:"required"`
+	Hosts []string `mapstructure:"hosts" default:"a,b"`
+	Level string   `mapstructure:"level" validate:"oneof=debug info"`
+}
+
+func TestBindFrom(t *testing.T) {
+	memConfig := NewMemConfig()
+	memConfig.Set("redis_host", "10.0.0.1")
+	memConfig.Set("redis_max_active", "50")
+	memConfig.Set("redis_tracer", true)
+
+	rc := bindRedis{}
+	assert.Nil(t, BindFrom(memConfig, "", &rc))
+	assert.Equal(t, "10.0.0.1", rc.Host)
+	assert.Equal(t, 6379, rc.Port)
+	assert.Equal(t, 50, rc.MaxActive)
+	assert.Equal(t, 300*time.Millisecond, rc.Timeout)
+	assert.True(t, rc.Tracer)
+}
+
+func TestBindFrom_Section(t *testing.T) {
+	memConfig := NewMemConfig()
+	memConfig.Set("server.addr", ":8080")
+
+	sc := bindServer{}
+	err := BindFrom(memConfig, "server", &sc)
+	assert.NotNil(t, err)
+	assert.Equal(t, ":8080", sc.Addr)
+	assert.Equal(t, []string{"a", "b"}, sc.Hosts)
+
+	bindErr, ok := err.(*BindError)
+	assert.True(t, ok)
+	assert.Len(t, bindErr.Errs, 1)
+}
+
+func TestBindFrom_Invalid(t *testing.T) {
+	memConfig := NewMemConfig()
+	memConfig.Set("redis_port", 70000)
+	memConfig.Set("redis_timeout", "slow")
+
+	err := BindFrom(memConfig, "", &bindRedis{})
+	assert.NotNil(t, err)
+	assert.Len(t, err.(*BindError).Errs, 2)
+	t.Log(err)
+
+	err = BindFrom(memConfig, "", bindRedis{})
+	assert.NotNil(t, err)
+}
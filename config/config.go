@@ -1,35 +1,62 @@
 package config
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
-	"strings"
 	"sync"
 	"time"
 
 	filedir "github.com/Hyingerrr/mirco-esim/pkg/file-dir"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
 type viperConf struct {
-	once sync.Once
+	notifier
+
+	mu sync.RWMutex
 
 	*viper.Viper
 
 	configType string
 
 	configFile []string
+
+	// sources in ascending precedence, the config files go first.
+	sources []Source
+
+	// settings loaded from each source, same order as sources.
+	layers []map[string]interface{}
+
+	// values written by Set, re-applied after every reload.
+	overrides map[string]interface{}
+
+	reloadMu sync.Mutex
+
+	watch bool
+
+	// prefix of the environment variables overriding the keys, empty disables.
+	envPrefix string
+
+	cancel context.CancelFunc
 }
 
 type ViperConfOptions struct{}
 
 type Option func(c *viperConf)
 
+// NewViperConfig merges the config files and the sources into one Config,
+// the later one overrides the earlier one:
+// conf.yaml < conf.<runmode>.yaml < monitoring.yaml < monitoring.<runmode>.yaml
+// < WithSources < ESIM_* environment variables.
 func NewViperConfig(options ...Option) Config {
-	c := &viperConf{}
+	c := &viperConf{
+		overrides: make(map[string]interface{}),
+		watch:     true,
+		envPrefix: defaultEnvPrefix,
+	}
 
 	for _, option := range options {
 		option(c)
@@ -46,44 +73,87 @@ func NewViperConfig(options ...Option) Config {
 			fmt.Sprintf("%s/monitoring.yaml", dir))
 	}
 
-	v := viper.New()
-	v.SetConfigType(c.configType)
+	fileSources := make([]Source, 0, len(c.configFile)*2+len(c.sources)+1)
+	runMode := c.runMode()
+	for _, configFile := range c.configFile {
+		fileSources = append(fileSources, NewFileSource(configFile, c.configType))
 
-	for k, configFile := range c.configFile {
-		if k == 0 {
-			content, err := ioutil.ReadFile(configFile)
-			if err != nil {
-				log.Panicf("Fatal error config file: %s \n", err.Error())
-			}
-
-			err = v.ReadConfig(strings.NewReader(os.ExpandEnv(string(content))))
-			if err != nil { // Handle errors reading the config file
-				log.Panicf("Fatal error config file: %s \n", err.Error())
-			}
+		if profile := profileFile(configFile, runMode); profile != "" {
+			fileSources = append(fileSources, NewFileSource(profile, c.configType))
 		}
+	}
+	c.sources = append(fileSources, c.sources...)
 
-		if k > 0 {
-			content, err := ioutil.ReadFile(configFile)
-			if err != nil {
-				log.Panicf("Fatal error config file: %s \n", err.Error())
-			}
+	if c.envPrefix != "" {
+		c.sources = append(c.sources, NewEnvSource(c.envPrefix))
+	}
 
-			err = v.MergeConfig(strings.NewReader(os.ExpandEnv(string(content))))
-			if err != nil { // Handle errors reading the config file
-				log.Panicf("Fatal error config file: %s \n", err.Error())
-			}
+	c.layers = make([]map[string]interface{}, len(c.sources))
+	for k, source := range c.sources {
+		settings, err := source.Load()
+		if err != nil {
+			log.Panicf("Fatal error config file: %s \n", err.Error())
 		}
+		c.layers[k] = settings
+	}
+
+	v, err := c.merge()
+	if err != nil {
+		log.Panicf("Fatal error config file: %s \n", err.Error())
 	}
 	c.Viper = v
-	return setInstance(c)
+
+	// the broken secrets fail at boot rather than at read.
+	if err = checkSecrets(v.AllSettings()); err != nil {
+		log.Panicf("Fatal error config secret: %s \n", err.Error())
+	}
+
+	if c.watch {
+		c.watchSources()
+	}
+
+	setDefaultIfNil(c)
+
+	return c
+}
+
+// merge builds a new viper from the loaded layers.
+func (vc *viperConf) merge() (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigType(vc.configType)
+
+	for k, settings := range vc.layers {
+		// viper shares the nested maps when merging, copy to keep the layers intact.
+		if err := v.MergeConfigMap(copySettings(settings)); err != nil {
+			return nil, fmt.Errorf("%s: %s", vc.sources[k], err.Error())
+		}
+	}
+
+	for key, val := range vc.overrides {
+		v.Set(key, val)
+	}
+
+	return v, nil
 }
 
-func setInstance(c *viperConf) Config {
-	if _conf != nil {
-		return _conf
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(settings))
+	for key, val := range settings {
+		switch child := val.(type) {
+		case map[string]interface{}:
+			dst[key] = copySettings(child)
+		case map[interface{}]interface{}:
+			m := make(map[string]interface{}, len(child))
+			for k, v := range child {
+				m[fmt.Sprint(k)] = v
+			}
+			dst[key] = copySettings(m)
+		default:
+			dst[key] = val
+		}
 	}
-	_conf = c
-	return _conf
+
+	return dst
 }
 
 func (ViperConfOptions) WithConfigType(configType string) Option {
@@ -98,55 +168,96 @@ func (ViperConfOptions) WithConfFile(configFile []string) Option {
 	}
 }
 
-func (vc *viperConf) Get(key string) interface{} { return vc.Viper.Get(key) }
+// WithSources layers the sources on top of the config files,
+// the later source has the higher precedence.
+func (ViperConfOptions) WithSources(sources ...Source) Option {
+	return func(v *viperConf) {
+		v.sources = append(v.sources, sources...)
+	}
+}
 
-func (vc *viperConf) GetString(key string) string { return vc.Viper.GetString(key) }
+// WithEnvPrefix changes the prefix of the overriding environment variables,
+// default ESIM, an empty prefix disables the overriding.
+func (ViperConfOptions) WithEnvPrefix(prefix string) Option {
+	return func(v *viperConf) {
+		v.envPrefix = prefix
+	}
+}
 
-func (vc *viperConf) GetBool(key string) bool { return vc.Viper.GetBool(key) }
+// WithWatch enables or disables hot reload of the sources, default true.
+func (ViperConfOptions) WithWatch(watch bool) Option {
+	return func(v *viperConf) {
+		v.watch = watch
+	}
+}
+
+func (vc *viperConf) getViper() *viper.Viper {
+	vc.mu.RLock()
+	defer vc.mu.RUnlock()
+	return vc.Viper
+}
 
-func (vc *viperConf) GetInt(key string) int { return vc.Viper.GetInt(key) }
+// Get decrypts the ENC(...) values, the typed getters all go through it.
+func (vc *viperConf) Get(key string) interface{} { return decryptValue(vc.getViper().Get(key)) }
 
-func (vc *viperConf) GetInt32(key string) int32 { return vc.Viper.GetInt32(key) }
+func (vc *viperConf) GetString(key string) string { return cast.ToString(vc.Get(key)) }
 
-func (vc *viperConf) GetInt64(key string) int64 { return vc.Viper.GetInt64(key) }
+func (vc *viperConf) GetBool(key string) bool { return cast.ToBool(vc.Get(key)) }
 
-func (vc *viperConf) GetUint(key string) uint { return vc.Viper.GetUint(key) }
+func (vc *viperConf) GetInt(key string) int { return cast.ToInt(vc.Get(key)) }
 
-func (vc *viperConf) GetUint32(key string) uint32 { return vc.Viper.GetUint32(key) }
+func (vc *viperConf) GetInt32(key string) int32 { return cast.ToInt32(vc.Get(key)) }
 
-func (vc *viperConf) GetUint64(key string) uint64 { return vc.Viper.GetUint64(key) }
+func (vc *viperConf) GetInt64(key string) int64 { return cast.ToInt64(vc.Get(key)) }
 
-func (vc *viperConf) GetFloat64(key string) float64 { return vc.Viper.GetFloat64(key) }
+func (vc *viperConf) GetUint(key string) uint { return cast.ToUint(vc.Get(key)) }
 
-func (vc *viperConf) GetTime(key string) time.Time { return vc.Viper.GetTime(key) }
+func (vc *viperConf) GetUint32(key string) uint32 { return cast.ToUint32(vc.Get(key)) }
 
-func (vc *viperConf) GetDuration(key string) time.Duration { return vc.Viper.GetDuration(key) }
+func (vc *viperConf) GetUint64(key string) uint64 { return cast.ToUint64(vc.Get(key)) }
+
+func (vc *viperConf) GetFloat64(key string) float64 { return cast.ToFloat64(vc.Get(key)) }
+
+func (vc *viperConf) GetTime(key string) time.Time { return cast.ToTime(vc.Get(key)) }
+
+func (vc *viperConf) GetDuration(key string) time.Duration { return cast.ToDuration(vc.Get(key)) }
 
 // func GetIntSlice(key string) []int { return config.GetIntSlice(key) }
 
-func (vc *viperConf) GetStringSlice(key string) []string { return vc.Viper.GetStringSlice(key) }
+func (vc *viperConf) GetStringSlice(key string) []string {
+	return cast.ToStringSlice(vc.Get(key))
+}
 
 func (vc *viperConf) GetStringMap(key string) map[string]interface{} {
-	return vc.Viper.GetStringMap(key)
+	return cast.ToStringMap(vc.Get(key))
 }
 
 func (vc *viperConf) GetStringMapString(key string) map[string]string {
-	return vc.Viper.GetStringMapString(key)
+	return cast.ToStringMapString(vc.Get(key))
 }
 
 func (vc *viperConf) GetStringMapStringSlice(key string) map[string][]string {
-	return vc.Viper.GetStringMapStringSlice(key)
+	return cast.ToStringMapStringSlice(vc.Get(key))
 }
 
-func (vc *viperConf) GetSizeInBytes(key string) uint { return vc.Viper.GetSizeInBytes(key) }
+func (vc *viperConf) GetSizeInBytes(key string) uint { return vc.getViper().GetSizeInBytes(key) }
 
 func (vc *viperConf) UnmarshalKey(key string, rawVal interface{},
 	opts ...viper.DecoderConfigOption) error {
-	return vc.Viper.UnmarshalKey(key, rawVal, opts...)
+	return decode(vc.Get(key), rawVal, opts...)
 }
 
 func (vc *viperConf) Unmarshal(rawVal interface{}, opts ...viper.DecoderConfigOption) error {
-	return vc.Viper.Unmarshal(rawVal, opts...)
+	return decode(decryptValue(vc.getViper().AllSettings()), rawVal, opts...)
 }
 
-func (vc *viperConf) Set(key string, value interface{}) { vc.Viper.Set(key, value) }
+func (vc *viperConf) Set(key string, value interface{}) {
+	vc.mu.Lock()
+	old := flatSettings(vc.Viper)
+	vc.overrides[key] = value
+	vc.Viper.Set(key, value)
+	cur := flatSettings(vc.Viper)
+	vc.mu.Unlock()
+
+	vc.notify(old, cur)
+}
@@ -33,3 +33,25 @@ func TestNotConfigFile(t *testing.T) {
 
 	NewViperConfig(options.WithConfigType("yaml"))
 }
+
+func TestNewViperConfig_Independent(t *testing.T) {
+	options := ViperConfOptions{}
+
+	confA := NewViperConfig(options.WithConfFile([]string{"./a.yaml"}),
+		options.WithWatch(false))
+	confB := NewViperConfig(options.WithConfFile([]string{"./a.yaml", "b.yaml"}),
+		options.WithWatch(false))
+
+	if confA == confB {
+		t.Errorf("error should be independent instances")
+	}
+
+	if confA.GetString("grpc_client_timeout") != "" {
+		t.Errorf("error should empty , now %s", confA.GetString("grpc_client_timeout"))
+	}
+
+	t.Cleanup(ReplaceDefault(confB))
+	if Default() != confB {
+		t.Errorf("error default should be confB")
+	}
+}
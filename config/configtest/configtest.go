@@ -0,0 +1,20 @@
+// Package configtest helps the tests which depend on the default config.
+package configtest
+
+import (
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+)
+
+// SetDefault sets conf as the default config until the test ends.
+//
+//	func TestXxx(t *testing.T) {
+//		conf := config.NewMemConfig()
+//		conf.Set("appname", "test")
+//		configtest.SetDefault(t, conf)
+//	}
+func SetDefault(tb testing.TB, conf config.Config) {
+	tb.Helper()
+	tb.Cleanup(config.ReplaceDefault(conf))
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	layerSet = "set"
+
+	layerMemory = "memory"
+)
+
+// DumpItem is the effective value of a key and the layer it came from.
+// The ENC(...) values are dumped as they are.
+type DumpItem struct {
+	Key string `json:"key"`
+
+	Value interface{} `json:"value"`
+
+	Layer string `json:"layer"`
+}
+
+// Dumper is implemented by the configs which can show the effective values.
+type Dumper interface {
+	Dump() []DumpItem
+}
+
+// Dump shows the effective values of the default config, sorted by key.
+func Dump() []DumpItem {
+	if d, ok := Default().(Dumper); ok {
+		return d.Dump()
+	}
+
+	return nil
+}
+
+// DumpString formats the items as a table.
+func DumpString(items []DumpItem) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tLAYER")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", item.Key, item.Value, item.Layer)
+	}
+	w.Flush()
+
+	return sb.String()
+}
+
+func (vc *viperConf) Dump() []DumpItem {
+	vc.mu.RLock()
+	defer vc.mu.RUnlock()
+
+	items := make(map[string]DumpItem)
+	for k, settings := range vc.layers {
+		layer := vc.sources[k].String()
+		for key, val := range flattenSettings("", settings) {
+			items[key] = DumpItem{Key: key, Value: val, Layer: layer}
+		}
+	}
+
+	for key, val := range vc.overrides {
+		key = strings.ToLower(key)
+		items[key] = DumpItem{Key: key, Value: val, Layer: layerSet}
+	}
+
+	return sortItems(items)
+}
+
+func (mc *MemConfig) Dump() []DumpItem {
+	items := make(map[string]DumpItem, len(mc.data))
+	for key, val := range mc.data {
+		items[key] = DumpItem{Key: key, Value: val, Layer: layerMemory}
+	}
+
+	return sortItems(items)
+}
+
+func sortItems(items map[string]DumpItem) []DumpItem {
+	sorted := make([]DumpItem, 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, item)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+// flattenSettings joins the nested keys by ".", as viper does.
+func flattenSettings(prefix string, settings map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	for key, val := range copySettings(settings) {
+		key = strings.ToLower(key)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if child, ok := val.(map[string]interface{}); ok && len(child) > 0 {
+			for k, v := range flattenSettings(key, child) {
+				flat[k] = v
+			}
+			continue
+		}
+
+		flat[key] = val
+	}
+
+	return flat
+}
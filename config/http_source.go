@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type httpSource struct {
+	url string
+
+	client *http.Client
+
+	header http.Header
+
+	interval time.Duration
+}
+
+type HTTPSourceOption func(*httpSource)
+
+// NewHTTPSource loads a JSON object from url,
+// and polls it every 30 seconds by default.
+func NewHTTPSource(url string, options ...HTTPSourceOption) Source {
+	hs := &httpSource{
+		url:      url,
+		header:   make(http.Header),
+		interval: 30 * time.Second,
+	}
+
+	for _, option := range options {
+		option(hs)
+	}
+
+	if hs.client == nil {
+		hs.client = &http.Client{Timeout: 3 * time.Second}
+	}
+
+	return hs
+}
+
+func WithHTTPSourceClient(client *http.Client) HTTPSourceOption {
+	return func(hs *httpSource) {
+		hs.client = client
+	}
+}
+
+func WithHTTPSourceHeader(key, value string) HTTPSourceOption {
+	return func(hs *httpSource) {
+		hs.header.Add(key, value)
+	}
+}
+
+func WithHTTPSourceInterval(interval time.Duration) HTTPSourceOption {
+	return func(hs *httpSource) {
+		hs.interval = interval
+	}
+}
+
+func (hs *httpSource) fetch() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, hs.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = hs.header.Clone()
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %d", hs.url, resp.StatusCode)
+	}
+
+	return body, nil
+}
+
+func (hs *httpSource) Load() (map[string]interface{}, error) {
+	body, err := hs.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	if err = json.Unmarshal(body, &settings); err != nil {
+		return nil, fmt.Errorf("%s: %s", hs.url, err.Error())
+	}
+
+	return settings, nil
+}
+
+func (hs *httpSource) Watch(ctx context.Context, onChange func()) error {
+	return poll(ctx, hs.interval, hs.String(), func() (string, error) {
+		body, err := hs.fetch()
+		if err != nil {
+			return "", err
+		}
+
+		sum := md5.Sum(body)
+		return hex.EncodeToString(sum[:]), nil
+	}, onChange)
+}
+
+func (hs *httpSource) String() string {
+	return "http:" + hs.url
+}
@@ -1,12 +1,17 @@
 package config
 
 import (
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
-var _conf Config
+var (
+	_conf Config
+
+	defaultMu sync.RWMutex
+)
 
 type Config interface {
 	Get(key string) interface{}
@@ -53,81 +58,117 @@ type Config interface {
 }
 
 func Get(key string) interface{} {
-	return _conf.Get(key)
+	return Default().Get(key)
 }
 
 func GetString(key string) string {
-	return _conf.GetString(key)
+	return Default().GetString(key)
 }
 
 func GetBool(key string) bool {
-	return _conf.GetBool(key)
+	return Default().GetBool(key)
 }
 
 func GetInt(key string) int {
-	return _conf.GetInt(key)
+	return Default().GetInt(key)
 }
 
 func GetInt32(key string) int32 {
-	return _conf.GetInt32(key)
+	return Default().GetInt32(key)
 }
 
 func GetInt64(key string) int64 {
-	return _conf.GetInt64(key)
+	return Default().GetInt64(key)
 }
 
 func GetUint(key string) uint {
-	return _conf.GetUint(key)
+	return Default().GetUint(key)
 }
 
 func GetUint32(key string) uint32 {
-	return _conf.GetUint32(key)
+	return Default().GetUint32(key)
 }
 
 func GetUint64(key string) uint64 {
-	return _conf.GetUint64(key)
+	return Default().GetUint64(key)
 }
 
 func GetFloat64(key string) float64 {
-	return _conf.GetFloat64(key)
+	return Default().GetFloat64(key)
 }
 
 func GetTime(key string) time.Time {
-	return _conf.GetTime(key)
+	return Default().GetTime(key)
 }
 
 func GetDuration(key string) time.Duration {
-	return _conf.GetDuration(key)
+	return Default().GetDuration(key)
 }
 
 func GetStringSlice(key string) []string {
-	return _conf.GetStringSlice(key)
+	return Default().GetStringSlice(key)
 }
 
 func GetStringMap(key string) map[string]interface{} {
-	return _conf.GetStringMap(key)
+	return Default().GetStringMap(key)
 }
 
 func GetStringMapString(key string) map[string]string {
-	return _conf.GetStringMapString(key)
+	return Default().GetStringMapString(key)
 }
 
 func GetStringMapStringSlice(key string) map[string][]string {
-	return _conf.GetStringMapStringSlice(key)
+	return Default().GetStringMapStringSlice(key)
 }
 
 func GetSizeInBytes(key string) uint {
-	return _conf.GetSizeInBytes(key)
+	return Default().GetSizeInBytes(key)
 }
 
 func UnmarshalKey(key string, rawVal interface{}, opts ...viper.DecoderConfigOption) error {
-	return _conf.UnmarshalKey(key, rawVal, opts...)
+	return Default().UnmarshalKey(key, rawVal, opts...)
 }
 
 func Unmarshal(rawVal interface{}, opts ...viper.DecoderConfigOption) error {
-	return _conf.Unmarshal(rawVal, opts...)
+	return Default().Unmarshal(rawVal, opts...)
 }
 
 func Set(key string, value interface{}) {
-	_conf.Set(key, value)
+	Default().Set(key, value)
+}
+
+// Default returns the config used by the package-level functions.
+func Default() Config {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return _conf
+}
+
+// SetDefault replaces the config used by the package-level functions.
+func SetDefault(conf Config) {
+	defaultMu.Lock()
+	_conf = conf
+	defaultMu.Unlock()
+}
+
+// ReplaceDefault sets conf as the default and returns a func to restore the old one.
+func ReplaceDefault(conf Config) (restore func()) {
+	defaultMu.Lock()
+	old := _conf
+	_conf = conf
+	defaultMu.Unlock()
+
+	return func() {
+		SetDefault(old)
+	}
+}
+
+// setDefaultIfNil makes the first created config the default,
+// so the package-level functions work without SetDefault.
+func setDefaultIfNil(conf Config) {
+	defaultMu.Lock()
+	if _conf == nil {
+		_conf = conf
+	}
+	defaultMu.Unlock()
 }
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KV is the minimal key/value store a config center needs to provide,
+// such as etcd, consul or apollo.
+type KV interface {
+	// List returns all pairs whose key starts with prefix.
+	List(prefix string) (map[string]string, error)
+}
+
+// KVWatcher is implemented by the stores which can push changes,
+// the others are polled. stop releases the watch.
+type KVWatcher interface {
+	WatchPrefix(prefix string, onChange func()) (stop func(), err error)
+}
+
+type kvSource struct {
+	kv KV
+
+	prefix string
+
+	interval time.Duration
+}
+
+type KVSourceOption func(*kvSource)
+
+// NewKVSource maps the keys under prefix to config keys, "/" is the separator,
+// e.g. "esim/redis_host" => "redis_host", "esim/dbs/0/dsn" => "dbs.0.dsn".
+// The nodes whose keys are 0..n-1 become lists, so "dbs" binds to a slice.
+// Load returns an error if a key is also the prefix of another key, e.g. "esim/dbs" and "esim/dbs/0".
+func NewKVSource(kv KV, prefix string, options ...KVSourceOption) Source {
+	ks := &kvSource{
+		kv:       kv,
+		prefix:   prefix,
+		interval: 30 * time.Second,
+	}
+
+	for _, option := range options {
+		option(ks)
+	}
+
+	return ks
+}
+
+// WithKVSourceInterval sets the poll interval if the KV can not push changes.
+func WithKVSourceInterval(interval time.Duration) KVSourceOption {
+	return func(ks *kvSource) {
+		ks.interval = interval
+	}
+}
+
+func (ks *kvSource) Load() (map[string]interface{}, error) {
+	pairs, err := ks.kv.List(ks.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	settings := make(map[string]interface{})
+	for _, key := range keys {
+		name := strings.Trim(strings.TrimPrefix(key, ks.prefix), "/")
+		if name == "" {
+			continue
+		}
+
+		// a 和 a/b 不能同时存在
+		paths := strings.Split(name, "/")
+		node := settings
+		for i, path := range paths[:len(paths)-1] {
+			child, ok := node[path]
+			if !ok {
+				child = make(map[string]interface{})
+				node[path] = child
+			}
+
+			if node, ok = child.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%s: %s conflicts with %s", ks.String(), key, strings.Join(paths[:i+1], "/"))
+			}
+		}
+
+		if _, ok := node[paths[len(paths)-1]]; ok {
+			return nil, fmt.Errorf("%s: %s conflicts with %s", ks.String(), key, name)
+		}
+		node[paths[len(paths)-1]] = pairs[key]
+	}
+
+	for key, val := range settings {
+		settings[key] = toList(val)
+	}
+
+	return settings, nil
+}
+
+// toList turns the map with the keys 0..n-1 into a slice, recursively.
+func toList(val interface{}) interface{} {
+	node, ok := val.(map[string]interface{})
+	if !ok {
+		return val
+	}
+
+	for key, child := range node {
+		node[key] = toList(child)
+	}
+
+	list := make([]interface{}, len(node))
+	for key, child := range node {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(node) || strconv.Itoa(i) != key {
+			return node
+		}
+		list[i] = child
+	}
+
+	return list
+}
+
+func (ks *kvSource) Watch(ctx context.Context, onChange func()) error {
+	if watcher, ok := ks.kv.(KVWatcher); ok {
+		stop, err := watcher.WatchPrefix(ks.prefix, onChange)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+
+		return nil
+	}
+
+	return poll(ctx, ks.interval, ks.String(), func() (string, error) {
+		pairs, err := ks.kv.List(ks.prefix)
+		if err != nil {
+			return "", err
+		}
+
+		keys := make([]string, 0, len(pairs))
+		for key := range pairs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "%s=%s\n", key, pairs[key])
+		}
+		return sb.String(), nil
+	}, onChange)
+}
+
+func (ks *kvSource) String() string {
+	return "kv:" + ks.prefix
+}
+
+// MemKV is an in-memory KV, it stands in for the config center
+// in local development and tests.
+type MemKV struct {
+	mu sync.RWMutex
+
+	data map[string]string
+
+	watchers map[int]memKVWatcher
+
+	nextID int
+}
+
+type memKVWatcher struct {
+	prefix string
+
+	onChange func()
+}
+
+func NewMemKV() *MemKV {
+	return &MemKV{
+		data:     make(map[string]string),
+		watchers: make(map[int]memKVWatcher),
+	}
+}
+
+func (mk *MemKV) List(prefix string) (map[string]string, error) {
+	mk.mu.RLock()
+	defer mk.mu.RUnlock()
+
+	pairs := make(map[string]string)
+	for key, val := range mk.data {
+		if strings.HasPrefix(key, prefix) {
+			pairs[key] = val
+		}
+	}
+
+	return pairs, nil
+}
+
+func (mk *MemKV) WatchPrefix(prefix string, onChange func()) (func(), error) {
+	mk.mu.Lock()
+	id := mk.nextID
+	mk.nextID++
+	mk.watchers[id] = memKVWatcher{prefix: prefix, onChange: onChange}
+	mk.mu.Unlock()
+
+	return func() {
+		mk.mu.Lock()
+		delete(mk.watchers, id)
+		mk.mu.Unlock()
+	}, nil
+}
+
+func (mk *MemKV) Put(key, val string) {
+	mk.mu.Lock()
+	mk.data[key] = val
+	mk.mu.Unlock()
+
+	mk.fire(key)
+}
+
+func (mk *MemKV) Delete(key string) {
+	mk.mu.Lock()
+	delete(mk.data, key)
+	mk.mu.Unlock()
+
+	mk.fire(key)
+}
+
+func (mk *MemKV) fire(key string) {
+	mk.mu.RLock()
+	watchers := make([]memKVWatcher, 0, len(mk.watchers))
+	for _, w := range mk.watchers {
+		if strings.HasPrefix(key, w.prefix) {
+			watchers = append(watchers, w)
+		}
+	}
+	mk.mu.RUnlock()
+
+	for _, w := range watchers {
+		w.onChange()
+	}
+}
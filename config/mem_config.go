@@ -8,18 +8,20 @@ import (
 )
 
 type MemConfig struct {
+	notifier
+
 	data map[string]interface{}
 }
 
 func NewMemConfig() *MemConfig {
 	return &MemConfig{
-		make(map[string]interface{}),
+		data: make(map[string]interface{}),
 	}
 }
 
 func (mc *MemConfig) Get(key string) interface{} {
 	if val, ok := mc.data[key]; ok {
-		return val
+		return decryptValue(val)
 	}
 
 	return nil
@@ -94,13 +96,19 @@ func (mc *MemConfig) GetSizeInBytes(key string) uint {
 
 func (mc *MemConfig) UnmarshalKey(key string, rawVal interface{},
 	opts ...viper.DecoderConfigOption) error {
-	return nil
+	return decode(mc.Get(key), rawVal, opts...)
 }
 
 func (mc *MemConfig) Unmarshal(rawVal interface{}, opts ...viper.DecoderConfigOption) error {
-	return nil
+	return decode(decryptValue(mc.data), rawVal, opts...)
 }
 
 func (mc *MemConfig) Set(key string, value interface{}) {
+	old := make(map[string]interface{}, len(mc.data))
+	for k, v := range mc.data {
+		old[k] = v
+	}
+
 	mc.data[key] = value
+	mc.notify(old, mc.data)
 }
@@ -221,17 +221,40 @@ func TestGetSizeInBytes(t *testing.T) {
 
 func TestUnmarshalKey(t *testing.T) {
 	memConfig := NewMemConfig()
-	res := memConfig.UnmarshalKey(testKey, testKey)
-	if res != nil {
-		t.Errorf("结果错误 应该是 nil 实际 %T", res)
+	memConfig.Set(testKey, map[string]interface{}{"name": testStrVal, "port": "6379"})
+
+	var res struct {
+		Name string
+		Port int
+	}
+	err := memConfig.UnmarshalKey(testKey, &res)
+	if err != nil {
+		t.Errorf("结果错误 应该是 nil 实际 %s", err.Error())
+	}
+	if res.Name != testStrVal || res.Port != 6379 {
+		t.Errorf("结果错误 实际 %+v", res)
+	}
+
+	if err = memConfig.UnmarshalKey(testKey, res); err == nil {
+		t.Errorf("结果错误 非指针应该返回错误")
 	}
 }
 
 func TestUnmarshal(t *testing.T) {
 	memConfig := NewMemConfig()
-	res := memConfig.Unmarshal(testKey)
-	if res != nil {
-		t.Errorf("结果错误 应该是 nil 实际 %T", res)
+	memConfig.Set(testKey, testStrVal)
+	memConfig.Set("timeout", "3s")
+
+	var res struct {
+		Test    string
+		Timeout time.Duration
+	}
+	err := memConfig.Unmarshal(&res)
+	if err != nil {
+		t.Errorf("结果错误 应该是 nil 实际 %s", err.Error())
+	}
+	if res.Test != testStrVal || res.Timeout != 3*time.Second {
+		t.Errorf("结果错误 实际 %+v", res)
 	}
 }
 
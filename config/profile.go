@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hyingerrr/mirco-esim/core/xenv"
+)
+
+const defaultEnvPrefix = "ESIM"
+
+// runMode reads runmode from ESIM_RUNMODE or the config files,
+// it is dev if not set. The run mode of the process is set by the container.
+func (vc *viperConf) runMode() xenv.DeployEnv {
+	var mode string
+	if vc.envPrefix != "" {
+		mode = os.Getenv(vc.envPrefix + "_RUNMODE")
+	}
+
+	if mode == "" {
+		for _, configFile := range vc.configFile {
+			settings, err := NewFileSource(configFile, vc.configType).Load()
+			if err != nil {
+				// reported when loading all sources.
+				continue
+			}
+
+			if val, ok := settings["runmode"].(string); ok && val != "" {
+				mode = val
+			}
+		}
+	}
+
+	runMode := xenv.NewRunMode(mode)
+	if !runMode.IsValid() {
+		log.Printf("config unknown runmode %s, skip the profile files \n", mode)
+		return ""
+	}
+
+	return runMode.GetEnv()
+}
+
+// profileFile returns conf.<env>.yaml for conf.yaml if it exists.
+func profileFile(configFile string, env xenv.DeployEnv) string {
+	if env == "" {
+		return ""
+	}
+
+	ext := filepath.Ext(configFile)
+	profile := strings.TrimSuffix(configFile, ext) + "." + string(env) + ext
+	if _, err := os.Stat(profile); err != nil {
+		return ""
+	}
+
+	return profile
+}
+
+type envSource struct {
+	prefix string
+}
+
+// NewEnvSource maps the environment variables to config keys,
+// e.g. ESIM_REDIS_HOST => redis_host, "__" is the nested separator,
+// ESIM_GRPC__TIMEOUT => grpc.timeout.
+func NewEnvSource(prefix string) Source {
+	return &envSource{prefix: strings.ToUpper(prefix) + "_"}
+}
+
+func (es *envSource) Load() (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	for _, env := range os.Environ() {
+		pair := strings.SplitN(env, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], es.prefix) {
+			continue
+		}
+
+		// never expose the secret key as a config value.
+		if pair[0] == SecretKeyEnv || pair[0] == SecretKeyFileEnv {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(pair[0], es.prefix))
+		if key == "" {
+			continue
+		}
+
+		paths := strings.Split(key, "__")
+		node := settings
+		for _, path := range paths[:len(paths)-1] {
+			child, ok := node[path].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[path] = child
+			}
+			node = child
+		}
+		node[paths[len(paths)-1]] = pair[1]
+	}
+
+	return settings, nil
+}
+
+// Watch does nothing, the environment of a process does not change.
+func (es *envSource) Watch(ctx context.Context, onChange func()) error {
+	return nil
+}
+
+func (es *envSource) String() string {
+	return "env:" + es.prefix + "*"
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/xenv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViperConf_Profile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim_profile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "conf.yaml")
+	assert.Nil(t, ioutil.WriteFile(file,
+		[]byte("runmode: qa\nredis_host: 127.0.0.1\nredis_port: 6379\nlog_level: info\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "conf.qa.yaml"),
+		[]byte("redis_host: 10.0.0.1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "conf.pro.yaml"),
+		[]byte("redis_host: 10.0.0.2\n"), 0644))
+
+	os.Setenv("ESIM_LOG_LEVEL", "debug")
+	defer os.Unsetenv("ESIM_LOG_LEVEL")
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{file}), options.WithWatch(false))
+	conf.Set("redis_port", 6380)
+	t.Cleanup(ReplaceDefault(conf))
+
+	assert.Equal(t, "10.0.0.1", conf.GetString("redis_host"))
+	// 运行环境由 container 设置
+	assert.False(t, xenv.IsQa())
+	assert.Equal(t, "debug", conf.GetString("log_level"))
+
+	layers := make(map[string]string)
+	for _, item := range Dump() {
+		layers[item.Key] = item.Layer
+	}
+	assert.Equal(t, "file:"+filepath.Join(dir, "conf.qa.yaml"), layers["redis_host"])
+	assert.Equal(t, "env:ESIM_*", layers["log_level"])
+	assert.Equal(t, "set", layers["redis_port"])
+	assert.Equal(t, "file:"+file, layers["runmode"])
+
+	t.Log(DumpString(Dump()))
+}
+
+func TestEnvSource_Load(t *testing.T) {
+	os.Setenv("ESIM_GRPC__TIMEOUT", "300")
+	os.Setenv(SecretKeyEnv, testSecretKey)
+	defer os.Unsetenv("ESIM_GRPC__TIMEOUT")
+	defer os.Unsetenv(SecretKeyEnv)
+
+	settings, err := NewEnvSource("esim").Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"timeout": "300"}, settings["grpc"])
+	assert.Nil(t, settings["config_key"])
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Hyingerrr/mirco-esim/pkg/security"
+)
+
+const (
+	// SecretKeyEnv holds the AES key of the ENC(...) values, 16, 24 or 32 bytes,
+	// raw or base64 encoded.
+	SecretKeyEnv = "ESIM_CONFIG_KEY"
+
+	// SecretKeyFileEnv is the path of the file holding the AES key,
+	// used if SecretKeyEnv is empty.
+	SecretKeyFileEnv = "ESIM_CONFIG_KEY_FILE"
+
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+var (
+	secretKey []byte
+
+	secretKeyMu sync.RWMutex
+
+	// ciphertext => plaintext, swapped with the key under secretKeyMu
+	secretCache = &sync.Map{}
+)
+
+// SetSecretKey overrides the key from SecretKeyEnv and SecretKeyFileEnv.
+func SetSecretKey(key []byte) {
+	secretKeyMu.Lock()
+	secretKey = key
+	secretCache = &sync.Map{}
+	secretKeyMu.Unlock()
+}
+
+func loadSecretCache() *sync.Map {
+	secretKeyMu.RLock()
+	defer secretKeyMu.RUnlock()
+
+	return secretCache
+}
+
+// LoadSecretKey returns the key set by SetSecretKey,
+// or read from SecretKeyEnv, or the file of SecretKeyFileEnv.
+func LoadSecretKey() ([]byte, error) {
+	secretKeyMu.RLock()
+	key := secretKey
+	secretKeyMu.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	raw := os.Getenv(SecretKeyEnv)
+	if raw == "" {
+		keyFile := os.Getenv(SecretKeyFileEnv)
+		if keyFile == "" {
+			return nil, fmt.Errorf("config secret key not found, set %s or %s",
+				SecretKeyEnv, SecretKeyFileEnv)
+		}
+
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		raw = strings.TrimSpace(string(content))
+	}
+
+	return ParseSecretKey(raw)
+}
+
+// ParseSecretKey accepts the raw or base64 encoded AES key.
+func ParseSecretKey(raw string) ([]byte, error) {
+	if isAESKey([]byte(raw)) {
+		return []byte(raw), nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && isAESKey(key) {
+		return key, nil
+	}
+
+	return nil, errors.New("config secret key must be 16, 24 or 32 bytes")
+}
+
+func isAESKey(key []byte) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	}
+
+	return false
+}
+
+// IsEncrypted reports whether val is written as ENC(...).
+func IsEncrypted(val string) bool {
+	return strings.HasPrefix(val, encPrefix) && strings.HasSuffix(val, encSuffix)
+}
+
+// Encrypt returns ENC(base64(iv + AES-CBC(plaintext))).
+func Encrypt(plaintext string, key []byte) (string, error) {
+	iv := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+
+	crypted, err := security.AESEncryptIV([]byte(plaintext), key, iv, security.AES_CBC_PKCS7PADDING)
+	if err != nil {
+		return "", err
+	}
+
+	return encPrefix + base64.StdEncoding.EncodeToString(append(iv, crypted...)) + encSuffix, nil
+}
+
+// Decrypt decrypts the ENC(...) value with the secret key,
+// the others are returned as they are.
+func Decrypt(val string) (string, error) {
+	if !IsEncrypted(val) {
+		return val, nil
+	}
+
+	// 解密期间换了 key 时, 结果存入旧的缓存后丢弃
+	cache := loadSecretCache()
+	if plain, ok := cache.Load(val); ok {
+		return plain.(string), nil
+	}
+
+	key, err := LoadSecretKey()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(val[len(encPrefix) : len(val)-len(encSuffix)])
+	if err != nil {
+		return "", err
+	}
+
+	// iv + at least one block
+	if len(data) < 32 || len(data)%16 != 0 {
+		return "", errors.New("config secret is broken")
+	}
+
+	plain, err := security.AESDecryptIV(data[16:], key, data[:16], security.AES_CBC_PKCS7PADDING)
+	if err != nil {
+		return "", err
+	}
+
+	cache.Store(val, string(plain))
+	return string(plain), nil
+}
+
+// decryptValue decrypts every ENC(...) in val, including nested maps and slices,
+// the value which can not be decrypted is kept.
+func decryptValue(val interface{}) interface{} {
+	val, _ = walkSecrets(val)
+	return val
+}
+
+// checkSecrets returns the first ENC(...) value can not be decrypted.
+func checkSecrets(val interface{}) error {
+	_, err := walkSecrets(val)
+	return err
+}
+
+func walkSecrets(val interface{}) (interface{}, error) {
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	switch v := val.(type) {
+	case string:
+		plain, err := Decrypt(v)
+		if err != nil {
+			return v, err
+		}
+		return plain, nil
+	case []interface{}:
+		dst := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			dst[i], err = walkSecrets(item)
+			keep(err)
+		}
+		return dst, firstErr
+	case []string:
+		dst := make([]string, len(v))
+		for i, item := range v {
+			plain, err := Decrypt(item)
+			keep(err)
+			if err != nil {
+				plain = item
+			}
+			dst[i] = plain
+		}
+		return dst, firstErr
+	case map[string]interface{}:
+		dst := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			var err error
+			dst[k], err = walkSecrets(item)
+			keep(err)
+		}
+		return dst, firstErr
+	case map[interface{}]interface{}:
+		dst := make(map[interface{}]interface{}, len(v))
+		for k, item := range v {
+			var err error
+			dst[k], err = walkSecrets(item)
+			keep(err)
+		}
+		return dst, firstErr
+	case map[string]string:
+		dst := make(map[string]string, len(v))
+		for k, item := range v {
+			plain, err := Decrypt(item)
+			keep(err)
+			if err != nil {
+				plain = item
+			}
+			dst[k] = plain
+		}
+		return dst, firstErr
+	}
+
+	return val, nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecretKey = "0123456789abcdef"
+
+func TestEncrypt_Decrypt(t *testing.T) {
+	SetSecretKey([]byte(testSecretKey))
+	defer SetSecretKey(nil)
+
+	ciphertext, err := Encrypt("123456", []byte(testSecretKey))
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(ciphertext))
+
+	plain, err := Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "123456", plain)
+
+	plain, err = Decrypt("plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", plain)
+
+	_, err = Decrypt("ENC(YnJva2Vu)")
+	assert.NotNil(t, err)
+}
+
+func TestLoadSecretKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim_secret")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "config.key")
+	assert.Nil(t, ioutil.WriteFile(keyFile, []byte(testSecretKey+"\n"), 0600))
+
+	os.Setenv(SecretKeyFileEnv, keyFile)
+	defer os.Unsetenv(SecretKeyFileEnv)
+
+	key, err := LoadSecretKey()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(testSecretKey), key)
+
+	os.Setenv(SecretKeyEnv, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	defer os.Unsetenv(SecretKeyEnv)
+
+	key, err = LoadSecretKey()
+	assert.Nil(t, err)
+	assert.Len(t, key, 32)
+}
+
+func TestConfig_Decrypt(t *testing.T) {
+	SetSecretKey([]byte(testSecretKey))
+	defer SetSecretKey(nil)
+
+	password, err := Encrypt("123456", []byte(testSecretKey))
+	assert.Nil(t, err)
+	dsn, err := Encrypt("root:123456@tcp(127.0.0.1:3306)/esim", []byte(testSecretKey))
+	assert.Nil(t, err)
+
+	memConfig := NewMemConfig()
+	memConfig.Set("redis_password", password)
+	assert.Equal(t, "123456", memConfig.GetString("redis_password"))
+
+	dir, err := ioutil.TempDir("", "esim_secret")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "conf.yaml")
+	content := "redis_password: " + password + "\ndbs:\n  - db: esim\n    dsn: " + dsn + "\n"
+	assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0644))
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{file}), options.WithWatch(false))
+	assert.Equal(t, "123456", conf.GetString("redis_password"))
+
+	dbs := make([]struct {
+		Db  string
+		Dsn string
+	}, 0)
+	assert.Nil(t, conf.UnmarshalKey("dbs", &dbs))
+	assert.Equal(t, "root:123456@tcp(127.0.0.1:3306)/esim", dbs[0].Dsn)
+}
+
+func TestSetSecretKey_Concurrent(t *testing.T) {
+	defer SetSecretKey(nil)
+
+	ciphertext, err := Encrypt("123456", []byte(testSecretKey))
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = Decrypt(ciphertext)
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		SetSecretKey([]byte(testSecretKey))
+	}
+	wg.Wait()
+
+	plain, err := Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "123456", plain)
+}
+
+// 重新加载时密文有误, 保留旧的配置
+func TestViperConf_ReloadBrokenSecret(t *testing.T) {
+	SetSecretKey([]byte(testSecretKey))
+	defer SetSecretKey(nil)
+
+	password, err := Encrypt("123456", []byte(testSecretKey))
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "esim_secret")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "conf.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("redis_password: "+password+"\n"), 0644))
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{file}), options.WithWatch(false)).(*viperConf)
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("redis_password: ENC(YnJva2Vu)\n"), 0644))
+	assert.NotNil(t, conf.reload(0))
+	assert.Equal(t, "123456", conf.GetString("redis_password"))
+}
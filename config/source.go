@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// editors and configmap updates fire several events for one save.
+const reloadDelay = 100 * time.Millisecond
+
+// Source supplies a layer of settings to viperConf.
+type Source interface {
+	// Load returns all settings of the source, nested maps are allowed.
+	Load() (map[string]interface{}, error)
+
+	// Watch calls onChange every time the source changed until ctx is done.
+	// It must not block.
+	Watch(ctx context.Context, onChange func()) error
+
+	String() string
+}
+
+type fileSource struct {
+	file string
+
+	configType string
+}
+
+// NewFileSource reads a local config file, the environment variables
+// in the file like ${REDIS_HOST} are expanded.
+func NewFileSource(file, configType string) Source {
+	if configType == "" {
+		configType = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+
+	return &fileSource{
+		file:       file,
+		configType: configType,
+	}
+}
+
+func (fs *fileSource) Load() (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(fs.file)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType(fs.configType)
+	err = v.ReadConfig(strings.NewReader(os.ExpandEnv(string(content))))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fs.file, err.Error())
+	}
+
+	return v.AllSettings(), nil
+}
+
+// Watch watches the directory of the file, so the file
+// replaced by rename (vim, k8s configmap) is also caught.
+func (fs *fileSource) Watch(ctx context.Context, onChange func()) error {
+	abs, err := filepath.Abs(fs.file)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err = watcher.Add(filepath.Dir(abs)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+			watcher.Close()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// k8s configmap swaps the ..data symlink instead of the file.
+				if filepath.Clean(event.Name) != abs && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("config watch %s error: %s \n", fs.file, err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (fs *fileSource) String() string {
+	return "file:" + fs.file
+}
+
+// poll calls onChange when the hash returned by load changes.
+// It is used by the sources can not push the changes.
+func poll(ctx context.Context, interval time.Duration, name string,
+	load func() (string, error), onChange func()) error {
+	last, err := load()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cur, err := load()
+				if err != nil {
+					log.Printf("config poll %s error: %s \n", name, err.Error())
+					continue
+				}
+
+				if cur != last {
+					last = cur
+					onChange()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSource_Load(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "esim", r.Header.Get("X-App"))
+		_, _ = w.Write([]byte(`{"redis_host": "10.0.0.1", "grpc": {"timeout": 300}}`))
+	}))
+	defer ts.Close()
+
+	source := NewHTTPSource(ts.URL, WithHTTPSourceHeader("X-App", "esim"))
+	settings, err := source.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", settings["redis_host"])
+	assert.Equal(t, map[string]interface{}{"timeout": float64(300)}, settings["grpc"])
+}
+
+func TestKVSource_Load(t *testing.T) {
+	kv := NewMemKV()
+	kv.Put("esim/redis_host", "10.0.0.2")
+	kv.Put("esim/grpc/timeout", "300")
+	kv.Put("other/redis_host", "10.0.0.3")
+
+	settings, err := NewKVSource(kv, "esim/").Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"redis_host": "10.0.0.2",
+		"grpc":       map[string]interface{}{"timeout": "300"},
+	}, settings)
+}
+
+func TestKVSource_List(t *testing.T) {
+	kv := NewMemKV()
+	kv.Put("esim/dbs/0/db", "esim")
+	kv.Put("esim/dbs/0/dsn", "root@tcp(10.0.0.1)/esim")
+	kv.Put("esim/dbs/1/db", "order")
+	kv.Put("esim/shards/0", "a")
+	kv.Put("esim/shards/2", "c")
+
+	settings, err := NewKVSource(kv, "esim/").Load()
+	assert.Nil(t, err)
+	// 下标不连续时保留为 map
+	assert.Equal(t, map[string]interface{}{"0": "a", "2": "c"}, settings["shards"])
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{"a.yaml"}),
+		options.WithSources(NewKVSource(kv, "esim/")))
+	defer conf.(*viperConf).Close()
+
+	var dbs struct {
+		Dbs []struct {
+			Db  string `mapstructure:"db"`
+			Dsn string `mapstructure:"dsn"`
+		} `mapstructure:"dbs"`
+	}
+	assert.Nil(t, BindFrom(conf, "", &dbs))
+	assert.Len(t, dbs.Dbs, 2)
+	assert.Equal(t, "root@tcp(10.0.0.1)/esim", dbs.Dbs[0].Dsn)
+	assert.Equal(t, "order", dbs.Dbs[1].Db)
+}
+
+func TestKVSource_Conflict(t *testing.T) {
+	kv := NewMemKV()
+	kv.Put("esim/grpc/timeout", "300")
+	kv.Put("esim/grpc", "on")
+
+	_, err := NewKVSource(kv, "esim/").Load()
+	assert.EqualError(t, err, "kv:esim/: esim/grpc/timeout conflicts with grpc")
+
+	kv.Delete("esim/grpc/timeout")
+	kv.Put("esim/grpc/", "off")
+	_, err = NewKVSource(kv, "esim/").Load()
+	assert.EqualError(t, err, "kv:esim/: esim/grpc/ conflicts with grpc")
+}
+
+func TestMemKV_StopWatch(t *testing.T) {
+	kv := NewMemKV()
+
+	var calls int
+	stop, err := kv.WatchPrefix("esim/", func() { calls++ })
+	assert.Nil(t, err)
+
+	kv.Put("esim/name", "a")
+	stop()
+	kv.Put("esim/name", "b")
+
+	assert.Equal(t, 1, calls)
+	assert.Len(t, kv.watchers, 0)
+}
+
+func TestViperConf_Sources(t *testing.T) {
+	kv := NewMemKV()
+	kv.Put("esim/name", "from_kv")
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{"a.yaml", "b.yaml"}),
+		options.WithSources(NewKVSource(kv, "esim/")))
+	defer conf.(*viperConf).Close()
+
+	// the kv source overrides the files.
+	assert.Equal(t, "from_kv", conf.GetString("name"))
+	assert.Equal(t, "Esim", conf.GetString("appname"))
+
+	changed := make(chan interface{}, 1)
+	conf.(Notifier).OnChange("redis_host", func(old, new interface{}) {
+		changed <- new
+	})
+
+	kv.Put("esim/redis_host", "10.0.0.4")
+	select {
+	case val := <-changed:
+		assert.Equal(t, "10.0.0.4", val)
+	case <-time.After(time.Second):
+		t.Fatal("not receive the change")
+	}
+
+	kv.Delete("esim/name")
+	assert.Equal(t, "esim", conf.GetString("name"))
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Notifier is implemented by the configs which can report changed values.
+type Notifier interface {
+	// OnChange calls fn when any key starting with keyPrefix changes.
+	// If keyPrefix names a single key, old and new are the values of that key,
+	// otherwise they are map[string]interface{} of every key under keyPrefix.
+	OnChange(keyPrefix string, fn func(old, new interface{}))
+}
+
+type subscriber struct {
+	keyPrefix string
+
+	fn func(old, new interface{})
+}
+
+type notifier struct {
+	subMu sync.RWMutex
+
+	subs []subscriber
+}
+
+func (n *notifier) OnChange(keyPrefix string, fn func(old, new interface{})) {
+	n.subMu.Lock()
+	n.subs = append(n.subs, subscriber{keyPrefix: keyPrefix, fn: fn})
+	n.subMu.Unlock()
+}
+
+// notify compares the two flat settings and calls the matched subscribers.
+func (n *notifier) notify(oldSettings, newSettings map[string]interface{}) {
+	n.subMu.RLock()
+	subs := make([]subscriber, len(n.subs))
+	copy(subs, n.subs)
+	n.subMu.RUnlock()
+
+	for _, sub := range subs {
+		oldVal := pickPrefix(oldSettings, sub.keyPrefix)
+		newVal := pickPrefix(newSettings, sub.keyPrefix)
+		if !reflect.DeepEqual(oldVal, newVal) {
+			sub.fn(oldVal, newVal)
+		}
+	}
+}
+
+func pickPrefix(settings map[string]interface{}, keyPrefix string) interface{} {
+	if val, ok := settings[keyPrefix]; ok {
+		return val
+	}
+
+	matched := make(map[string]interface{})
+	for k, v := range settings {
+		if strings.HasPrefix(k, keyPrefix) {
+			matched[k] = v
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	return matched
+}
+
+func flatSettings(v *viper.Viper) map[string]interface{} {
+	settings := make(map[string]interface{})
+	for _, key := range v.AllKeys() {
+		settings[key] = v.Get(key)
+	}
+
+	return settings
+}
+
+// OnChange keys are case insensitive in viper.
+func (vc *viperConf) OnChange(keyPrefix string, fn func(old, new interface{})) {
+	vc.notifier.OnChange(strings.ToLower(keyPrefix), fn)
+}
+
+// reload re-loads the k-th source, rebuilds the viper and notifies the subscribers.
+// The old values are kept if the source is broken or has a broken secret.
+func (vc *viperConf) reload(k int) error {
+	vc.reloadMu.Lock()
+	defer vc.reloadMu.Unlock()
+
+	settings, err := vc.sources[k].Load()
+	if err != nil {
+		return err
+	}
+
+	vc.mu.Lock()
+	oldSettings := vc.layers[k]
+	vc.layers[k] = settings
+	v, err := vc.merge()
+	if err == nil {
+		err = checkSecrets(v.AllSettings())
+	}
+	if err != nil {
+		vc.layers[k] = oldSettings
+		vc.mu.Unlock()
+		return err
+	}
+	old := flatSettings(vc.Viper)
+	vc.Viper = v
+	cur := flatSettings(v)
+	vc.mu.Unlock()
+
+	vc.notify(old, cur)
+
+	return nil
+}
+
+func (vc *viperConf) watchSources() {
+	ctx, cancel := context.WithCancel(context.Background())
+	vc.cancel = cancel
+
+	for k, source := range vc.sources {
+		k, source := k, source
+		err := source.Watch(ctx, func() {
+			if err := vc.reload(k); err != nil {
+				log.Printf("config reload %s error: %s \n", source, err.Error())
+			}
+		})
+		if err != nil {
+			log.Printf("config watch %s error: %s \n", source, err.Error())
+		}
+	}
+}
+
+// Close stops watching the sources.
+func (vc *viperConf) Close() error {
+	if vc.cancel != nil {
+		vc.cancel()
+	}
+
+	return nil
+}
+
+// OnChange subscribes the changes of the default config,
+// it does nothing if the config can not report changes.
+func OnChange(keyPrefix string, fn func(old, new interface{})) {
+	if n, ok := Default().(Notifier); ok {
+		n.OnChange(keyPrefix, fn)
+	}
+}
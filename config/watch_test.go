@@ -0,0 +1,56 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViperConf_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim_config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "conf.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("log_level: info\nredis_max_idle: 10\n"), 0644))
+
+	options := ViperConfOptions{}
+	conf := NewViperConfig(options.WithConfFile([]string{file})).(*viperConf)
+
+	changed := make(chan [2]interface{}, 1)
+	conf.OnChange("log_level", func(old, new interface{}) {
+		changed <- [2]interface{}{old, new}
+	})
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("log_level: debug\nredis_max_idle: 10\n"), 0644))
+
+	select {
+	case vals := <-changed:
+		assert.Equal(t, "info", vals[0])
+		assert.Equal(t, "debug", vals[1])
+	case <-time.After(3 * time.Second):
+		t.Fatal("not receive the change")
+	}
+	assert.Equal(t, "debug", conf.GetString("log_level"))
+	assert.Nil(t, conf.Close())
+}
+
+func TestMemConfig_OnChange(t *testing.T) {
+	memConfig := NewMemConfig()
+	memConfig.Set("redis_max_idle", 10)
+
+	var got interface{}
+	memConfig.OnChange("redis_", func(old, new interface{}) {
+		got = new
+	})
+
+	memConfig.Set("redis_max_idle", 10)
+	assert.Nil(t, got)
+
+	memConfig.Set("redis_max_idle", 20)
+	assert.Equal(t, map[string]interface{}{"redis_max_idle": 20}, got)
+}
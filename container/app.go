@@ -0,0 +1,294 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+	"github.com/Hyingerrr/mirco-esim/transports"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// 超时后 tracer 和日志仍有一段单独的时间刷新
+const shutdownFlushTimeout = 3 * time.Second
+
+// Closer is closed when the app stops, e.g. infra.Infra.
+type Closer interface {
+	Close()
+}
+
+// CloserFunc adapts a func to Closer.
+type CloserFunc func()
+
+func (f CloserFunc) Close() {
+	f()
+}
+
+type component struct {
+	name string
+	stop func()
+}
+
+// App owns the startup probes, the transports and the closers of a service.
+// The probes run before the transports start, see Boot. The transports start in the order they are registered,
+// and at shutdown they stop in reverse order, then the closers
+// in reverse order, then the instances of the registry are closed,
+// the admin server stops and the tracer and the logger are flushed.
+// The tracer and the logger are flushed even if the shutdown times out.
+type App struct {
+	*Esim
+
+	trans   []transports.Transports
+	names   []string
+	closers []component
+
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+
+	probes     []*probe
+	bootOnce   sync.Once
+	bootReport *BootReport
+	bootErr    error
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+type AppOption func(*App)
+
+type AppOptions struct{}
+
+func NewApp(options ...AppOption) *App {
+	app := &App{}
+
+	for _, option := range options {
+		option(app)
+	}
+
+	if app.Esim == nil {
+		app.Esim = NewEsim()
+	}
+
+	if app.shutdownTimeout <= 0 && app.Conf != nil {
+		// 单位毫秒
+		app.shutdownTimeout = time.Duration(app.Conf.GetInt("shutdown_timeout")) * time.Millisecond
+	}
+
+	if app.shutdownTimeout <= 0 {
+		app.shutdownTimeout = defaultShutdownTimeout
+	}
+
+	if len(app.signals) == 0 {
+		app.signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+
+	return app
+}
+
+func (AppOptions) WithEsim(esim *Esim) AppOption {
+	return func(app *App) {
+		app.Esim = esim
+	}
+}
+
+// WithShutdownTimeout is the deadline of the whole shutdown.
+func (AppOptions) WithShutdownTimeout(timeout time.Duration) AppOption {
+	return func(app *App) {
+		app.shutdownTimeout = timeout
+	}
+}
+
+func (AppOptions) WithSignals(signals ...os.Signal) AppOption {
+	return func(app *App) {
+		app.signals = signals
+	}
+}
+
+func (app *App) RegisterTran(tran transports.Transports) {
+	app.RegisterNamedTran(fmt.Sprintf("%T", tran), tran)
+}
+
+// RegisterNamedTran names the transport in the shutdown report.
+func (app *App) RegisterNamedTran(name string, tran transports.Transports) {
+	app.trans = append(app.trans, tran)
+	app.names = append(app.names, name)
+}
+
+func (app *App) RegisterCloser(closer Closer) {
+	app.RegisterNamedCloser(fmt.Sprintf("%T", closer), closer)
+}
+
+// RegisterNamedCloser names the closer in the shutdown report.
+func (app *App) RegisterNamedCloser(name string, closer Closer) {
+	app.closers = append(app.closers, component{name: name, stop: closer.Close})
+}
+
+// Start runs Boot, then starts the transports in order.
+// It panics if the boot is aborted.
+func (app *App) Start() {
+	if err := app.Boot(); err != nil {
+		app.Logger.Panicf("%s", err.Error())
+	}
+
+	for i, tran := range app.trans {
+		app.Logger.Infof("starting %s", app.names[i])
+		tran.Start()
+	}
+}
+
+// AwaitSignal blocks until one of the signals arrives.
+func (app *App) AwaitSignal() os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, app.signals...)
+	defer signal.Stop(c)
+
+	s := <-c
+	app.Logger.Infof("receive a signal %s", s.String())
+
+	return s
+}
+
+// Run starts the app, and shuts it down after a signal.
+// The error of Boot is returned without starting the transports.
+func (app *App) Run() error {
+	if err := app.Boot(); err != nil {
+		if closeErr := Instances().Close(); closeErr != nil {
+			app.Logger.Errorf("%s", closeErr.Error())
+		}
+		return err
+	}
+
+	app.Start()
+	app.AwaitSignal()
+
+	return app.Shutdown()
+}
+
+// Shutdown stops everything in reverse order within the shutdown timeout,
+// a *ShutdownError is returned if a component blocks.
+// Only the first call works, the later calls return the same result.
+func (app *App) Shutdown() error {
+	app.shutdownOnce.Do(func() {
+		app.shutdownErr = app.shutdown()
+	})
+
+	return app.shutdownErr
+}
+
+func (app *App) shutdown() error {
+	// 先摘除流量
+	health.SetReady(false)
+
+	components := make([]component, 0, len(app.trans)+len(app.closers)+2)
+	for i := len(app.trans) - 1; i >= 0; i-- {
+		components = append(components, component{name: app.names[i], stop: app.trans[i].GracefulShutDown})
+	}
+
+	for i := len(app.closers) - 1; i >= 0; i-- {
+		components = append(components, app.closers[i])
+	}
+
+	components = append(components, component{name: "instances", stop: func() {
+		if err := Instances().Close(); err != nil {
+			app.Logger.Errorf("%s", err.Error())
+		}
+	}})
+
+	deadline := time.Now().Add(app.shutdownTimeout)
+
+	components = append(components, component{name: "admin", stop: func() {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		if err := app.Esim.Shutdown(ctx); err != nil {
+			app.Logger.Errorf("shutdown admin server: %s", err.Error())
+		}
+	}})
+
+	var err error
+	for i, comp := range components {
+		begin := time.Now()
+		if !runUntil(comp.stop, deadline) {
+			shutdownErr := &ShutdownError{Blocked: comp.name, Timeout: app.shutdownTimeout}
+			for _, skipped := range components[i+1:] {
+				shutdownErr.Skipped = append(shutdownErr.Skipped, skipped.name)
+			}
+			err = shutdownErr
+			break
+		}
+
+		app.Logger.Infof("%s stopped, cost %v", comp.name, time.Since(begin))
+	}
+
+	if err != nil {
+		app.Logger.Errorf("shutdown: %s", err.Error())
+	}
+
+	app.flush()
+
+	return err
+}
+
+// flush closes the tracer and the logger, each one within shutdownFlushTimeout.
+func (app *App) flush() {
+	if app.Tracer != nil && app.Tracer.Closer != nil {
+		if !runUntil(func() {
+			if err := app.Tracer.Close(); err != nil {
+				app.Logger.Errorf("close tracer: %s", err.Error())
+			}
+		}, time.Now().Add(shutdownFlushTimeout)) {
+			app.Logger.Errorf("close tracer over %v", shutdownFlushTimeout)
+		}
+	}
+
+	// 最后刷新日志并停止异步写入, 终端上的 stdout 不支持 sync, 忽略错误
+	runUntil(func() {
+		_ = logx.Close(app.Logger)
+	}, time.Now().Add(shutdownFlushTimeout))
+}
+
+// runUntil reports whether fn returns before the deadline.
+func runUntil(fn func(), deadline time.Time) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// ShutdownError reports the component which blocked the shutdown.
+type ShutdownError struct {
+	Blocked string
+
+	// 因超时未执行的组件
+	Skipped []string
+
+	Timeout time.Duration
+}
+
+func (se *ShutdownError) Error() string {
+	msg := fmt.Sprintf("%s blocked the shutdown over %v", se.Blocked, se.Timeout)
+	if len(se.Skipped) > 0 {
+		msg += ", skipped: " + strings.Join(se.Skipped, ", ")
+	}
+
+	return msg
+}
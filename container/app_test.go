@@ -0,0 +1,155 @@
+package container
+
+import (
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/tracer"
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type fakeTran struct {
+	name  string
+	rec   *recorder
+	block time.Duration
+}
+
+func (f *fakeTran) Start() {
+	f.rec.add("start " + f.name)
+}
+
+func (f *fakeTran) GracefulShutDown() {
+	time.Sleep(f.block)
+	f.rec.add("stop " + f.name)
+}
+
+type tracerCloser struct {
+	rec *recorder
+}
+
+func (tc tracerCloser) Close() error {
+	tc.rec.add("close tracer")
+	return nil
+}
+
+func newTestApp(options ...AppOption) *App {
+	return newTestAppWithConf(config.NewMemConfig(), options...)
+}
+
+func newTestAppWithConf(conf config.Config, options ...AppOption) *App {
+	loggerOptions := log.LoggerOptions{}
+	esim := &Esim{Conf: conf, Logger: log.NewLogger(loggerOptions.WithLoggerConf(conf))}
+
+	appOptions := AppOptions{}
+	return NewApp(append([]AppOption{appOptions.WithEsim(esim)}, options...)...)
+}
+
+func TestApp_Shutdown(t *testing.T) {
+	rec := &recorder{}
+	app := newTestApp()
+	app.RegisterNamedTran("grpc", &fakeTran{name: "grpc", rec: rec})
+	app.RegisterNamedTran("http", &fakeTran{name: "http", rec: rec})
+	app.RegisterNamedCloser("redis", CloserFunc(func() { rec.add("close redis") }))
+	app.RegisterNamedCloser("mysql", CloserFunc(func() { rec.add("close mysql") }))
+
+	app.Start()
+	assert.Nil(t, app.Shutdown())
+	assert.Equal(t, []string{
+		"start grpc", "start http",
+		"stop http", "stop grpc",
+		"close mysql", "close redis",
+	}, rec.get())
+
+	// only once
+	assert.Nil(t, app.Shutdown())
+	assert.Len(t, rec.get(), 6)
+	assert.Equal(t, defaultShutdownTimeout, app.shutdownTimeout)
+}
+
+func TestApp_ShutdownBlocked(t *testing.T) {
+	rec := &recorder{}
+	appOptions := AppOptions{}
+	app := newTestApp(appOptions.WithShutdownTimeout(50 * time.Millisecond))
+	app.RegisterTran(&fakeTran{name: "grpc", rec: rec})
+	app.RegisterNamedTran("http", &fakeTran{name: "http", rec: rec, block: time.Second})
+	app.RegisterNamedCloser("redis", CloserFunc(func() { rec.add("close redis") }))
+
+	begin := time.Now()
+	err := app.Shutdown()
+	assert.True(t, time.Since(begin) < time.Second)
+
+	shutdownErr, ok := err.(*ShutdownError)
+	assert.True(t, ok)
+	assert.Equal(t, "http", shutdownErr.Blocked)
+	assert.Equal(t, []string{"*container.fakeTran", "redis", "instances", "admin"}, shutdownErr.Skipped)
+	assert.Contains(t, err.Error(), "http blocked the shutdown")
+}
+
+func TestApp_ShutdownBlockedFlushTracer(t *testing.T) {
+	rec := &recorder{}
+	appOptions := AppOptions{}
+	app := newTestApp(appOptions.WithShutdownTimeout(50 * time.Millisecond))
+	app.Tracer = &tracer.EsimTracer{Closer: tracerCloser{rec: rec}}
+	app.RegisterNamedTran("http", &fakeTran{name: "http", rec: rec, block: time.Second})
+
+	err := app.Shutdown()
+	assert.IsType(t, &ShutdownError{}, err)
+	assert.Equal(t, []string{"close tracer"}, rec.get())
+}
+
+func TestApp_ShutdownTimeoutConf(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("shutdown_timeout", 5000)
+
+	app := newTestAppWithConf(conf)
+	assert.Equal(t, 5*time.Second, app.shutdownTimeout)
+}
+
+func TestApp_Run(t *testing.T) {
+	rec := &recorder{}
+	appOptions := AppOptions{}
+	app := newTestApp(appOptions.WithSignals(syscall.SIGUSR1))
+	app.RegisterNamedTran("grpc", &fakeTran{name: "grpc", rec: rec})
+
+	done := make(chan error)
+	go func() {
+		done <- app.Run()
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(rec.get()) == 1
+	}, time.Second, 5*time.Millisecond)
+	// wait for signal.Notify
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("app is not stopped")
+	}
+	assert.Equal(t, []string{"start grpc", "stop grpc"}, rec.get())
+}
@@ -0,0 +1,302 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/health"
+)
+
+// Status of a startup probe.
+const (
+	BootOK      = "ok"
+	BootFail    = "fail"
+	BootSkipped = "skipped"
+)
+
+var errProbeTimeout = errors.New("boot: probe timeout")
+
+// BootConfig controls the boot phase run before the transports start.
+type BootConfig struct {
+	// 所有探测的总时限
+	Timeout time.Duration `mapstructure:"boot_timeout" default:"30s"`
+	// 单次探测的时限
+	ProbeTimeout time.Duration `mapstructure:"boot_probe_timeout" default:"3s"`
+	// 失败后的重试次数, 间隔从 RetryInterval 开始翻倍
+	Retries       int           `mapstructure:"boot_retries" default:"3" validate:"gte=0"`
+	RetryInterval time.Duration `mapstructure:"boot_retry_interval" default:"500ms"`
+	// 关键组件探测失败时继续启动, /readyz 报告 degraded
+	Degraded bool `mapstructure:"boot_degraded"`
+}
+
+type probe struct {
+	name     string
+	checker  health.Checker
+	critical bool
+	retries  int
+	timeout  time.Duration
+}
+
+type ProbeOption func(*probe)
+
+type ProbeOptions struct{}
+
+// WithCritical false means the failure of the probe never aborts the boot.
+func (ProbeOptions) WithCritical(critical bool) ProbeOption {
+	return func(p *probe) {
+		p.critical = critical
+	}
+}
+
+// WithRetries overrides boot_retries.
+func (ProbeOptions) WithRetries(retries int) ProbeOption {
+	return func(p *probe) {
+		p.retries = retries
+	}
+}
+
+// WithTimeout overrides boot_probe_timeout.
+func (ProbeOptions) WithTimeout(timeout time.Duration) ProbeOption {
+	return func(p *probe) {
+		p.timeout = timeout
+	}
+}
+
+// BootResult is a row of the boot table.
+type BootResult struct {
+	Component string
+	Status    string
+	Critical  bool
+	Attempts  int
+	Latency   time.Duration
+	Error     string
+}
+
+// BootReport is the result of the boot phase.
+type BootReport struct {
+	Results  []BootResult
+	Degraded bool
+	Cost     time.Duration
+}
+
+// Failed returns the critical components which fail.
+func (br *BootReport) Failed() []string {
+	var failed []string
+	for _, result := range br.Results {
+		if result.Critical && result.Status != BootOK {
+			failed = append(failed, result.Component)
+		}
+	}
+
+	return failed
+}
+
+// String prints the report as a table.
+func (br *BootReport) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tSTATUS\tCRITICAL\tATTEMPTS\tLATENCY\tERROR")
+	for _, result := range br.Results {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%v\t%s\n", result.Component, result.Status,
+			result.Critical, result.Attempts, result.Latency.Round(time.Millisecond), result.Error)
+	}
+	w.Flush()
+
+	return sb.String()
+}
+
+// BootError aborts the boot.
+type BootError struct {
+	Failed []string
+}
+
+func (be *BootError) Error() string {
+	return "boot: critical components failed: " + strings.Join(be.Failed, ", ")
+}
+
+// RegisterProbe adds a startup probe, probes run concurrently in Boot.
+// The probe is critical by default.
+func (app *App) RegisterProbe(name string, checker health.Checker, options ...ProbeOption) {
+	p := &probe{
+		name:     name,
+		checker:  checker,
+		critical: true,
+		retries:  -1,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	app.probes = append(app.probes, p)
+}
+
+// Boot runs the startup probes with retries within boot_timeout,
+// and logs a table of the results. A *BootError is returned if a critical
+// probe fails, unless boot_degraded is set. Only the first call works.
+func (app *App) Boot() error {
+	app.bootOnce.Do(func() {
+		app.bootReport, app.bootErr = app.boot()
+	})
+
+	return app.bootErr
+}
+
+// BootReport returns nil before Boot.
+func (app *App) BootReport() *BootReport {
+	return app.bootReport
+}
+
+func (app *App) boot() (*BootReport, error) {
+	bc := app.bootConfig()
+	report := &BootReport{Degraded: bc.Degraded}
+	if len(app.probes) == 0 {
+		return report, nil
+	}
+
+	begin := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), bc.Timeout)
+	defer cancel()
+
+	report.Results = make([]BootResult, len(app.probes))
+	wg := sync.WaitGroup{}
+	for i, p := range app.probes {
+		wg.Add(1)
+		go func(i int, p *probe) {
+			defer wg.Done()
+			report.Results[i] = p.run(ctx, bc)
+		}(i, p)
+	}
+	wg.Wait()
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].Component < report.Results[j].Component
+	})
+	report.Cost = time.Since(begin)
+
+	failed := report.Failed()
+	if len(failed) == 0 {
+		app.Logger.Infof("boot cost %v\n%s", report.Cost, report.String())
+		return report, nil
+	}
+
+	if !bc.Degraded {
+		app.Logger.Errorf("boot cost %v\n%s", report.Cost, report.String())
+		return report, &BootError{Failed: failed}
+	}
+
+	app.Logger.Warnf("boot in degraded mode, cost %v\n%s", report.Cost, report.String())
+
+	// 降级启动, 由 /readyz 报告失败的组件
+	opts := health.Options{}
+	health.Register("boot", health.CheckerFunc(func(context.Context) error {
+		return fmt.Errorf("degraded: %s", strings.Join(failed, ", "))
+	}), opts.WithCritical(false))
+
+	return report, nil
+}
+
+func (app *App) bootConfig() BootConfig {
+	bc := BootConfig{}
+	conf := app.Conf
+	if conf == nil {
+		conf = config.NewNullConfig()
+	}
+
+	if err := config.BindFrom(conf, "", &bc); err != nil {
+		app.Logger.Errorf("bind boot config: %s", err.Error())
+	}
+
+	if bc.Timeout <= 0 {
+		bc.Timeout = 30 * time.Second
+	}
+
+	return bc
+}
+
+// run retries the checker with backoff until it passes or ctx is done.
+func (p *probe) run(ctx context.Context, bc BootConfig) BootResult {
+	result := BootResult{Component: p.name, Critical: p.critical, Status: BootFail}
+
+	retries := p.retries
+	if retries < 0 {
+		retries = bc.Retries
+	}
+
+	timeout := p.timeout
+	if timeout <= 0 {
+		timeout = bc.ProbeTimeout
+	}
+
+	interval := bc.RetryInterval
+	begin := time.Now()
+	defer func() {
+		result.Latency = time.Since(begin)
+	}()
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return result
+			case <-timer.C:
+			}
+			interval *= 2
+		}
+
+		if ctx.Err() != nil {
+			if result.Attempts == 0 {
+				result.Status = BootSkipped
+				result.Error = ctx.Err().Error()
+			}
+			return result
+		}
+
+		result.Attempts++
+		err := p.check(ctx, timeout)
+		if err == nil {
+			result.Status = BootOK
+			result.Error = ""
+			return result
+		}
+
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// check runs the checker once, a blocked checker is left behind.
+func (p *probe) check(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("boot: probe panic: %v", rec)
+			}
+		}()
+		done <- p.checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errProbeTimeout
+	}
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBootApp(degraded bool) *App {
+	app := newTestApp()
+	app.Conf.Set("boot_retry_interval", "10ms")
+	app.Conf.Set("boot_timeout", "2s")
+	app.Conf.Set("boot_degraded", degraded)
+
+	return app
+}
+
+func failUntil(n int32) (health.Checker, *int32) {
+	var calls int32
+	return health.CheckerFunc(func(context.Context) error {
+		if atomic.AddInt32(&calls, 1) <= n {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), &calls
+}
+
+func TestApp_BootRetry(t *testing.T) {
+	app := newBootApp(false)
+
+	checker, calls := failUntil(2)
+	app.RegisterProbe("redis", checker)
+
+	assert.Nil(t, app.Boot())
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	report := app.BootReport()
+	assert.Len(t, report.Results, 1)
+	assert.Equal(t, BootOK, report.Results[0].Status)
+	assert.Equal(t, 3, report.Results[0].Attempts)
+	assert.Empty(t, report.Results[0].Error)
+	assert.Contains(t, report.String(), "redis")
+
+	// 只执行一次
+	assert.Nil(t, app.Boot())
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestApp_BootAbort(t *testing.T) {
+	app := newBootApp(false)
+
+	mysql, _ := failUntil(100)
+	app.RegisterProbe("mysql", mysql)
+	cache, _ := failUntil(100)
+	po := ProbeOptions{}
+	app.RegisterProbe("cache", cache, po.WithCritical(false), po.WithRetries(0))
+
+	err := app.Boot()
+	assert.NotNil(t, err)
+	bootErr, ok := err.(*BootError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"mysql"}, bootErr.Failed)
+
+	results := app.BootReport().Results
+	assert.Equal(t, "cache", results[0].Component)
+	assert.Equal(t, 1, results[0].Attempts)
+	assert.Equal(t, "mysql", results[1].Component)
+	assert.Equal(t, 4, results[1].Attempts)
+	assert.Equal(t, "connection refused", results[1].Error)
+
+	assert.Panics(t, app.Start)
+	assert.Equal(t, err, app.Run())
+}
+
+func TestApp_BootDegraded(t *testing.T) {
+	app := newBootApp(true)
+	defer health.Unregister("boot")
+
+	mysql, _ := failUntil(100)
+	po := ProbeOptions{}
+	app.RegisterProbe("mysql", mysql, po.WithRetries(1))
+
+	assert.Nil(t, app.Boot())
+	assert.True(t, app.BootReport().Degraded)
+	assert.Equal(t, []string{"mysql"}, app.BootReport().Failed())
+
+	result, err := health.Default().CheckOne(context.Background(), "boot")
+	assert.Nil(t, err)
+	assert.Equal(t, health.StatusFail, result.Status)
+	assert.False(t, result.Critical)
+}
+
+func TestApp_BootTimeout(t *testing.T) {
+	app := newBootApp(false)
+	app.Conf.Set("boot_timeout", "100ms")
+
+	po := ProbeOptions{}
+	app.RegisterProbe("mongodb", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), po.WithTimeout(time.Second))
+
+	begin := time.Now()
+	assert.NotNil(t, app.Boot())
+	assert.True(t, time.Since(begin) < time.Second)
+
+	result := app.BootReport().Results[0]
+	assert.Equal(t, BootFail, result.Status)
+	assert.Equal(t, 1, result.Attempts)
+}
+
+func TestApp_BootPanic(t *testing.T) {
+	app := newBootApp(false)
+
+	po := ProbeOptions{}
+	app.RegisterProbe("rocketmq", health.CheckerFunc(func(context.Context) error {
+		panic("dial failed")
+	}), po.WithRetries(0))
+
+	assert.NotNil(t, app.Boot())
+	assert.Contains(t, app.BootReport().Results[0].Error, "dial failed")
+}
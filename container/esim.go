@@ -1,11 +1,15 @@
 package container
 
 import (
+	"context"
 	"sync"
 
+	// mount /buildinfo, /config, /goroutines and pprof on the metrics server
+	_ "github.com/Hyingerrr/mirco-esim/core/admin"
 	"github.com/Hyingerrr/mirco-esim/core/metrics"
 
 	"github.com/Hyingerrr/mirco-esim/core/tracer"
+	"github.com/Hyingerrr/mirco-esim/core/xenv"
 
 	"github.com/Hyingerrr/mirco-esim/config"
 	"github.com/Hyingerrr/mirco-esim/log"
@@ -41,8 +45,13 @@ var esimSet = wire.NewSet(
 	provideAppName,
 )
 
+// provideConf also makes the conf default for the package-level functions,
+// and sets the run mode by runmode.
 func provideConf() config.Config {
-	return confFunc()
+	conf := confFunc()
+	config.SetDefault(conf)
+	xenv.SetRunMode(conf.GetString("runmode"))
+	return conf
 }
 
 func providePrometheus() *metrics.Prometheus {
@@ -50,7 +59,9 @@ func providePrometheus() *metrics.Prometheus {
 }
 
 func provideLogger(conf config.Config) log.Logger {
-	return loggerFunc(conf)
+	logger := loggerFunc(conf)
+	log.SetDefault(logger)
+	return logger
 }
 
 func provideTracer() *tracer.EsimTracer {
@@ -70,10 +81,22 @@ func NewEsim() *Esim {
 	return onceEsim
 }
 
+// Shutdown stops the admin server which serves /metrics.
+func (e *Esim) Shutdown(ctx context.Context) error {
+	return e.prometheus.Shutdown(ctx)
+}
+
 func (e *Esim) String() string {
 	return "Esim 基础框架;"
 }
 
 func AppName() string {
+	if onceEsim == nil {
+		if conf := config.Default(); conf != nil {
+			return conf.GetString("appname")
+		}
+		return ""
+	}
+
 	return onceEsim.AppName
 }
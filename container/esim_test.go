@@ -3,6 +3,9 @@ package container
 import (
 	"testing"
 
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/xenv"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,3 +17,21 @@ func TestNewEsim(t *testing.T) {
 	assert.NotNil(t, esim.Tracer)
 	assert.NotNil(t, esim.prometheus)
 }
+
+func TestProvideConf_RunMode(t *testing.T) {
+	oldConfFunc := confFunc
+	t.Cleanup(config.ReplaceDefault(config.Default()))
+	t.Cleanup(func() {
+		confFunc = oldConfFunc
+		xenv.SetRunMode("")
+	})
+
+	SetConfFunc(func() config.Config {
+		conf := config.NewMemConfig()
+		conf.Set("runmode", "qa")
+		return conf
+	})
+
+	provideConf()
+	assert.True(t, xenv.IsQa())
+}
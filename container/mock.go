@@ -11,6 +11,7 @@ import (
 func provideMockConf() config.Config {
 	conf := config.NewMemConfig()
 	conf.Set("debug", true)
+	config.SetDefault(conf)
 	return conf
 }
 
@@ -0,0 +1,222 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+)
+
+// DefaultName is the instance built from the top-level config keys.
+const DefaultName = "default"
+
+// Factory builds the named instance of a kind, e.g. the "cache" redis cluster.
+type Factory func(name string) (interface{}, error)
+
+type instance struct {
+	key string
+
+	mu    sync.Mutex
+	built bool
+	val   interface{}
+}
+
+// Registry builds the named instances lazily, caches them,
+// and closes them in reverse order of creation.
+type Registry struct {
+	mu sync.Mutex
+
+	factories map[string]Factory
+
+	instances map[string]*instance
+
+	// 创建顺序, 关闭时逆序; 与 Close 并发的 Get 创建的实例也记在这里, 由下次 Close 关闭
+	created []*instance
+
+	// 为 nil 时不注册 checker
+	health *health.Registry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+		instances: make(map[string]*instance),
+	}
+}
+
+// Provide sets the factory of kind, the cached instances are kept.
+func (r *Registry) Provide(kind string, factory Factory) {
+	r.mu.Lock()
+	r.factories[kind] = factory
+	r.mu.Unlock()
+}
+
+// SetHealth registers a checker named <kind>/<name> in h for each built instance
+// with Ping() error or Ping() []error, e.g. "redis/default",
+// the checkers are unregistered by Close.
+func (r *Registry) SetHealth(h *health.Registry) {
+	r.mu.Lock()
+	r.health = h
+	r.mu.Unlock()
+}
+
+// Get returns the cached instance, or builds it by the factory of kind.
+// A failed build is not cached, so the next Get retries,
+// a panic in the factory is returned as error.
+func (r *Registry) Get(kind, name string) (interface{}, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	key := kind + "/" + name
+
+	r.mu.Lock()
+	factory, ok := r.factories[kind]
+	if !ok {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("container: no factory of %s", kind)
+	}
+
+	ins, ok := r.instances[key]
+	if !ok {
+		ins = &instance{key: key}
+		r.instances[key] = ins
+	}
+	r.mu.Unlock()
+
+	ins.mu.Lock()
+	defer ins.mu.Unlock()
+
+	if ins.built {
+		return ins.val, nil
+	}
+
+	val, err := build(factory, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ins.val, ins.built = val, true
+
+	r.mu.Lock()
+	r.created = append(r.created, ins)
+	h := r.health
+	r.mu.Unlock()
+
+	if h != nil {
+		registerChecker(h, key, val)
+	}
+
+	return val, nil
+}
+
+func registerChecker(h *health.Registry, key string, val interface{}) {
+	switch pinger := val.(type) {
+	case interface{ Ping() error }:
+		h.Register(key, health.PingChecker(pinger.Ping))
+	case interface{ Ping() []error }:
+		h.Register(key, health.ErrorsChecker(pinger.Ping))
+	}
+}
+
+func build(factory Factory, name string) (val interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("container: build %s: %v", name, rec)
+		}
+	}()
+
+	return factory(name)
+}
+
+// MustGet panics if the instance can not be built.
+func (r *Registry) MustGet(kind, name string) interface{} {
+	val, err := r.Get(kind, name)
+	if err != nil {
+		panic(err)
+	}
+
+	return val
+}
+
+// Names returns the sorted names of the built instances of kind.
+func (r *Registry) Names(kind string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, ins := range r.created {
+		if strings.HasPrefix(ins.key, kind+"/") && !seen[ins.key] {
+			seen[ins.key] = true
+			names = append(names, strings.TrimPrefix(ins.key, kind+"/"))
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Close closes the built instances in reverse order of creation,
+// the instances implementing io.Closer or Closer are closed.
+// The instances are built again by Get after Close.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	created := r.created
+	r.created = nil
+	r.instances = make(map[string]*instance)
+	h := r.health
+	r.mu.Unlock()
+
+	var errs []string
+	for i := len(created) - 1; i >= 0; i-- {
+		ins := created[i]
+		if h != nil {
+			h.Unregister(ins.key)
+		}
+
+		switch closer := ins.val.(type) {
+		case io.Closer:
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", ins.key, err.Error()))
+			}
+		case Closer:
+			closer.Close()
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("container: close instances: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+var _registry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.SetHealth(health.Default())
+
+	return r
+}
+
+// Instances returns the registry used by the client packages,
+// the built clients are checked by /readyz, it is closed by App at shutdown.
+func Instances() *Registry {
+	return _registry
+}
+
+func Provide(kind string, factory Factory) {
+	_registry.Provide(kind, factory)
+}
+
+func Get(kind, name string) (interface{}, error) {
+	return _registry.Get(kind, name)
+}
+
+func MustGet(kind, name string) interface{} {
+	return _registry.MustGet(kind, name)
+}
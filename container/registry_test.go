@@ -0,0 +1,185 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClient struct {
+	name   string
+	closed *[]string
+}
+
+func (f *fakeClient) Close() error {
+	*f.closed = append(*f.closed, f.name)
+	return nil
+}
+
+func TestRegistry_Get(t *testing.T) {
+	var (
+		builds int32
+		closed []string
+	)
+
+	r := NewRegistry()
+	r.Provide("redis", func(name string) (interface{}, error) {
+		atomic.AddInt32(&builds, 1)
+		return &fakeClient{name: name, closed: &closed}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "cache", r.MustGet("redis", "cache").(*fakeClient).name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), builds)
+
+	def, err := r.Get("redis", "")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultName, def.(*fakeClient).name)
+	assert.Equal(t, []string{"cache", DefaultName}, r.Names("redis"))
+
+	_, err = r.Get("mongodb", "default")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, r.Close())
+	assert.Equal(t, []string{DefaultName, "cache"}, closed)
+	assert.Empty(t, r.Names("redis"))
+
+	// built again after Close
+	r.MustGet("redis", "cache")
+	assert.Equal(t, int32(3), builds)
+}
+
+func TestRegistry_Error(t *testing.T) {
+	var builds int
+	r := NewRegistry()
+	r.Provide("mysql", func(name string) (interface{}, error) {
+		builds++
+		if name == "bad" {
+			return nil, errors.New("dsn is empty")
+		}
+		panic("can not connect")
+	})
+
+	_, err := r.Get("mysql", "bad")
+	assert.EqualError(t, err, "dsn is empty")
+	// retry after failure
+	_, err = r.Get("mysql", "bad")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, builds)
+
+	_, err = r.Get("mysql", "panic")
+	assert.Contains(t, err.Error(), "can not connect")
+	assert.Panics(t, func() { r.MustGet("mysql", "panic") })
+
+	closer := 0
+	r.Provide("mq", func(string) (interface{}, error) {
+		return CloserFunc(func() { closer++ }), nil
+	})
+	r.MustGet("mq", "order")
+	assert.Nil(t, r.Close())
+	assert.Equal(t, 1, closer)
+}
+
+type fakePinger struct {
+	err error
+}
+
+func (f *fakePinger) Ping() []error {
+	if f.err != nil {
+		return []error{f.err}
+	}
+	return nil
+}
+
+func TestRegistry_Health(t *testing.T) {
+	h := health.NewRegistry()
+	pinger := &fakePinger{}
+
+	r := NewRegistry()
+	r.SetHealth(h)
+	r.Provide("mysql", func(name string) (interface{}, error) {
+		return pinger, nil
+	})
+	r.Provide("fake", func(name string) (interface{}, error) {
+		return &fakeClient{name: name, closed: &[]string{}}, nil
+	})
+
+	r.MustGet("mysql", "")
+	r.MustGet("fake", "")
+	assert.Equal(t, []string{"mysql/default"}, h.Names())
+	assert.True(t, h.Ready(context.Background()).OK())
+
+	pinger.err = errors.New("ping error")
+	assert.False(t, h.Ready(context.Background()).OK())
+
+	assert.Nil(t, r.Close())
+	assert.Empty(t, h.Names())
+}
+
+func TestRegistry_GetClose(t *testing.T) {
+	var built, closed int32
+
+	r := NewRegistry()
+	r.Provide("redis", func(name string) (interface{}, error) {
+		atomic.AddInt32(&built, 1)
+		return CloserFunc(func() { atomic.AddInt32(&closed, 1) }), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := r.Get("redis", "")
+				assert.Nil(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.Nil(t, r.Close())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 每个创建的实例都关闭一次
+	assert.Nil(t, r.Close())
+	assert.Equal(t, atomic.LoadInt32(&built), atomic.LoadInt32(&closed))
+
+	// Close 发生在构建过程中, 实例由下次 Close 关闭
+	building, release := make(chan struct{}), make(chan struct{})
+	r.Provide("mysql", func(name string) (interface{}, error) {
+		close(building)
+		<-release
+		return CloserFunc(func() { atomic.AddInt32(&closed, 1) }), nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.MustGet("mysql", "")
+	}()
+	<-building
+	assert.Nil(t, r.Close())
+	close(release)
+	<-done
+
+	before := atomic.LoadInt32(&closed)
+	assert.Nil(t, r.Close())
+	assert.Equal(t, before+1, atomic.LoadInt32(&closed))
+}
@@ -0,0 +1,242 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned when the breaker rejects a call.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type Config struct {
+	// 滚动窗口, 分成 Buckets 个桶
+	Window  time.Duration `mapstructure:"breaker_window" default:"10s"`
+	Buckets int           `mapstructure:"breaker_buckets" default:"10" validate:"gte=1"`
+	// 窗口内请求数不足时不熔断
+	MinRequests int `mapstructure:"breaker_min_requests" default:"20" validate:"gte=1"`
+	// 失败率达到时熔断
+	ErrorRate float64 `mapstructure:"breaker_error_rate" default:"0.5" validate:"gt=0,lte=1"`
+	// 超过 SlowCall 的请求为慢请求, 慢请求比例达到 SlowCallRate 时熔断; 0 不检查
+	SlowCall     time.Duration `mapstructure:"breaker_slow_call"`
+	SlowCallRate float64       `mapstructure:"breaker_slow_call_rate" default:"0.5" validate:"gt=0,lte=1"`
+	// 熔断多久后进入半开
+	OpenTimeout time.Duration `mapstructure:"breaker_open_timeout" default:"5s"`
+	// 半开时放行的探测请求数, 全部成功后恢复
+	HalfOpenRequests int `mapstructure:"breaker_half_open_requests" default:"5" validate:"gte=1"`
+}
+
+type bucket struct {
+	epoch    int64
+	total    int
+	failures int
+	slow     int
+}
+
+// Breaker trips on the error rate or the slow call rate of the rolling window.
+// After OpenTimeout it lets HalfOpenRequests calls probe the downstream,
+// one failure opens it again.
+type Breaker struct {
+	name string
+	conf Config
+
+	mu      sync.Mutex
+	state   State
+	buckets []bucket
+	// 状态变化后, 之前放行的请求结果不再统计
+	generation int64
+	openedAt   time.Time
+	// 半开时已放行和已成功的探测
+	probes    int
+	successes int
+	lastUsed  time.Time
+
+	now           func() time.Time
+	onStateChange func(name string, from, to State)
+}
+
+func newBreaker(name string, conf Config, now func() time.Time, onStateChange func(string, State, State)) *Breaker {
+	return &Breaker{
+		name:          name,
+		conf:          conf,
+		buckets:       make([]bucket, conf.Buckets),
+		lastUsed:      now(),
+		now:           now,
+		onStateChange: onStateChange,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkOpenTimeout(b.now())
+
+	return b.state
+}
+
+// Allow returns ErrOpen if the call is rejected,
+// otherwise the caller must report the result by done.
+func (b *Breaker) Allow() (done func(failed bool, cost time.Duration), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.lastUsed = now
+	b.checkOpenTimeout(now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.conf.HalfOpenRequests {
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(failed bool, cost time.Duration) {
+		b.report(generation, failed, cost)
+	}, nil
+}
+
+// idle reports whether the breaker is closed and not used over timeout.
+func (b *Breaker) idle(now time.Time, timeout time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == StateClosed && now.Sub(b.lastUsed) > timeout
+}
+
+func (b *Breaker) report(generation int64, failed bool, cost time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	slow := b.conf.SlowCall > 0 && cost >= b.conf.SlowCall
+	now := b.now()
+
+	switch b.state {
+	case StateClosed:
+		bkt := b.bucket(now)
+		bkt.total++
+		if failed {
+			bkt.failures++
+		}
+		if slow {
+			bkt.slow++
+		}
+
+		if b.shouldTrip(now) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if failed || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.conf.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+func (b *Breaker) checkOpenTimeout(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes, b.successes = 0, 0
+
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+
+	if b.onStateChange != nil {
+		b.onStateChange(b.name, from, state)
+	}
+}
+
+func (b *Breaker) bucketSize() time.Duration {
+	size := b.conf.Window / time.Duration(len(b.buckets))
+	if size <= 0 {
+		size = time.Millisecond
+	}
+
+	return size
+}
+
+// bucket returns the bucket of now, the stale bucket is reset.
+func (b *Breaker) bucket(now time.Time) *bucket {
+	epoch := now.UnixNano() / int64(b.bucketSize())
+	bkt := &b.buckets[epoch%int64(len(b.buckets))]
+	if bkt.epoch != epoch {
+		*bkt = bucket{epoch: epoch}
+	}
+
+	return bkt
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	var total, failures, slow int
+
+	epoch := now.UnixNano() / int64(b.bucketSize())
+	for _, bkt := range b.buckets {
+		if epoch-bkt.epoch < int64(len(b.buckets)) {
+			total += bkt.total
+			failures += bkt.failures
+			slow += bkt.slow
+		}
+	}
+
+	if total < b.conf.MinRequests {
+		return false
+	}
+
+	if float64(failures)/float64(total) >= b.conf.ErrorRate {
+		return true
+	}
+
+	return b.conf.SlowCall > 0 && float64(slow)/float64(total) >= b.conf.SlowCallRate
+}
@@ -0,0 +1,158 @@
+package breaker
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger()
+	os.Exit(m.Run())
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func newTestBreaker(conf Config) (*Breaker, *fakeClock, *[]State) {
+	var states []State
+
+	g := NewGroup(GroupOptions{}.WithConfig(conf), GroupOptions{}.WithStateChange(func(name string, from, to State) {
+		states = append(states, to)
+	}))
+
+	b := g.Get("order")
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	b.now = clock.Now
+
+	return b, clock, &states
+}
+
+func call(t *testing.T, b *Breaker, failed bool, cost time.Duration) {
+	done, err := b.Allow()
+	assert.Nil(t, err)
+	done(failed, cost)
+}
+
+func TestBreaker_ErrorRate(t *testing.T) {
+	b, clock, states := newTestBreaker(Config{MinRequests: 10, ErrorRate: 0.5, OpenTimeout: time.Second, HalfOpenRequests: 2})
+
+	for i := 0; i < 5; i++ {
+		call(t, b, false, 0)
+	}
+	for i := 0; i < 4; i++ {
+		call(t, b, true, 0)
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	// 第10个请求, 失败率达到 0.5
+	call(t, b, true, 0)
+	assert.Equal(t, StateOpen, b.State())
+
+	_, err := b.Allow()
+	assert.Equal(t, ErrOpen, err)
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	// 半开只放行两个探测
+	done1, err := b.Allow()
+	assert.Nil(t, err)
+	done2, err := b.Allow()
+	assert.Nil(t, err)
+	_, err = b.Allow()
+	assert.Equal(t, ErrOpen, err)
+
+	done1(false, 0)
+	done2(true, 0)
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.now = clock.now.Add(time.Second)
+	call(t, b, false, 0)
+	call(t, b, false, 0)
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}, *states)
+}
+
+func TestBreaker_SlowCall(t *testing.T) {
+	b, _, _ := newTestBreaker(Config{MinRequests: 4, SlowCall: 100 * time.Millisecond, SlowCallRate: 0.5})
+
+	call(t, b, false, 10*time.Millisecond)
+	call(t, b, false, 10*time.Millisecond)
+	call(t, b, false, 200*time.Millisecond)
+	assert.Equal(t, StateClosed, b.State())
+
+	call(t, b, false, 200*time.Millisecond)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_RollingWindow(t *testing.T) {
+	b, clock, _ := newTestBreaker(Config{Window: 10 * time.Second, Buckets: 10, MinRequests: 4, ErrorRate: 0.5})
+
+	call(t, b, true, 0)
+	call(t, b, true, 0)
+	call(t, b, true, 0)
+
+	// 之前的失败滑出窗口
+	clock.now = clock.now.Add(11 * time.Second)
+	call(t, b, false, 0)
+	call(t, b, false, 0)
+	call(t, b, false, 0)
+	call(t, b, true, 0)
+	assert.Equal(t, StateClosed, b.State())
+
+	call(t, b, true, 0)
+	call(t, b, true, 0)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_StaleReport(t *testing.T) {
+	b, clock, _ := newTestBreaker(Config{MinRequests: 1, OpenTimeout: time.Second, HalfOpenRequests: 1})
+
+	stale, err := b.Allow()
+	assert.Nil(t, err)
+
+	call(t, b, true, 0)
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	// 熔断前放行的请求不影响半开
+	stale(true, 0)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	call(t, b, false, 0)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestGroup_EvictIdle(t *testing.T) {
+	g := NewGroup(GroupOptions{}.WithConfig(Config{MinRequests: 1, ErrorRate: 0.5, OpenTimeout: time.Hour}))
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	g.now = clock.Now
+
+	idle := g.Get("idle")
+	used := g.Get("used")
+	opened := g.Get("opened")
+	call(t, opened, true, 0)
+	assert.Equal(t, StateOpen, opened.State())
+
+	clock.now = clock.now.Add(idleTimeout + time.Second)
+	call(t, used, false, 0)
+
+	// 创建新熔断器时回收闲置的关闭熔断器, 打开的保留
+	g.Get("new")
+	assert.NotSame(t, idle, g.Get("idle"))
+	assert.Same(t, opened, g.Get("opened"))
+	assert.Same(t, used, g.Get("used"))
+}
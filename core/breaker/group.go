@@ -0,0 +1,165 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	"github.com/Hyingerrr/mirco-esim/core/metrics"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+)
+
+var (
+	// 0 closed, 1 open, 2 half-open
+	_breakerState = metrics.CreateMetricGauge(
+		"circuit_breaker_state",
+		[]string{meta.ServiceName, "breaker"}...,
+	)
+
+	_breakerRejected = metrics.CreateMetricCount(
+		"circuit_breaker_rejected_total",
+		[]string{meta.ServiceName, "breaker"}...,
+	)
+)
+
+// 闲置超过 idleTimeout 的关闭状态熔断器被回收
+const idleTimeout = 5 * time.Minute
+
+// Group holds a breaker for each name, e.g. a target and a method.
+type Group struct {
+	conf    Config
+	appName string
+
+	mu        sync.RWMutex
+	breakers  map[string]*Breaker
+	lastSweep time.Time
+
+	now func() time.Time
+
+	onStateChange func(name string, from, to State)
+}
+
+type GroupOption func(*Group)
+
+type GroupOptions struct{}
+
+// NewGroup reads the breaker_* config by default.
+func NewGroup(options ...GroupOption) *Group {
+	g := &Group{breakers: make(map[string]*Breaker), now: time.Now}
+
+	conf := config.Default()
+	if conf == nil {
+		conf = config.NewNullConfig()
+	}
+
+	if err := config.BindFrom(conf, "", &g.conf); err != nil {
+		logx.Panicf("bind breaker config: %s", err.Error())
+	}
+	g.appName = conf.GetString("appname")
+
+	for _, option := range options {
+		option(g)
+	}
+
+	return g
+}
+
+// WithConfig replaces the breaker_* config, the zero fields use the defaults.
+func (GroupOptions) WithConfig(conf Config) GroupOption {
+	return func(g *Group) {
+		def := Config{}
+		_ = config.BindFrom(config.NewNullConfig(), "", &def)
+
+		if conf.Window <= 0 {
+			conf.Window = def.Window
+		}
+		if conf.Buckets <= 0 {
+			conf.Buckets = def.Buckets
+		}
+		if conf.MinRequests <= 0 {
+			conf.MinRequests = def.MinRequests
+		}
+		if conf.ErrorRate <= 0 {
+			conf.ErrorRate = def.ErrorRate
+		}
+		if conf.SlowCallRate <= 0 {
+			conf.SlowCallRate = def.SlowCallRate
+		}
+		if conf.OpenTimeout <= 0 {
+			conf.OpenTimeout = def.OpenTimeout
+		}
+		if conf.HalfOpenRequests <= 0 {
+			conf.HalfOpenRequests = def.HalfOpenRequests
+		}
+
+		g.conf = conf
+	}
+}
+
+// WithStateChange is called with the lock of the breaker held, it should not block.
+func (GroupOptions) WithStateChange(onStateChange func(name string, from, to State)) GroupOption {
+	return func(g *Group) {
+		g.onStateChange = onStateChange
+	}
+}
+
+// Get returns the breaker of name, it is created at the first time.
+// The closed breakers idle over 5 minutes are evicted when a breaker is created.
+func (g *Group) Get(name string) *Breaker {
+	g.mu.RLock()
+	b, ok := g.breakers[name]
+	g.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if b, ok = g.breakers[name]; ok {
+		return b
+	}
+
+	now := g.now()
+	if now.Sub(g.lastSweep) > time.Minute {
+		for k, idle := range g.breakers {
+			if idle.idle(now, idleTimeout) {
+				g.evict(k)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	b = newBreaker(name, g.conf, g.now, g.stateChanged)
+	g.breakers[name] = b
+	_breakerState.Set(float64(StateClosed), g.appName, name)
+
+	return b
+}
+
+// Allow is Get(name).Allow(), the rejection is counted.
+func (g *Group) Allow(name string) (func(failed bool, cost time.Duration), error) {
+	done, err := g.Get(name).Allow()
+	if err != nil {
+		_breakerRejected.Inc(g.appName, name)
+	}
+
+	return done, err
+}
+
+// evict removes the breaker of name and its series, with g.mu held.
+func (g *Group) evict(name string) {
+	delete(g.breakers, name)
+	_breakerState.Delete(g.appName, name)
+	_breakerRejected.Delete(g.appName, name)
+}
+
+func (g *Group) stateChanged(name string, from, to State) {
+	_breakerState.Set(float64(to), g.appName, name)
+	logx.Warnf("circuit breaker %s: %s -> %s", name, from.String(), to.String())
+
+	if g.onStateChange != nil {
+		g.onStateChange(name, from, to)
+	}
+}
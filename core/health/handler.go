@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hyingerrr/mirco-esim/core/metrics"
+)
+
+func init() {
+	metrics.Handle("/healthz", Handler(_registry.Health))
+	metrics.Handle("/readyz", Handler(_registry.Ready))
+	metrics.Handle("/livez", Handler(_registry.Live))
+}
+
+// Handler serves the report as json,
+// the status code is 503 if a critical checker fails.
+func Handler(report func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := report(r.Context())
+
+		code := http.StatusOK
+		if !result.OK() {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,327 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTimeout = time.Second
+
+// Kind of a checker, a readiness checker is not run by /livez.
+type Kind int
+
+const (
+	Readiness Kind = iota
+	Liveness
+)
+
+// Status of a check or a report.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+var errTimeout = errors.New("health: check timeout")
+
+// Checker checks a dependency, e.g. mysql, redis or a downstream service.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a func to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// PingChecker adapts the Ping of redis.Client.
+func PingChecker(ping func() error) Checker {
+	return CheckerFunc(func(context.Context) error {
+		return ping()
+	})
+}
+
+// ErrorsChecker adapts the Ping of mysql.Client and mongodb.Client,
+// or infra.Infra.HealthCheck.
+func ErrorsChecker(check func() []error) Checker {
+	return CheckerFunc(func(context.Context) error {
+		errs := check()
+		if len(errs) == 0 {
+			return nil
+		}
+
+		msg := errs[0].Error()
+		for _, err := range errs[1:] {
+			msg += "; " + err.Error()
+		}
+
+		return errors.New(msg)
+	})
+}
+
+type entry struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+	kind     Kind
+}
+
+type Option func(*entry)
+
+type Options struct{}
+
+// WithTimeout limits the check, the default is 1s.
+func (Options) WithTimeout(timeout time.Duration) Option {
+	return func(e *entry) {
+		e.timeout = timeout
+	}
+}
+
+// WithCritical decides whether a failure fails the whole report,
+// a non-critical failure only makes it degraded. The default is true.
+func (Options) WithCritical(critical bool) Option {
+	return func(e *entry) {
+		e.critical = critical
+	}
+}
+
+func (Options) WithKind(kind Kind) Option {
+	return func(e *entry) {
+		e.kind = kind
+	}
+}
+
+// Result of a checker.
+type Result struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report of a group of checkers.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether no critical checker fails.
+func (r Report) OK() bool {
+	return r.Status != StatusFail
+}
+
+// Registry holds the named checkers.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	// 关闭过程中置为 false, /readyz 返回失败
+	ready int32
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]*entry),
+		ready:   1,
+	}
+}
+
+// Register adds a checker, the same name is replaced.
+func (r *Registry) Register(name string, checker Checker, options ...Option) {
+	e := &entry{
+		name:     name,
+		checker:  checker,
+		timeout:  defaultTimeout,
+		critical: true,
+		kind:     Readiness,
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	r.mu.Lock()
+	r.entries[name] = e
+	r.mu.Unlock()
+}
+
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.entries, name)
+	r.mu.Unlock()
+}
+
+// SetReady marks the service ready or not, e.g. false during the shutdown.
+func (r *Registry) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&r.ready, v)
+}
+
+func (r *Registry) IsReady() bool {
+	return atomic.LoadInt32(&r.ready) == 1
+}
+
+// Has reports whether the checker is registered.
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.entries[name]
+	return ok
+}
+
+// Names returns the sorted names of the checkers.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Health runs all checkers.
+func (r *Registry) Health(ctx context.Context) Report {
+	return r.run(ctx, func(*entry) bool { return true })
+}
+
+// Ready runs the readiness and liveness checkers,
+// it fails after SetReady(false).
+func (r *Registry) Ready(ctx context.Context) Report {
+	report := r.Health(ctx)
+	if !r.IsReady() {
+		report.Status = StatusFail
+	}
+
+	return report
+}
+
+// Live runs the liveness checkers only.
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.run(ctx, func(e *entry) bool { return e.kind == Liveness })
+}
+
+// CheckOne runs the named checker.
+func (r *Registry) CheckOne(ctx context.Context, name string) (Result, error) {
+	r.mu.RLock()
+	e, ok := r.entries[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Result{}, fmt.Errorf("health: checker %s not found", name)
+	}
+
+	return e.check(ctx), nil
+}
+
+func (r *Registry) run(ctx context.Context, filter func(*entry) bool) Report {
+	r.mu.RLock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		if filter(e) {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(entries))
+
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = e.check(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(entries))}
+	for i, e := range entries {
+		result := results[i]
+		report.Checks[e.name] = result
+
+		if result.Status == StatusOK {
+			continue
+		}
+
+		if result.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// check runs the checker within the timeout,
+// a blocked checker is left behind.
+func (e *entry) check(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("health: check panic: %v", rec)
+			}
+		}()
+		done <- e.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimeout
+	}
+
+	result := Result{
+		Status:   StatusOK,
+		Critical: e.critical,
+		Latency:  time.Since(begin).String(),
+	}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+var _registry = NewRegistry()
+
+// Default returns the registry served by the metrics server.
+func Default() *Registry {
+	return _registry
+}
+
+func Register(name string, checker Checker, options ...Option) {
+	_registry.Register(name, checker, options...)
+}
+
+func Unregister(name string) {
+	_registry.Unregister(name)
+}
+
+func SetReady(ready bool) {
+	_registry.SetReady(ready)
+}
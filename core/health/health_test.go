@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	opts := Options{}
+	r := NewRegistry()
+	r.Register("mysql", ErrorsChecker(func() []error { return nil }))
+	r.Register("redis", PingChecker(func() error { return nil }), opts.WithKind(Liveness))
+
+	report := r.Health(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Len(t, report.Checks, 2)
+
+	r.Register("cache", PingChecker(func() error { return errors.New("refused") }),
+		opts.WithCritical(false))
+	report = r.Ready(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.OK())
+	assert.Equal(t, "refused", report.Checks["cache"].Error)
+
+	r.Register("mongodb", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), opts.WithTimeout(20*time.Millisecond))
+	report = r.Health(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusFail, report.Checks["mongodb"].Status)
+
+	// liveness only
+	report = r.Live(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, []string{"redis"}, keys(report))
+
+	r.Unregister("mongodb")
+	r.SetReady(false)
+	assert.Equal(t, StatusFail, r.Ready(context.Background()).Status)
+	assert.Equal(t, StatusDegraded, r.Health(context.Background()).Status)
+
+	_, err := r.CheckOne(context.Background(), "mongodb")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"cache", "mysql", "redis"}, r.Names())
+}
+
+func TestErrorsChecker(t *testing.T) {
+	checker := ErrorsChecker(func() []error {
+		return []error{errors.New("master down"), errors.New("slave down")}
+	})
+	assert.EqualError(t, checker.Check(context.Background()), "master down; slave down")
+}
+
+func TestCheckPanic(t *testing.T) {
+	r := NewRegistry()
+	r.Register("panic", CheckerFunc(func(context.Context) error { panic("oops") }))
+
+	result, err := r.CheckOne(context.Background(), "panic")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusFail, result.Status)
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Register("mysql", PingChecker(func() error { return errors.New("refused") }))
+
+	w := httptest.NewRecorder()
+	Handler(r.Ready).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	report := Report{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, "refused", report.Checks["mysql"].Error)
+
+	w = httptest.NewRecorder()
+	Handler(r.Live).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func keys(report Report) []string {
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	return names
+}
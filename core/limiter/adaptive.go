@@ -0,0 +1,172 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type AdaptiveConfig struct {
+	// 初始并发数和上下限
+	Initial int
+	Min     int
+	Max     int
+
+	// 每 Window 按采样的平均耗时调整一次
+	Window time.Duration
+
+	// 平均耗时不超过 最小耗时*Tolerance 时不降低并发
+	Tolerance float64
+
+	// 新并发数的权重
+	Smoothing float64
+}
+
+const minRTTWindows = 10
+
+// adaptive limits the concurrency by the latency gradient:
+//
+//	gradient = clamp(minRTT * tolerance / avgRTT, 0.5, 1)
+//	limit = limit*(1-smoothing) + (limit*gradient + sqrt(limit))*smoothing
+//
+// The limit grows while the latency stays near the min,
+// and shrinks when the requests start queuing.
+// It doesn't grow while less than half of the limit is used, otherwise it drifts to Max under light load.
+// minRTT is reset every minRTTWindows windows, so it follows the drift of the latency.
+type adaptive struct {
+	conf AdaptiveConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+
+	minRTT      time.Duration
+	windowStart time.Time
+	windowMin   time.Duration
+	sum         time.Duration
+	samples     int
+	windows     int
+	peak        int
+
+	now      func() time.Time
+	onUpdate func(limit int)
+}
+
+func NewAdaptive(conf AdaptiveConfig) Limiter {
+	return newAdaptive(conf, nil)
+}
+
+func newAdaptive(conf AdaptiveConfig, onUpdate func(limit int)) *adaptive {
+	if conf.Min < 1 {
+		conf.Min = 1
+	}
+
+	if conf.Max < conf.Min {
+		conf.Max = 1000
+	}
+
+	if conf.Initial < conf.Min || conf.Initial > conf.Max {
+		conf.Initial = conf.Min
+	}
+
+	if conf.Window <= 0 {
+		conf.Window = time.Second
+	}
+
+	if conf.Tolerance < 1 {
+		conf.Tolerance = 1.5
+	}
+
+	if conf.Smoothing <= 0 || conf.Smoothing > 1 {
+		conf.Smoothing = 0.2
+	}
+
+	return &adaptive{
+		conf:     conf,
+		limit:    float64(conf.Initial),
+		now:      time.Now,
+		onUpdate: onUpdate,
+	}
+}
+
+func (a *adaptive) Allow() (Token, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.inflight >= int(a.limit) {
+		return nil, false
+	}
+	a.inflight++
+	if a.inflight > a.peak {
+		a.peak = a.inflight
+	}
+
+	start := a.now()
+	return newToken(func() {
+		a.release(a.now().Sub(start))
+	}, func() {
+		a.mu.Lock()
+		a.inflight--
+		a.mu.Unlock()
+	}), true
+}
+
+// Limit returns the current concurrency limit.
+func (a *adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return int(a.limit)
+}
+
+func (a *adaptive) release(rtt time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inflight--
+
+	if a.minRTT == 0 || rtt < a.minRTT {
+		a.minRTT = rtt
+	}
+
+	if a.windowMin == 0 || rtt < a.windowMin {
+		a.windowMin = rtt
+	}
+
+	now := a.now()
+	if a.windowStart.IsZero() {
+		a.windowStart = now
+	}
+	a.sum += rtt
+	a.samples++
+
+	if now.Sub(a.windowStart) < a.conf.Window {
+		return
+	}
+
+	avg := a.sum / time.Duration(a.samples)
+
+	a.windows++
+	if a.windows >= minRTTWindows {
+		a.minRTT, a.windows = a.windowMin, 0
+	}
+	peak := a.peak
+	a.windowStart, a.windowMin, a.sum, a.samples, a.peak = now, 0, 0, 0, a.inflight
+
+	gradient := 1.0
+	if avg > 0 {
+		gradient = math.Max(0.5, math.Min(1, float64(a.minRTT)*a.conf.Tolerance/float64(avg)))
+	}
+
+	if gradient == 1 && float64(peak) < a.limit/2 {
+		return
+	}
+
+	limit := a.limit*gradient + math.Sqrt(a.limit)
+	limit = a.limit*(1-a.conf.Smoothing) + limit*a.conf.Smoothing
+	a.limit = math.Max(float64(a.conf.Min), math.Min(float64(a.conf.Max), limit))
+
+	if a.onUpdate != nil {
+		a.onUpdate(int(a.limit))
+	}
+}
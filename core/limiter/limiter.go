@@ -0,0 +1,148 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter admits or rejects a request,
+// the token of an admitted request must be released by Done or Cancel.
+type Limiter interface {
+	Allow() (Token, bool)
+}
+
+// Token is an admitted request, only the first Done or Cancel takes effect.
+type Token interface {
+	// Done is called when the request finishes.
+	Done()
+
+	// Cancel gives back the admission of a request not run, e.g. a later rule rejects it,
+	// the adaptive limiter takes no latency sample of it.
+	Cancel()
+}
+
+type funcToken struct {
+	once   sync.Once
+	done   func()
+	cancel func()
+}
+
+func newToken(done, cancel func()) Token {
+	return &funcToken{done: done, cancel: cancel}
+}
+
+func (t *funcToken) Done() {
+	t.once.Do(func() {
+		if t.done != nil {
+			t.done()
+		}
+	})
+}
+
+func (t *funcToken) Cancel() {
+	t.once.Do(func() {
+		if t.cancel != nil {
+			t.cancel()
+		}
+	})
+}
+
+// tokenBucket adds rate tokens a second up to burst, a request takes one.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+func (tb *tokenBucket) Allow() (Token, bool) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+	if !tb.last.IsZero() {
+		tb.tokens = math.Min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	}
+	tb.last = now
+
+	if tb.tokens < 1 {
+		return nil, false
+	}
+	tb.tokens--
+
+	return newToken(nil, func() {
+		tb.mu.Lock()
+		tb.tokens = math.Min(tb.burst, tb.tokens+1)
+		tb.mu.Unlock()
+	}), true
+}
+
+// slidingWindow allows limit requests in any window, the count of the window
+// is estimated by the current and the previous fixed windows.
+type slidingWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+
+	start time.Time
+	prev  int
+	cur   int
+
+	now func() time.Time
+}
+
+func NewSlidingWindow(limit int, window time.Duration) Limiter {
+	if window <= 0 {
+		window = time.Second
+	}
+
+	return &slidingWindow{limit: limit, window: window, now: time.Now}
+}
+
+func (sw *slidingWindow) Allow() (Token, bool) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := sw.now()
+	start := now.Truncate(sw.window)
+	switch elapsed := start.Sub(sw.start); {
+	case elapsed == sw.window:
+		sw.prev, sw.cur = sw.cur, 0
+	case elapsed > sw.window:
+		sw.prev, sw.cur = 0, 0
+	}
+	sw.start = start
+
+	// 上一个窗口按重叠的比例计入
+	overlap := 1 - float64(now.Sub(start))/float64(sw.window)
+	if float64(sw.prev)*overlap+float64(sw.cur) >= float64(sw.limit) {
+		return nil, false
+	}
+	sw.cur++
+
+	return newToken(nil, func() {
+		sw.mu.Lock()
+		// 窗口已切换时不再退回
+		if sw.start.Equal(start) && sw.cur > 0 {
+			sw.cur--
+		}
+		sw.mu.Unlock()
+	}), true
+}
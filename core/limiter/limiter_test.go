@@ -0,0 +1,124 @@
+package limiter
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger()
+	os.Exit(m.Run())
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Add(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+func allowN(l Limiter, n int) int {
+	var allowed int
+	for i := 0; i < n; i++ {
+		if tok, ok := l.Allow(); ok {
+			tok.Done()
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := NewTokenBucket(10, 5).(*tokenBucket)
+	tb.now = clock.Now
+
+	// 初始为满桶
+	assert.Equal(t, 5, allowN(tb, 10))
+
+	clock.Add(300 * time.Millisecond)
+	assert.Equal(t, 3, allowN(tb, 10))
+
+	// 不超过桶容量
+	clock.Add(time.Minute)
+	assert.Equal(t, 5, allowN(tb, 10))
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	sw := NewSlidingWindow(10, time.Second).(*slidingWindow)
+	sw.now = clock.Now
+
+	assert.Equal(t, 10, allowN(sw, 20))
+
+	// 上一个窗口还有 70% 重叠, 计 7 个
+	clock.Add(1300 * time.Millisecond)
+	assert.Equal(t, 3, allowN(sw, 20))
+
+	// 跳过一个窗口后重新计数
+	clock.Add(2 * time.Second)
+	assert.Equal(t, 10, allowN(sw, 20))
+}
+
+func TestAdaptive(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+
+	var updates []int
+	a := newAdaptive(AdaptiveConfig{Initial: 10, Min: 2, Max: 100, Window: time.Second}, func(limit int) {
+		updates = append(updates, limit)
+	})
+	a.now = clock.Now
+
+	// 并发达到上限后拒绝
+	var toks []Token
+	for i := 0; i < 10; i++ {
+		tok, ok := a.Allow()
+		assert.True(t, ok)
+		toks = append(toks, tok)
+	}
+	_, ok := a.Allow()
+	assert.False(t, ok)
+
+	// 耗时平稳且并发用满, 并发数增长
+	clock.Add(10 * time.Millisecond)
+	for _, tok := range toks {
+		tok.Done()
+		tok.Done()
+	}
+	run := func(cost time.Duration, concurrency, n int) {
+		for i := 0; i < n; i++ {
+			toks = toks[:0]
+			for j := 0; j < concurrency; j++ {
+				if tok, ok := a.Allow(); ok {
+					toks = append(toks, tok)
+				}
+			}
+			clock.Add(cost)
+			for _, tok := range toks {
+				tok.Done()
+			}
+		}
+	}
+	run(10*time.Millisecond, 1, 200)
+	assert.Equal(t, 10, a.Limit())
+
+	run(10*time.Millisecond, 100, 300)
+	assert.Greater(t, a.Limit(), 10)
+	assert.Equal(t, a.Limit(), updates[len(updates)-1])
+
+	// 耗时上升, 并发数下降
+	grown := a.Limit()
+	run(100*time.Millisecond, 100, 50)
+	assert.Less(t, a.Limit(), grown)
+	assert.GreaterOrEqual(t, a.Limit(), 2)
+}
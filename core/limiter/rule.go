@@ -0,0 +1,348 @@
+package limiter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	"github.com/Hyingerrr/mirco-esim/core/metrics"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+)
+
+// 限流的维度
+const (
+	KeyMethod = "method"
+	KeyAppID  = meta.AppID
+	KeyMerID  = meta.MerID
+)
+
+const (
+	TypeTokenBucket   = "token_bucket"
+	TypeSlidingWindow = "sliding_window"
+	TypeAdaptive      = "adaptive"
+)
+
+// 按 key 的值创建的限流器, 闲置超过 idleTimeout 后回收
+const idleTimeout = 5 * time.Minute
+
+var (
+	_limiterRejected = metrics.CreateMetricCount(
+		"limiter_rejected_total",
+		[]string{meta.ServiceName, meta.Uri, "rule"}...,
+	)
+
+	_limiterConcurrency = metrics.CreateMetricGauge(
+		"limiter_concurrency_limit",
+		[]string{meta.ServiceName, "rule", "key"}...,
+	)
+)
+
+// Rule is an item of limiter_rules, e.g.
+//
+//	limiter_rules:
+//	  - match: /order.Order/*
+//	    key: merid
+//	    type: token_bucket
+//	    rate: 10
+//	    burst: 20
+//	  - match: "*"
+//	    type: adaptive
+//	    max_concurrency: 500
+type Rule struct {
+	// 默认 type:match:key
+	Name string `mapstructure:"name"`
+
+	// grpc 的完整方法名或 http 的路由, 如 /users/:id; /order.Order/* 前缀匹配, * 匹配所有
+	Match string `mapstructure:"match" validate:"required"`
+
+	// 每个 key 的值各自限流, 默认 method; 请求中没有该值时不受此规则限制
+	Key string `mapstructure:"key" validate:"omitempty,oneof=method appid merid"`
+
+	Type string `mapstructure:"type" validate:"oneof=token_bucket sliding_window adaptive"`
+
+	// token_bucket: 每秒的令牌数和桶容量
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+
+	// sliding_window: window 内最多 limit 个请求, 如 1s
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+
+	// adaptive: 并发数的初始值和上下限
+	InitialConcurrency int `mapstructure:"initial_concurrency"`
+	MinConcurrency     int `mapstructure:"min_concurrency"`
+	MaxConcurrency     int `mapstructure:"max_concurrency"`
+}
+
+type rulesConfig struct {
+	Rules []Rule `mapstructure:"limiter_rules" validate:"dive"`
+}
+
+// Request is what the rules match and key by.
+type Request struct {
+	// grpc 的完整方法名或 http 的路由
+	Method string
+	AppID  string
+	MerID  string
+}
+
+type entry struct {
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+type rule struct {
+	Rule
+
+	appName string
+
+	mu        sync.Mutex
+	limiters  map[string]*entry
+	lastSweep time.Time
+}
+
+func (r Rule) check() error {
+	if r.Match == "" {
+		return fmt.Errorf("limiter rule %s: match is empty", r.Name)
+	}
+
+	switch r.Key {
+	case "", KeyMethod, KeyAppID, KeyMerID:
+	default:
+		return fmt.Errorf("limiter rule %s: invalid key %s", r.Name, r.Key)
+	}
+
+	switch r.Type {
+	case TypeTokenBucket:
+		if r.Rate <= 0 {
+			return fmt.Errorf("limiter rule %s: rate should be positive", r.Name)
+		}
+	case TypeSlidingWindow:
+		if r.Limit <= 0 {
+			return fmt.Errorf("limiter rule %s: limit should be positive", r.Name)
+		}
+	case TypeAdaptive:
+	default:
+		return fmt.Errorf("limiter rule %s: invalid type %s", r.Name, r.Type)
+	}
+
+	return nil
+}
+
+func (r *rule) match(method string) bool {
+	switch {
+	case r.Match == "*":
+		return true
+	case strings.HasSuffix(r.Match, "*"):
+		return strings.HasPrefix(method, strings.TrimSuffix(r.Match, "*"))
+	default:
+		return r.Match == method
+	}
+}
+
+func (r *rule) keyOf(req Request) string {
+	switch r.Key {
+	case KeyAppID:
+		return req.AppID
+	case KeyMerID:
+		return req.MerID
+	default:
+		return req.Method
+	}
+}
+
+func (r *rule) limiter(key string) Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastSweep) > time.Minute {
+		for k, e := range r.limiters {
+			if now.Sub(e.lastUsed) > idleTimeout {
+				r.evict(k)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	e, ok := r.limiters[key]
+	if !ok {
+		e = &entry{limiter: r.newLimiter(key)}
+		r.limiters[key] = e
+	}
+	e.lastUsed = now
+
+	return e.limiter
+}
+
+// evict removes the limiter of key and its concurrency series, with r.mu held.
+func (r *rule) evict(key string) {
+	delete(r.limiters, key)
+	if r.Type == TypeAdaptive {
+		_limiterConcurrency.Delete(r.appName, r.Name, key)
+	}
+}
+
+// close removes the series of the limiters when the rule is replaced.
+func (r *rule) close() {
+	r.mu.Lock()
+	for k := range r.limiters {
+		r.evict(k)
+	}
+	r.mu.Unlock()
+}
+
+func (r *rule) newLimiter(key string) Limiter {
+	switch r.Type {
+	case TypeTokenBucket:
+		return NewTokenBucket(r.Rate, r.Burst)
+	case TypeSlidingWindow:
+		return NewSlidingWindow(r.Limit, r.Window)
+	default:
+		a := newAdaptive(AdaptiveConfig{
+			Initial: r.InitialConcurrency,
+			Min:     r.MinConcurrency,
+			Max:     r.MaxConcurrency,
+		}, func(limit int) {
+			_limiterConcurrency.Set(float64(limit), r.appName, r.Name, key)
+		})
+		_limiterConcurrency.Set(float64(a.conf.Initial), r.appName, r.Name, key)
+
+		return a
+	}
+}
+
+// Manager admits the requests by the rules, every matched rule must admit.
+type Manager struct {
+	appName string
+
+	mu    sync.RWMutex
+	rules []*rule
+}
+
+type ManagerOption func(*Manager)
+
+type ManagerOptions struct{}
+
+// NewManager reads limiter_rules, and follows the changes of it if the config supports hot reload.
+// It panics if the rules are invalid.
+func NewManager(options ...ManagerOption) *Manager {
+	m := &Manager{}
+	if config.Default() != nil {
+		m.appName = config.GetString("appname")
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	if m.rules != nil || config.Default() == nil {
+		return m
+	}
+
+	if err := m.reload(); err != nil {
+		logx.Panicf("%s", err.Error())
+	}
+
+	config.OnChange("limiter_rules", func(old, new interface{}) {
+		// 新规则有误时保留旧规则
+		if err := m.reload(); err != nil {
+			logx.Errorf("reload %s", err.Error())
+			return
+		}
+		logx.Infof("limiter rules reloaded")
+	})
+
+	return m
+}
+
+// WithRules is used instead of limiter_rules.
+func (ManagerOptions) WithRules(rules ...Rule) ManagerOption {
+	return func(m *Manager) {
+		if err := m.Update(rules); err != nil {
+			logx.Panicf("%s", err.Error())
+		}
+	}
+}
+
+func (m *Manager) reload() error {
+	rc := rulesConfig{}
+	if err := config.Bind("", &rc); err != nil {
+		return fmt.Errorf("limiter rules: %s", err.Error())
+	}
+
+	return m.Update(rc.Rules)
+}
+
+// Update replaces the rules, the state of the limiters is reset.
+func (m *Manager) Update(rules []Rule) error {
+	compiled := make([]*rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Key == "" {
+			r.Key = KeyMethod
+		}
+
+		if r.Name == "" {
+			r.Name = r.Type + ":" + r.Match + ":" + r.Key
+		}
+
+		if err := r.check(); err != nil {
+			return err
+		}
+
+		compiled = append(compiled, &rule{Rule: r, appName: m.appName, limiters: make(map[string]*entry)})
+	}
+
+	m.mu.Lock()
+	old := m.rules
+	m.rules = compiled
+	m.mu.Unlock()
+
+	for _, r := range old {
+		r.close()
+	}
+
+	return nil
+}
+
+// Allow returns the name of the rule which rejects the request,
+// otherwise done must be called when the request finishes.
+func (m *Manager) Allow(req Request) (done func(), rejectedBy string, ok bool) {
+	m.mu.RLock()
+	rules := m.rules
+	m.mu.RUnlock()
+
+	var tokens []Token
+	release := func() {
+		for _, tok := range tokens {
+			tok.Done()
+		}
+	}
+
+	for _, r := range rules {
+		if !r.match(req.Method) {
+			continue
+		}
+
+		key := r.keyOf(req)
+		if key == "" {
+			continue
+		}
+
+		tok, ok := r.limiter(key).Allow()
+		if !ok {
+			// 请求没有执行, 不计入前面规则的耗时
+			for _, t := range tokens {
+				t.Cancel()
+			}
+			_limiterRejected.Inc(m.appName, req.Method, r.Name)
+			return nil, r.Name, false
+		}
+		tokens = append(tokens, tok)
+	}
+
+	return release, "", true
+}
@@ -0,0 +1,179 @@
+package limiter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Allow(t *testing.T) {
+	m := NewManager(ManagerOptions{}.WithRules(
+		Rule{Match: "/order.Order/*", Key: KeyMerID, Type: TypeTokenBucket, Rate: 1, Burst: 2},
+		Rule{Name: "create", Match: "/order.Order/Create", Type: TypeAdaptive, InitialConcurrency: 1, MinConcurrency: 1, MaxConcurrency: 1},
+	))
+
+	// 每个商户各自限流
+	for i := 0; i < 2; i++ {
+		done, _, ok := m.Allow(Request{Method: "/order.Order/Query", MerID: "m1"})
+		assert.True(t, ok)
+		done()
+	}
+	_, rule, ok := m.Allow(Request{Method: "/order.Order/Query", MerID: "m1"})
+	assert.False(t, ok)
+	assert.Equal(t, "token_bucket:/order.Order/*:merid", rule)
+
+	done, _, ok := m.Allow(Request{Method: "/order.Order/Query", MerID: "m2"})
+	assert.True(t, ok)
+	done()
+
+	// 没有 merid 时不受按商户的规则限制
+	for i := 0; i < 5; i++ {
+		done, _, ok = m.Allow(Request{Method: "/order.Order/Query"})
+		assert.True(t, ok)
+		done()
+	}
+
+	// 不匹配的方法不限流
+	for i := 0; i < 5; i++ {
+		done, _, ok = m.Allow(Request{Method: "/user.User/Query", MerID: "m1"})
+		assert.True(t, ok)
+		done()
+	}
+
+	// 并发数为1
+	done, _, ok = m.Allow(Request{Method: "/order.Order/Create"})
+	assert.True(t, ok)
+	_, rule, ok = m.Allow(Request{Method: "/order.Order/Create"})
+	assert.False(t, ok)
+	assert.Equal(t, "create", rule)
+	done()
+
+	done, _, ok = m.Allow(Request{Method: "/order.Order/Create"})
+	assert.True(t, ok)
+	done()
+}
+
+// 后面的规则拒绝时, 前面规则占用的并发要释放
+func TestManager_Release(t *testing.T) {
+	m := NewManager(ManagerOptions{}.WithRules(
+		Rule{Name: "concurrency", Match: "*", Type: TypeAdaptive, InitialConcurrency: 1, MinConcurrency: 1, MaxConcurrency: 1},
+		Rule{Name: "rate", Match: "*", Type: TypeSlidingWindow, Limit: 1},
+	))
+
+	done, _, ok := m.Allow(Request{Method: "/order.Order/Query"})
+	assert.True(t, ok)
+	done()
+
+	_, rule, ok := m.Allow(Request{Method: "/order.Order/Query"})
+	assert.False(t, ok)
+	assert.Equal(t, "rate", rule)
+
+	assert.Nil(t, m.Update([]Rule{{Name: "concurrency", Match: "*", Type: TypeAdaptive, InitialConcurrency: 1, MinConcurrency: 1, MaxConcurrency: 1}}))
+	done, _, ok = m.Allow(Request{Method: "/order.Order/Query"})
+	assert.True(t, ok)
+	done()
+}
+
+func TestManager_Update(t *testing.T) {
+	m := NewManager(ManagerOptions{}.WithRules())
+
+	done, _, ok := m.Allow(Request{Method: "/order.Order/Query"})
+	assert.True(t, ok)
+	done()
+
+	assert.NotNil(t, m.Update([]Rule{{Match: "*", Type: "leaky_bucket"}}))
+	assert.NotNil(t, m.Update([]Rule{{Match: "*", Type: TypeTokenBucket}}))
+	assert.NotNil(t, m.Update([]Rule{{Match: "*", Key: "ip", Type: TypeSlidingWindow, Limit: 1}}))
+
+	assert.Nil(t, m.Update([]Rule{{Match: "/order.Order/Query", Type: TypeSlidingWindow, Limit: 1}}))
+	done, _, ok = m.Allow(Request{Method: "/order.Order/Query"})
+	assert.True(t, ok)
+	done()
+	_, _, ok = m.Allow(Request{Method: "/order.Order/Query"})
+	assert.False(t, ok)
+}
+
+// concurrencyKeys returns the key labels of the concurrency series of rule.
+func concurrencyKeys(t *testing.T, rule string) []string {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	assert.Nil(t, err)
+
+	keys := make([]string, 0)
+	for _, mf := range mfs {
+		if !strings.HasSuffix(mf.GetName(), "limiter_concurrency_limit") {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if labels["rule"] == rule {
+				keys = append(keys, labels["key"])
+			}
+		}
+	}
+
+	return keys
+}
+
+func TestManager_EvictSeries(t *testing.T) {
+	m := NewManager(ManagerOptions{}.WithRules(
+		Rule{Name: "per_app", Match: "*", Key: KeyAppID, Type: TypeAdaptive, MaxConcurrency: 10},
+	))
+
+	for _, appID := range []string{"a1", "a2"} {
+		done, _, ok := m.Allow(Request{Method: "/order.Order/Query", AppID: appID})
+		assert.True(t, ok)
+		done()
+	}
+	assert.ElementsMatch(t, []string{"a1", "a2"}, concurrencyKeys(t, "per_app"))
+
+	// a1 闲置后回收
+	r := m.rules[0]
+	r.mu.Lock()
+	r.limiters["a1"].lastUsed = time.Now().Add(-2 * idleTimeout)
+	r.lastSweep = time.Time{}
+	r.mu.Unlock()
+
+	done, _, ok := m.Allow(Request{Method: "/order.Order/Query", AppID: "a2"})
+	assert.True(t, ok)
+	done()
+	assert.Equal(t, []string{"a2"}, concurrencyKeys(t, "per_app"))
+
+	assert.Nil(t, m.Update(nil))
+	assert.Empty(t, concurrencyKeys(t, "per_app"))
+}
+
+// 后面的规则拒绝时, 前面的自适应规则不计入耗时, 并发数不下降
+func TestManager_CancelAdaptive(t *testing.T) {
+	m := NewManager(ManagerOptions{}.WithRules(
+		Rule{Name: "adaptive", Match: "*", Type: TypeAdaptive, InitialConcurrency: 10, MinConcurrency: 1, MaxConcurrency: 100},
+		Rule{Name: "rate", Match: "*", Type: TypeTokenBucket, Rate: 0.001, Burst: 5},
+	))
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	a := m.rules[0].limiter("/order.Order/Query").(*adaptive)
+	a.now = clock.Now
+
+	var rejected int
+	for i := 0; i < 30; i++ {
+		for j := 0; j < 5; j++ {
+			done, _, ok := m.Allow(Request{Method: "/order.Order/Query"})
+			if !ok {
+				rejected++
+				continue
+			}
+			clock.Add(10 * time.Millisecond)
+			done()
+		}
+		clock.Add(time.Second)
+	}
+
+	assert.Greater(t, rejected, 100)
+	assert.Equal(t, 10, a.Limit())
+}
@@ -66,6 +66,16 @@ func Int64(ctx context.Context, key string) int64 {
 	return i64
 }
 
+func Strings(ctx context.Context, key string) []string {
+	md, ok := ctx.Value(mdKey{}).(MD)
+	if !ok {
+		return nil
+	}
+
+	strs, _ := md[key].([]string)
+	return strs
+}
+
 func Value(ctx context.Context, key string) interface{} {
 	md, ok := ctx.Value(mdKey{}).(MD)
 	if !ok {
@@ -20,6 +20,10 @@ const (
 
 	StatusCode = "statuscode"
 
+	// mTLS 对端证书的身份, 由 grpc server 写入
+	PeerCN  = "peercn"
+	PeerSAN = "peersan" // []string, DNS/IP/URI/Email
+
 	HTTPProtocol = "restful"
 	RPCProtocol  = "gprc"
 )
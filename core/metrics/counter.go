@@ -11,6 +11,8 @@ type CounterVec interface {
 	Inc(labels ...string)
 	Add(v float64, labels ...string)
 	GetMetric(labels ...string) (prometheus.Counter, error)
+	// Delete removes the series of labels, e.g. when its key is gone
+	Delete(labels ...string) bool
 	close() bool
 }
 
@@ -45,6 +47,10 @@ func (cv *promCounterVec) Add(n float64, labels ...string) {
 	cv.counter.WithLabelValues(labels...).Add(n)
 }
 
+func (cv *promCounterVec) Delete(labels ...string) bool {
+	return cv.counter.DeleteLabelValues(labels...)
+}
+
 // close
 func (cv *promCounterVec) close() bool {
 	return prometheus.Unregister(cv.counter)
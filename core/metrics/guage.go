@@ -11,6 +11,8 @@ type GaugeVec interface {
 	Set(v float64, labels ...string)
 	Inc(labels ...string)
 	Add(v float64, labels ...string)
+	// Delete removes the series of labels, e.g. when its key is gone
+	Delete(labels ...string) bool
 	close() bool
 }
 
@@ -47,6 +49,10 @@ func (gv *promGuageVec) Set(v float64, labels ...string) {
 	gv.gauge.WithLabelValues(labels...).Set(v)
 }
 
+func (gv *promGuageVec) Delete(labels ...string) bool {
+	return gv.gauge.DeleteLabelValues(labels...)
+}
+
 func (gv *promGuageVec) close() bool {
 	return prometheus.Unregister(gv.gauge)
 }
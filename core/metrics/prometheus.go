@@ -1,8 +1,12 @@
 package metrics
 
 import (
+	"context"
+	stdlog "log"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/Hyingerrr/mirco-esim/config"
 
@@ -11,7 +15,56 @@ import (
 
 const defaultPrometheusHTTPAddr = "9002"
 
-type Prometheus struct{}
+// Prometheus is the admin http server, it serves /metrics
+// and the handlers mounted by Handle.
+type Prometheus struct {
+	server *http.Server
+
+	addr string
+}
+
+// routes serves the handlers mounted by Handle, the others fall back to http.DefaultServeMux.
+type routes struct {
+	mu sync.RWMutex
+
+	handlers map[string]http.Handler
+}
+
+var _routes = &routes{
+	handlers: map[string]http.Handler{
+		"/metrics": promhttp.Handler(),
+	},
+}
+
+// Handle mounts the handler next to /metrics, the same pattern is replaced.
+// A pattern ending with "/" matches the paths under it as http.ServeMux.
+func Handle(pattern string, handler http.Handler) {
+	_routes.mu.Lock()
+	_routes.handlers[pattern] = handler
+	_routes.mu.Unlock()
+}
+
+func (rs *routes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rs.mu.RLock()
+	handler, ok := rs.handlers[r.URL.Path]
+	if !ok {
+		// 最长前缀匹配
+		var longest string
+		for pattern, h := range rs.handlers {
+			if strings.HasSuffix(pattern, "/") && strings.HasPrefix(r.URL.Path, pattern) &&
+				len(pattern) > len(longest) {
+				longest, handler, ok = pattern, h, true
+			}
+		}
+	}
+	rs.mu.RUnlock()
+
+	if !ok {
+		handler = http.DefaultServeMux
+	}
+
+	handler.ServeHTTP(w, r)
+}
 
 type VectorOpts struct {
 	Namespace string
@@ -49,13 +102,39 @@ func NewPrometheus() *Prometheus {
 		addr = ":" + addr
 	}
 
+	p := &Prometheus{server: &http.Server{Addr: addr, Handler: _routes}}
+
+	// log 依赖 metrics, 这里只能用标准库输出
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		stdlog.Printf("metrics: listen %s: %v", addr, err)
+		return p
+	}
+	p.addr = lis.Addr().String()
+
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		err := http.ListenAndServe(addr, nil)
-		if err != nil {
-			panic(err)
+		if err := p.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			stdlog.Printf("metrics: serve %s: %v", addr, err)
 		}
 	}()
 
-	return &Prometheus{}
+	return p
+}
+
+// Addr is the listening address, empty if the server failed to listen.
+func (p *Prometheus) Addr() string {
+	if p == nil {
+		return ""
+	}
+
+	return p.addr
+}
+
+// Shutdown stops the server gracefully.
+func (p *Prometheus) Shutdown(ctx context.Context) error {
+	if p == nil || p.server == nil {
+		return nil
+	}
+
+	return p.server.Shutdown(ctx)
 }
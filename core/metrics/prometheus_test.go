@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/config/configtest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes_Prefix(t *testing.T) {
+	Handle("/debug/test/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("prefix"))
+	}))
+	Handle("/debug/test/exact", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("exact"))
+	}))
+
+	for path, want := range map[string]string{
+		"/debug/test/":      "prefix",
+		"/debug/test/heap":  "prefix",
+		"/debug/test/exact": "exact",
+	} {
+		w := httptest.NewRecorder()
+		_routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, w.Body.String(), path)
+	}
+}
+
+func TestPrometheus_Shutdown(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("prometheus_http_addr", "127.0.0.1:0")
+	configtest.SetDefault(t, conf)
+
+	p := NewPrometheus()
+	assert.NotEmpty(t, p.Addr())
+
+	resp, err := http.Get("http://" + p.Addr() + "/metrics")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "go_goroutines")
+
+	assert.Nil(t, p.Shutdown(context.Background()))
+	_, err = http.Get("http://" + p.Addr() + "/metrics")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logx "github.com/Hyingerrr/mirco-esim/log"
+)
+
+type fileEntry struct {
+	Instance Instance  `json:"instance"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// File is a Registrar and a Discovery on a shared directory,
+// the processes of a machine find each other by it, e.g.
+//
+//	/tmp/esim-registry/order-service/order-service-10.0.0.1_50051.json
+type File struct {
+	dir string
+
+	// Watch 轮询的间隔
+	interval time.Duration
+}
+
+// NewFile polls the directory every interval in Watch, 1s by default.
+func NewFile(dir string, interval time.Duration) *File {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &File{dir: dir, interval: interval}
+}
+
+func (f *File) Register(ins Instance, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	dir := filepath.Join(f.dir, ins.Service)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(fileEntry{Instance: ins, ExpireAt: time.Now().Add(ttl)})
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再 rename, 读的一方不会读到写了一半的文件
+	file := f.file(ins)
+	tmp := file + ".tmp"
+	if err = ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+func (f *File) Deregister(ins Instance) error {
+	err := os.Remove(f.file(ins))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (f *File) Resolve(service string) ([]Endpoint, error) {
+	files, err := filepath.Glob(filepath.Join(f.dir, service, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	eps := make([]Endpoint, 0, len(files))
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			// 读的时候被注销了
+			continue
+		}
+
+		entry := fileEntry{}
+		if err = json.Unmarshal(buf, &entry); err != nil {
+			logx.Warnf("registry file %s: %s", file, err.Error())
+			continue
+		}
+
+		if entry.ExpireAt.After(now) {
+			eps = append(eps, entry.Instance.Endpoint())
+		}
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].Addr < eps[j].Addr })
+
+	return eps, nil
+}
+
+// Watch polls the directory, the expired instances are found by the polling too.
+func (f *File) Watch(service string, onChange func([]Endpoint)) (func(), error) {
+	last, err := f.Resolve(service)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				eps, err := f.Resolve(service)
+				if err != nil {
+					logx.Errorf("registry watch %s: %s", service, err.Error())
+					continue
+				}
+
+				if !Equal(last, eps) {
+					last = eps
+					onChange(eps)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+		})
+	}, nil
+}
+
+func (f *File) file(ins Instance) string {
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(ins.id())
+	return filepath.Join(f.dir, ins.Service, name+".json")
+}
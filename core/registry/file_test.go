@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile_RegisterWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim-registry")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	f := NewFile(dir, 20*time.Millisecond)
+
+	changes := make(chan []Endpoint, 10)
+	stop, err := f.Watch("order", func(eps []Endpoint) {
+		changes <- eps
+	})
+	assert.Nil(t, err)
+	defer stop()
+
+	wait := func() []Endpoint {
+		select {
+		case eps := <-changes:
+			return eps
+		case <-time.After(3 * time.Second):
+			t.Fatal("no change")
+			return nil
+		}
+	}
+
+	a := Instance{Service: "order", Host: "10.0.0.1", Port: 50051, Version: "v1.0.0"}
+	b := Instance{Service: "order", Host: "10.0.0.2", Port: 50051, Weight: 3}
+	assert.Nil(t, f.Register(a, 200*time.Millisecond))
+	assert.Nil(t, f.Register(b, time.Minute))
+
+	eps, err := f.Resolve("order")
+	assert.Nil(t, err)
+	assert.Equal(t, []Endpoint{
+		{Addr: "10.0.0.1:50051", Metadata: map[string]string{"version": "v1.0.0"}},
+		{Addr: "10.0.0.2:50051", Weight: 3, Metadata: map[string]string{}},
+	}, eps)
+
+	// 两次注册可能在同一次轮询里
+	if eps = wait(); len(eps) == 1 {
+		eps = wait()
+	}
+	assert.Len(t, eps, 2)
+
+	// a 不续约, 过期后被发现
+	eps = wait()
+	assert.Len(t, eps, 1)
+	assert.Equal(t, "10.0.0.2:50051", eps[0].Addr)
+
+	assert.Nil(t, f.Deregister(b))
+	assert.Len(t, wait(), 0)
+
+	// 重复注销不报错
+	assert.Nil(t, f.Deregister(b))
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"sync"
+	"time"
+
+	logx "github.com/Hyingerrr/mirco-esim/log"
+)
+
+const DefaultTTL = 15 * time.Second
+
+// Keeper registers an instance and renews it every ttl/3 until Stop,
+// so the instance expires soon after the process dies.
+type Keeper struct {
+	registrar Registrar
+	ins       Instance
+	ttl       time.Duration
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewKeeper(registrar Registrar, ins Instance, ttl time.Duration) *Keeper {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if ins.Weight < 1 {
+		ins.Weight = 1
+	}
+
+	ins.ID = ins.id()
+
+	return &Keeper{
+		registrar: registrar,
+		ins:       ins,
+		ttl:       ttl,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (k *Keeper) Instance() Instance {
+	return k.ins
+}
+
+// Start registers the instance and starts the heartbeat.
+// The heartbeat goes on even if the first registration fails,
+// the error is only returned for logging.
+func (k *Keeper) Start() error {
+	var err error
+
+	k.startOnce.Do(func() {
+		err = k.registrar.Register(k.ins, k.ttl)
+		go k.heartbeat()
+	})
+
+	return err
+}
+
+func (k *Keeper) heartbeat() {
+	defer close(k.done)
+
+	ticker := time.NewTicker(k.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			if err := k.registrar.Register(k.ins, k.ttl); err != nil {
+				logx.Errorf("registry heartbeat %s: %s", k.ins.ID, err.Error())
+			}
+		}
+	}
+}
+
+// Stop stops the heartbeat and deregisters the instance.
+func (k *Keeper) Stop() error {
+	var err error
+
+	k.stopOnce.Do(func() {
+		close(k.stop)
+
+		started := true
+		k.startOnce.Do(func() {
+			started = false
+		})
+
+		if started {
+			<-k.done
+		}
+
+		err = k.registrar.Deregister(k.ins)
+	})
+
+	return err
+}
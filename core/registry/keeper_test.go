@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeeper_Heartbeat(t *testing.T) {
+	m := NewMemory(10 * time.Millisecond)
+	defer m.Close()
+
+	k := NewKeeper(m, Instance{Service: "order", Host: "10.0.0.1", Port: 50051}, 90*time.Millisecond)
+	assert.Equal(t, "order-10.0.0.1:50051", k.Instance().ID)
+	assert.Equal(t, 1, k.Instance().Weight)
+	assert.Nil(t, k.Start())
+
+	// 超过 ttl 仍然在线
+	time.Sleep(300 * time.Millisecond)
+	eps, _ := m.Resolve("order")
+	assert.Len(t, eps, 1)
+
+	assert.Nil(t, k.Stop())
+	eps, _ = m.Resolve("order")
+	assert.Len(t, eps, 0)
+
+	// 没有 Start 也可以 Stop
+	assert.Nil(t, NewKeeper(m, Instance{Service: "order"}, 0).Stop())
+}
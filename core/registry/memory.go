@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	ins    Instance
+	expire time.Time
+}
+
+// Memory is a Registrar and a Discovery in the process,
+// for the local development and the tests.
+type Memory struct {
+	mu       sync.Mutex
+	services map[string]map[string]*memoryEntry
+	watchers map[string]map[int]func([]Endpoint)
+	nextID   int
+
+	// 保证通知的顺序
+	notifyMu sync.Mutex
+
+	now func() time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewMemory checks the expired instances every sweep interval, 1s by default.
+func NewMemory(sweep time.Duration) *Memory {
+	if sweep <= 0 {
+		sweep = time.Second
+	}
+
+	m := &Memory{
+		services: make(map[string]map[string]*memoryEntry),
+		watchers: make(map[string]map[int]func([]Endpoint)),
+		now:      time.Now,
+		closed:   make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(sweep)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.closed:
+				return
+			case <-ticker.C:
+				m.sweep()
+			}
+		}
+	}()
+
+	return m
+}
+
+func (m *Memory) Register(ins Instance, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	m.mu.Lock()
+	entries, ok := m.services[ins.Service]
+	if !ok {
+		entries = make(map[string]*memoryEntry)
+		m.services[ins.Service] = entries
+	}
+	entries[ins.id()] = &memoryEntry{ins: ins, expire: m.now().Add(ttl)}
+	m.mu.Unlock()
+
+	m.notify(ins.Service)
+
+	return nil
+}
+
+func (m *Memory) Deregister(ins Instance) error {
+	m.mu.Lock()
+	delete(m.services[ins.Service], ins.id())
+	m.mu.Unlock()
+
+	m.notify(ins.Service)
+
+	return nil
+}
+
+func (m *Memory) Resolve(service string) ([]Endpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.endpoints(service), nil
+}
+
+// Watch calls onChange in the goroutine of the change, onChange should not block.
+func (m *Memory) Watch(service string, onChange func([]Endpoint)) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	id := m.nextID
+
+	if m.watchers[service] == nil {
+		m.watchers[service] = make(map[int]func([]Endpoint))
+	}
+
+	last := m.endpoints(service)
+	m.watchers[service][id] = func(eps []Endpoint) {
+		if Equal(last, eps) {
+			return
+		}
+		last = eps
+		onChange(eps)
+	}
+
+	return func() {
+		m.mu.Lock()
+		delete(m.watchers[service], id)
+		m.mu.Unlock()
+	}, nil
+}
+
+// Close stops the sweep.
+func (m *Memory) Close() {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+}
+
+// endpoints returns the live endpoints sorted by addr, m.mu is held.
+func (m *Memory) endpoints(service string) []Endpoint {
+	now := m.now()
+
+	eps := make([]Endpoint, 0, len(m.services[service]))
+	for _, entry := range m.services[service] {
+		if entry.expire.After(now) {
+			eps = append(eps, entry.ins.Endpoint())
+		}
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].Addr < eps[j].Addr })
+
+	return eps
+}
+
+func (m *Memory) notify(service string) {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+
+	m.mu.Lock()
+	eps := m.endpoints(service)
+	watchers := make([]func([]Endpoint), 0, len(m.watchers[service]))
+	for _, w := range m.watchers[service] {
+		watchers = append(watchers, w)
+	}
+	m.mu.Unlock()
+
+	for _, w := range watchers {
+		w(eps)
+	}
+}
+
+// sweep removes the expired instances and notifies the watchers.
+func (m *Memory) sweep() {
+	var expired []string
+
+	m.mu.Lock()
+	now := m.now()
+	for service, entries := range m.services {
+		for id, entry := range entries {
+			if !entry.expire.After(now) {
+				delete(entries, id)
+				expired = append(expired, service)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, service := range expired {
+		m.notify(service)
+	}
+}
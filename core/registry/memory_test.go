@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_RegisterWatch(t *testing.T) {
+	m := NewMemory(time.Hour)
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	var (
+		mu      sync.Mutex
+		changes [][]Endpoint
+	)
+	stop, err := m.Watch("order", func(eps []Endpoint) {
+		mu.Lock()
+		changes = append(changes, eps)
+		mu.Unlock()
+	})
+	assert.Nil(t, err)
+
+	a := Instance{Service: "order", Host: "10.0.0.1", Port: 50051, Version: "v1.0.0", Weight: 2}
+	b := Instance{Service: "order", Host: "10.0.0.2", Port: 50051, Weight: 1, Metadata: map[string]string{"zone": "sh"}}
+	assert.Nil(t, m.Register(a, 10*time.Second))
+	assert.Nil(t, m.Register(b, 30*time.Second))
+	// 续约不通知
+	assert.Nil(t, m.Register(a, 10*time.Second))
+
+	eps, err := m.Resolve("order")
+	assert.Nil(t, err)
+	assert.Equal(t, []Endpoint{
+		{Addr: "10.0.0.1:50051", Weight: 2, Metadata: map[string]string{"version": "v1.0.0"}},
+		{Addr: "10.0.0.2:50051", Weight: 1, Metadata: map[string]string{"zone": "sh"}},
+	}, eps)
+
+	// a 没有续约, 过期
+	now = now.Add(20 * time.Second)
+	m.sweep()
+
+	eps, _ = m.Resolve("order")
+	assert.Len(t, eps, 1)
+	assert.Equal(t, "10.0.0.2:50051", eps[0].Addr)
+
+	assert.Nil(t, m.Deregister(b))
+
+	mu.Lock()
+	assert.Len(t, changes, 4)
+	assert.Len(t, changes[3], 0)
+	mu.Unlock()
+
+	stop()
+	assert.Nil(t, m.Register(a, 10*time.Second))
+
+	mu.Lock()
+	assert.Len(t, changes, 4)
+	mu.Unlock()
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoint is an instance of a service.
+type Endpoint struct {
+	Addr string `json:"addr"`
+
+	// 负载均衡的权重, 小于1按1处理
+	Weight int `json:"weight"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (ep Endpoint) weight() int {
+	if ep.Weight < 1 {
+		return 1
+	}
+
+	return ep.Weight
+}
+
+// Discovery finds the endpoints of the services,
+// e.g. a local stand-in, consul or etcd.
+type Discovery interface {
+	// Resolve returns the current endpoints of the service.
+	Resolve(service string) ([]Endpoint, error)
+
+	// Watch calls onChange with all the endpoints whenever they change,
+	// until stop is called.
+	Watch(service string, onChange func([]Endpoint)) (stop func(), err error)
+}
+
+// Instance is a running server of a service.
+type Instance struct {
+	// 默认 service-host-port
+	ID string `json:"id"`
+
+	// appname
+	Service string `json:"service"`
+
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	Version string `json:"version"`
+
+	Weight int `json:"weight"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Addr returns host:port.
+func (ins Instance) Addr() string {
+	return net.JoinHostPort(ins.Host, strconv.Itoa(ins.Port))
+}
+
+func (ins Instance) id() string {
+	if ins.ID != "" {
+		return ins.ID
+	}
+
+	return ins.Service + "-" + ins.Addr()
+}
+
+// Endpoint returns the endpoint the clients see, the version is put into the metadata.
+func (ins Instance) Endpoint() Endpoint {
+	md := make(map[string]string, len(ins.Metadata)+1)
+	for k, v := range ins.Metadata {
+		md[k] = v
+	}
+
+	if ins.Version != "" {
+		md["version"] = ins.Version
+	}
+
+	return Endpoint{Addr: ins.Addr(), Weight: ins.Weight, Metadata: md}
+}
+
+// Registrar announces the instances of the servers.
+type Registrar interface {
+	// Register registers or renews the instance,
+	// the instance expires if it is not renewed within ttl.
+	Register(ins Instance, ttl time.Duration) error
+
+	Deregister(ins Instance) error
+}
+
+// ParseEndpoint parses "host:port" or "host:port=weight".
+func ParseEndpoint(s string) (Endpoint, error) {
+	s = strings.TrimSpace(s)
+	ep := Endpoint{Addr: s, Weight: 1}
+
+	if i := strings.LastIndex(s, "="); i >= 0 {
+		weight, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		if err != nil {
+			return ep, err
+		}
+		ep.Addr, ep.Weight = strings.TrimSpace(s[:i]), weight
+	}
+
+	return ep, nil
+}
+
+// Equal reports whether a and b have the same addrs and weights in any order.
+func Equal(a, b []Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	key := func(eps []Endpoint) []string {
+		keys := make([]string, len(eps))
+		for i, ep := range eps {
+			keys[i] = ep.Addr + "=" + strconv.Itoa(ep.weight())
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	ka, kb := key(a), key(b)
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -22,14 +22,20 @@ var (
 	env DeployEnv
 )
 
-func SetRunMode(mode string) *RunMode {
+// NewRunMode parses mode without setting the run mode of the process, it is dev if empty.
+func NewRunMode(mode string) *RunMode {
 	if mode == "" {
-		env = DeployEnvDev
-	} else {
-		env = DeployEnv(mode)
+		return &RunMode{mode: DeployEnvDev}
 	}
 
-	return &RunMode{mode: env}
+	return &RunMode{mode: DeployEnv(mode)}
+}
+
+func SetRunMode(mode string) *RunMode {
+	runMode := NewRunMode(mode)
+	env = runMode.mode
+
+	return runMode
 }
 
 func (run RunMode) GetEnv() DeployEnv {
@@ -0,0 +1,151 @@
+package grpc
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/metadata"
+)
+
+// Balancers, set by grpc_client_balancer or per target by grpc_client_targets.
+const (
+	BalancerRoundRobin     = roundrobin.Name
+	BalancerWeighted       = "weighted_round_robin"
+	BalancerConsistentHash = "consistent_hash"
+)
+
+// 一致性hash环上每个权重的虚拟节点数
+const hashReplicas = 100
+
+func init() {
+	balancer.Register(base.NewBalancerBuilderV2(BalancerWeighted, &weightedPickerBuilder{}, base.Config{}))
+	balancer.Register(base.NewBalancerBuilderV2(BalancerConsistentHash, &hashPickerBuilder{}, base.Config{}))
+}
+
+type weightedSubConn struct {
+	sc      balancer.SubConn
+	weight  int
+	current int
+}
+
+type weightedPickerBuilder struct{}
+
+func (*weightedPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+
+	scs := make([]*weightedSubConn, 0, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		scs = append(scs, &weightedSubConn{sc: sc, weight: addrWeight(sci.Address)})
+	}
+
+	return &weightedPicker{scs: scs}
+}
+
+// weightedPicker is the smooth weighted round robin of nginx,
+// e.g. weights 5,1,1 pick a a b a c a a.
+type weightedPicker struct {
+	mu  sync.Mutex
+	scs []*weightedSubConn
+}
+
+func (p *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		total int
+		best  *weightedSubConn
+	)
+
+	for _, wsc := range p.scs {
+		wsc.current += wsc.weight
+		total += wsc.weight
+		if best == nil || wsc.current > best.current {
+			best = wsc
+		}
+	}
+	best.current -= total
+
+	return balancer.PickResult{SubConn: best.sc}, nil
+}
+
+type hashPickerBuilder struct{}
+
+func (*hashPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+
+	p := &hashPicker{nodes: make(map[uint32]balancer.SubConn)}
+
+	addrs := make([]string, 0, len(info.ReadySCs))
+	scs := make(map[string]balancer.SubConn, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		addrs = append(addrs, sci.Address.Addr)
+		scs[sci.Address.Addr] = sc
+
+		for i := 0; i < hashReplicas*addrWeight(sci.Address); i++ {
+			hash := crc32.ChecksumIEEE([]byte(sci.Address.Addr + "#" + strconv.Itoa(i)))
+			p.ring = append(p.ring, hash)
+			p.nodes[hash] = sc
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i] < p.ring[j] })
+
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		p.scs = append(p.scs, scs[addr])
+	}
+
+	return p
+}
+
+// hashPicker picks by the merid in the outgoing metadata,
+// the requests without merid are round robin.
+type hashPicker struct {
+	ring  []uint32
+	nodes map[uint32]balancer.SubConn
+
+	scs  []balancer.SubConn
+	next uint32
+}
+
+func (p *hashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	key := hashKey(info)
+	if key == "" {
+		next := atomic.AddUint32(&p.next, 1)
+		return balancer.PickResult{SubConn: p.scs[int(next)%len(p.scs)]}, nil
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i] >= hash })
+	if i == len(p.ring) {
+		i = 0
+	}
+
+	return balancer.PickResult{SubConn: p.nodes[p.ring[i]]}, nil
+}
+
+func hashKey(info balancer.PickInfo) string {
+	if info.Ctx == nil {
+		return ""
+	}
+
+	if md, ok := metadata.FromOutgoingContext(info.Ctx); ok {
+		if merID := md.Get(meta.MerID); len(merID) > 0 {
+			return merID[0]
+		}
+	}
+
+	return ""
+}
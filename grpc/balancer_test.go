@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	"github.com/Hyingerrr/mirco-esim/core/registry"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	addr string
+}
+
+func buildInfo(eps ...registry.Endpoint) (base.PickerBuildInfo, map[balancer.SubConn]string) {
+	info := base.PickerBuildInfo{ReadySCs: make(map[balancer.SubConn]base.SubConnInfo)}
+	names := make(map[balancer.SubConn]string)
+	for i, addr := range toAddresses(eps) {
+		sc := &fakeSubConn{addr: eps[i].Addr}
+		info.ReadySCs[sc] = base.SubConnInfo{Address: addr}
+		names[sc] = eps[i].Addr
+	}
+
+	return info, names
+}
+
+func TestWeightedPicker(t *testing.T) {
+	info, names := buildInfo(
+		registry.Endpoint{Addr: "a", Weight: 5},
+		registry.Endpoint{Addr: "b", Weight: 1},
+		registry.Endpoint{Addr: "c", Weight: 1},
+	)
+	picker := (&weightedPickerBuilder{}).Build(info)
+
+	counts := make(map[string]int)
+	for i := 0; i < 70; i++ {
+		res, err := picker.Pick(balancer.PickInfo{})
+		assert.Nil(t, err)
+		counts[names[res.SubConn]]++
+	}
+
+	assert.Equal(t, map[string]int{"a": 50, "b": 10, "c": 10}, counts)
+
+	_, err := (&weightedPickerBuilder{}).Build(base.PickerBuildInfo{}).Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+}
+
+func TestHashPicker(t *testing.T) {
+	pickFor := func(picker balancer.V2Picker, names map[balancer.SubConn]string, merID string) string {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(meta.MerID, merID))
+		res, err := picker.Pick(balancer.PickInfo{Ctx: ctx})
+		assert.Nil(t, err)
+		return names[res.SubConn]
+	}
+
+	info, names := buildInfo(registry.Endpoint{Addr: "a"}, registry.Endpoint{Addr: "b"}, registry.Endpoint{Addr: "c"})
+	picker := (&hashPickerBuilder{}).Build(info)
+
+	before := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		merID := fmt.Sprintf("M%04d", i)
+		before[merID] = pickFor(picker, names, merID)
+		assert.Equal(t, before[merID], pickFor(picker, names, merID))
+	}
+
+	// 去掉 c 后, 只有原来落在 c 上的商户换节点
+	info, names = buildInfo(registry.Endpoint{Addr: "a"}, registry.Endpoint{Addr: "b"})
+	picker = (&hashPickerBuilder{}).Build(info)
+	for merID, addr := range before {
+		if addr != "c" {
+			assert.Equal(t, addr, pickFor(picker, names, merID))
+		}
+	}
+
+	// 没有 merid 时轮询
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		res, err := picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+		assert.Nil(t, err)
+		seen[names[res.SubConn]] = true
+	}
+	assert.Len(t, seen, 2)
+}
+
+func startHealthServers(t *testing.T, n int) ([]string, func()) {
+	var (
+		addrs []string
+		svrs  []*grpc.Server
+	)
+
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+
+		svr := grpc.NewServer()
+		healthpb.RegisterHealthServer(svr, &identityHealth{})
+		go func() {
+			_ = svr.Serve(lis)
+		}()
+
+		addrs = append(addrs, lis.Addr().String())
+		svrs = append(svrs, svr)
+	}
+
+	return addrs, func() {
+		for _, svr := range svrs {
+			svr.Stop()
+		}
+	}
+}
+
+func TestBalancer_ConsistentHashDial(t *testing.T) {
+	addrs, stop := startHealthServers(t, 2)
+	defer stop()
+
+	conn, err := grpc.Dial(SchemeStatic+":///"+strings.Join(addrs, ","), grpc.WithInsecure(),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, BalancerConsistentHash)))
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+	check := func(ctx context.Context) string {
+		p := &peer.Peer{}
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true), grpc.Peer(p))
+		assert.Nil(t, err)
+		return p.Addr.String()
+	}
+
+	// 没有 merid 时轮询, 等两个连接都 ready
+	seen := make(map[string]bool)
+	for i := 0; i < 50 && len(seen) < 2; i++ {
+		seen[check(context.Background())] = true
+	}
+	assert.Len(t, seen, 2)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), meta.MerID, "M0001")
+	first := check(ctx)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, check(ctx))
+	}
+}
@@ -0,0 +1,56 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/breaker"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerFallback is called when the breaker rejects the call or the call fails,
+// e.g. fill reply with a default and return nil to degrade.
+// err is ErrBreakerOpen if rejected.
+type BreakerFallback func(ctx context.Context, method string, req, reply interface{}, err error) error
+
+// ErrBreakerOpen is returned when the breaker is open.
+var ErrBreakerOpen = status.Error(codes.Unavailable, breaker.ErrOpen.Error())
+
+// 业务错误不计入熔断
+var breakerFailureCodes = map[codes.Code]bool{
+	codes.Unknown:           true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Internal:          true,
+	codes.Unavailable:       true,
+	codes.DataLoss:          true,
+}
+
+// breakerUnaryClientInterceptor keeps a breaker for each target and method,
+// it wraps the retries so an open breaker is not retried.
+func breakerUnaryClientInterceptor(group *breaker.Group, fallback BreakerFallback) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := group.Allow(cc.Target() + method)
+		if err != nil {
+			if fallback != nil {
+				return fallback(ctx, method, req, reply, ErrBreakerOpen)
+			}
+			return ErrBreakerOpen
+		}
+
+		beg := time.Now()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+
+		done(err != nil && breakerFailureCodes[status.Code(errors.Cause(err))], time.Since(beg))
+
+		if err != nil && fallback != nil {
+			return fallback(ctx, method, req, reply, err)
+		}
+
+		return err
+	}
+}
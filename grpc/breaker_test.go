@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/breaker"
+	"github.com/Hyingerrr/mirco-esim/grpc/test"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreakerUnaryClientInterceptor(t *testing.T) {
+	group := breaker.NewGroup(breaker.GroupOptions{}.WithConfig(breaker.Config{MinRequests: 2, OpenTimeout: time.Minute}))
+
+	var fallbacks []error
+	interceptor := breakerUnaryClientInterceptor(group, func(ctx context.Context, method string, req, reply interface{}, err error) error {
+		fallbacks = append(fallbacks, err)
+		if err == ErrBreakerOpen {
+			reply.(*test.HelloResponse).NameEn = "fallback"
+			return nil
+		}
+		return err
+	})
+
+	cc, err := grpc.Dial("passthrough:///order", grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	var calls int
+	invoke := func(method string, err error) (*test.HelloResponse, error) {
+		reply := &test.HelloResponse{}
+		return reply, interceptor(context.Background(), method, nil, reply, cc,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				calls++
+				return err
+			})
+	}
+
+	// 业务错误不熔断
+	for i := 0; i < 3; i++ {
+		_, err = invoke("/order.Order/Query", status.Error(codes.InvalidArgument, "invalid"))
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	unavailable := handlerErr(status.Error(codes.Unavailable, "unavailable"))
+	_, _ = invoke("/order.Order/Create", unavailable)
+	_, _ = invoke("/order.Order/Create", unavailable)
+	assert.Equal(t, breaker.StateOpen, group.Get(cc.Target()+"/order.Order/Create").State())
+
+	calls = 0
+	reply, err := invoke("/order.Order/Create", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "fallback", reply.NameEn)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, ErrBreakerOpen, fallbacks[len(fallbacks)-1])
+
+	// 按方法熔断
+	_, err = invoke("/order.Order/Query", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -19,6 +19,8 @@ type ServerConfig struct {
 	Port    string
 	// handle
 	Timeout time.Duration // grpc_server_timeout
+	// 整个流的时限, 0 不限制; ms
+	StreamTimeout time.Duration
 	// conn
 	DialTimeout time.Duration // ms
 	// keepalive
@@ -34,51 +36,85 @@ type ServerConfig struct {
 	Tracer bool
 	// validate
 	Validate bool
+	// grpc health service
+	Health bool
+	// grpc_server_tls
+	TLS *TLSConfig
+	// 按 limiter_rules 限流
+	Limiter bool
+	// 注册到注册中心的地址, 为空时取本机ip
+	RegisterHost string
+	// 心跳超时; s
+	RegisterTTL      time.Duration
+	RegisterWeight   int
+	RegisterMetadata map[string]string
 }
 
-func (gs *Server) setServerConfig() {
-	s := &ServerConfig{}
-	s.Debug = config.GetBool("grpc_server_debug")
-	s.Metrics = config.GetBool("grpc_server_metrics")
-	s.Tracer = config.GetBool("grpc_server_tracer")
-	s.Validate = config.GetBool("grpc_server_validate")
-	s.AppName = config.GetString("appname")
-	s.Addr = config.GetString("grpc_server_tcp")
-	if s.Addr == "" {
-		logx.Panicf("grpc addr is empty")
-	}
-	if in := strings.Index(s.Addr, ":"); in < 0 {
-		s.Addr = ":" + s.Addr
-	}
-
-	s.Timeout = config.GetDuration("grpc_server_timeout") * time.Millisecond
-	if s.Timeout == 0 {
-		s.Timeout = 1000 * time.Millisecond
-	}
+// serverKeys are the keys of ServerConfig, durations are in the units of the keys.
+type serverKeys struct {
+	AppName string `mapstructure:"appname"`
+	Addr    string `mapstructure:"grpc_server_tcp" validate:"required"`
+
+	Timeout       int `mapstructure:"grpc_server_timeout" default:"1000" validate:"gte=0"`       // ms
+	StreamTimeout int `mapstructure:"grpc_server_stream_timeout" validate:"gte=0"`               // ms
+	DialTimeout   int `mapstructure:"grpc_server_conn_time_out" default:"2000" validate:"gte=0"` // ms
+	KeepTime      int `mapstructure:"grpc_server_kp_time" default:"7200" validate:"gte=0"`       // s
+	KeepTimeOut   int `mapstructure:"grpc_server_kp_time_out" default:"20" validate:"gte=0"`     // s
+	SlowTime      int `mapstructure:"grpc_server_slow_time" validate:"gte=0"`                    // ms
+
+	Debug         bool `mapstructure:"grpc_server_debug"`
+	Metrics       bool `mapstructure:"grpc_server_metrics"`
+	Tracer        bool `mapstructure:"grpc_server_tracer"`
+	Validate      bool `mapstructure:"grpc_server_validate"`
+	HealthDisable bool `mapstructure:"grpc_server_health_disable"`
+	Limiter       bool `mapstructure:"grpc_server_limiter"`
+
+	RegisterHost     string            `mapstructure:"grpc_server_register_host"`
+	RegisterTTL      int               `mapstructure:"grpc_server_register_ttl" validate:"gte=0"` // s
+	RegisterWeight   int               `mapstructure:"grpc_server_register_weight" validate:"gte=0"`
+	RegisterMetadata map[string]string `mapstructure:"grpc_server_register_metadata"`
+}
 
-	s.DialTimeout = config.GetDuration("grpc_server_conn_time_out") * time.Millisecond
-	if s.DialTimeout == 0 {
-		s.DialTimeout = 2000 * time.Millisecond
+func (gs *Server) setServerConfig() {
+	k := serverKeys{}
+	if err := config.Bind("", &k); err != nil {
+		logx.Panicf("grpc server: %s", err.Error())
 	}
 
-	s.KeepTime = config.GetDuration("grpc_server_kp_time") * time.Second
-	if s.KeepTime == 0 {
-		s.KeepTime = 2 * time.Hour
+	s := &ServerConfig{
+		AppName:          k.AppName,
+		Addr:             k.Addr,
+		Timeout:          time.Duration(k.Timeout) * time.Millisecond,
+		StreamTimeout:    time.Duration(k.StreamTimeout) * time.Millisecond,
+		DialTimeout:      time.Duration(k.DialTimeout) * time.Millisecond,
+		KeepTime:         time.Duration(k.KeepTime) * time.Second,
+		KeepTimeOut:      time.Duration(k.KeepTimeOut) * time.Second,
+		SlowTime:         time.Duration(k.SlowTime) * time.Millisecond,
+		Debug:            k.Debug,
+		Metrics:          k.Metrics,
+		Tracer:           k.Tracer,
+		Validate:         k.Validate,
+		Health:           !k.HealthDisable,
+		TLS:              newTLSConfig("grpc_server_tls"),
+		Limiter:          k.Limiter,
+		RegisterHost:     k.RegisterHost,
+		RegisterTTL:      time.Duration(k.RegisterTTL) * time.Second,
+		RegisterWeight:   k.RegisterWeight,
+		RegisterMetadata: k.RegisterMetadata,
 	}
 
-	s.KeepTimeOut = config.GetDuration("grpc_server_kp_time_out") * time.Second
-	if s.KeepTimeOut == 0 {
-		s.KeepTimeOut = 20 * time.Second
+	if in := strings.Index(s.Addr, ":"); in < 0 {
+		s.Addr = ":" + s.Addr
 	}
 
-	s.SlowTime = config.GetDuration("grpc_server_slow_time") * time.Millisecond
-
 	gs.config = s
 }
 
 type ClientConfig struct {
 	// handle
 	Timeout time.Duration
+	// 整个流的时限, 0 不限制; ms
+	StreamTimeout time.Duration
 	// conn
 	DialTimeout time.Duration // ms
 	// keepalive
@@ -93,36 +129,89 @@ type ClientConfig struct {
 	SlowTime time.Duration
 	// tracer
 	Tracer bool
+	// grpc_client_tls
+	TLS *TLSConfig
+	// 默认的负载均衡, 为空时使用 grpc 的 pick_first
+	Balancer string
+	// 按 target 配置的负载均衡
+	Targets []TargetConfig
+	// 按方法配置的重试
+	Retries []*RetryPolicy
+	// 按 target 和方法熔断
+	Breaker bool
 }
 
-func (gc *ClientOptions) setClientConfig() {
-	s := &ClientConfig{}
-	s.Debug = config.GetBool("grpc_client_debug")
-	s.Metrics = config.GetBool("grpc_client_metrics")
-	s.Tracer = config.GetBool("grpc_client_tracer")
-	s.PermitWithoutStream = config.GetBool("grpc_client_permit_without_stream")
-
-	s.Timeout = config.GetDuration("grpc_client_timeout") * time.Millisecond
-	if s.Timeout == 0 {
-		s.Timeout = 1000 * time.Millisecond
-	}
+// TargetConfig is an item of grpc_client_targets, e.g.
+//
+//	grpc_client_targets:
+//	  - target: registry:///order-service
+//	    balancer: consistent_hash
+type TargetConfig struct {
+	Target   string `mapstructure:"target" validate:"required"`
+	Balancer string `mapstructure:"balancer" validate:"oneof=pick_first round_robin weighted_round_robin consistent_hash"`
+}
 
-	s.DialTimeout = config.GetDuration("grpc_client_conn_time_out") * time.Millisecond
-	if s.DialTimeout == 0 {
-		s.DialTimeout = 200 * time.Millisecond
+// balancerFor returns the balancer of the target.
+func (cc *ClientConfig) balancerFor(target string) string {
+	for _, tc := range cc.Targets {
+		if tc.Target == target {
+			return tc.Balancer
+		}
 	}
 
-	s.KeepTime = config.GetDuration("grpc_client_kp_time") * time.Second
-	if s.KeepTime == 0 {
-		s.KeepTime = 3600 * time.Second
+	return cc.Balancer
+}
+
+// clientKeys are the keys of ClientConfig, durations are in the units of the keys.
+type clientKeys struct {
+	Timeout       int `mapstructure:"grpc_client_timeout" default:"1000" validate:"gte=0"`      // ms
+	StreamTimeout int `mapstructure:"grpc_client_stream_timeout" validate:"gte=0"`              // ms
+	DialTimeout   int `mapstructure:"grpc_client_conn_time_out" default:"200" validate:"gte=0"` // ms
+	KeepTime      int `mapstructure:"grpc_client_kp_time" default:"3600" validate:"gte=0"`      // s
+	KeepTimeOut   int `mapstructure:"grpc_client_kp_time_out" default:"20" validate:"gte=0"`    // s
+	SlowTime      int `mapstructure:"grpc_client_slow_time" validate:"gte=0"`                   // ms
+
+	PermitWithoutStream bool `mapstructure:"grpc_client_permit_without_stream"`
+	Debug               bool `mapstructure:"grpc_client_debug"`
+	Metrics             bool `mapstructure:"grpc_client_metrics"`
+	Tracer              bool `mapstructure:"grpc_client_tracer"`
+	Breaker             bool `mapstructure:"grpc_client_breaker"`
+
+	Balancer string         `mapstructure:"grpc_client_balancer"`
+	Targets  []TargetConfig `mapstructure:"grpc_client_targets" validate:"dive"`
+	Retries  []*RetryPolicy `mapstructure:"grpc_client_retries" validate:"dive"`
+}
+
+func (gc *ClientOptions) setClientConfig() {
+	k := clientKeys{}
+	if err := config.Bind("", &k); err != nil {
+		logx.Panicf("grpc client: %s", err.Error())
 	}
 
-	s.KeepTimeOut = config.GetDuration("grpc_client_kp_time_out") * time.Second
-	if s.KeepTimeOut == 0 {
-		s.KeepTimeOut = 20 * time.Second
+	s := &ClientConfig{
+		Timeout:             time.Duration(k.Timeout) * time.Millisecond,
+		StreamTimeout:       time.Duration(k.StreamTimeout) * time.Millisecond,
+		DialTimeout:         time.Duration(k.DialTimeout) * time.Millisecond,
+		KeepTime:            time.Duration(k.KeepTime) * time.Second,
+		KeepTimeOut:         time.Duration(k.KeepTimeOut) * time.Second,
+		PermitWithoutStream: k.PermitWithoutStream,
+		Debug:               k.Debug,
+		Metrics:             k.Metrics,
+		SlowTime:            time.Duration(k.SlowTime) * time.Millisecond,
+		Tracer:              k.Tracer,
+		TLS:                 newTLSConfig("grpc_client_tls"),
+		Balancer:            k.Balancer,
+		Targets:             k.Targets,
+		// WithRetryPolicies 优先
+		Retries: append(gc.retries, k.Retries...),
+		Breaker: k.Breaker,
 	}
 
-	s.SlowTime = config.GetDuration("grpc_client_slow_time") * time.Millisecond
+	for _, p := range s.Retries {
+		if err := p.init(); err != nil {
+			logx.Panicf("grpc client retries: %s", err.Error())
+		}
+	}
 
 	gc.config = s
 }
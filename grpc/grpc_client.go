@@ -1,10 +1,12 @@
 package grpc
 
 import (
+	"fmt"
 	"time"
 
 	"google.golang.org/grpc/keepalive"
 
+	"github.com/Hyingerrr/mirco-esim/core/breaker"
 	logx "github.com/Hyingerrr/mirco-esim/log"
 
 	"golang.org/x/net/context"
@@ -17,8 +19,12 @@ type Client struct {
 }
 
 type ClientOptions struct {
-	opts   []grpc.DialOption
-	config *ClientConfig
+	opts    []grpc.DialOption
+	config  *ClientConfig
+	retries []*RetryPolicy
+
+	breaker         *breaker.Group
+	breakerFallback BreakerFallback
 }
 
 type ClientOptional func(c *ClientOptions)
@@ -32,8 +38,17 @@ func NewClientOptions(options ...ClientOptional) *ClientOptions {
 
 	c.setClientConfig()
 
+	transport := grpc.WithInsecure()
+	if c.config.TLS.Enable {
+		creds, err := NewClientTLSCreds(c.config.TLS)
+		if err != nil {
+			logx.Panicf("grpc client tls: %s", err.Error())
+		}
+		transport = grpc.WithTransportCredentials(creds)
+	}
+
 	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
+		transport,
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:                c.config.KeepTime,
 			Timeout:             c.config.KeepTimeOut,
@@ -41,21 +56,37 @@ func NewClientOptions(options ...ClientOptional) *ClientOptions {
 		}),
 		grpc.WithChainUnaryInterceptor(
 			timeOutUnaryClientInterceptor(c.config.Timeout), metadataHandler()),
+		grpc.WithChainStreamInterceptor(
+			timeOutStreamClientInterceptor(c.config.StreamTimeout), metadataStreamHandler()),
+	}
+
+	if c.breaker == nil && c.config.Breaker {
+		c.breaker = breaker.NewGroup()
 	}
 
+	// 熔断在重试之外, 熔断后不再重试
+	if c.breaker != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(breakerUnaryClientInterceptor(c.breaker, c.breakerFallback)))
+	}
+
+	opts = append(opts, grpc.WithChainUnaryInterceptor(retryUnaryClientInterceptor(c.config.Retries, c.config.Metrics)))
+
 	if c.config.Debug {
 		opts = append(opts,
-			grpc.WithChainUnaryInterceptor(debugUnaryClientInterceptor(c.config.SlowTime)))
+			grpc.WithChainUnaryInterceptor(debugUnaryClientInterceptor(c.config.SlowTime)),
+			grpc.WithChainStreamInterceptor(debugStreamClientInterceptor(c.config.SlowTime)))
 	}
 
 	if c.config.Tracer {
 		opts = append(opts,
-			grpc.WithChainUnaryInterceptor(traceUnaryClientInterceptor()))
+			grpc.WithChainUnaryInterceptor(traceUnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(traceStreamClientInterceptor()))
 	}
 
 	if c.config.Metrics {
 		opts = append(opts,
-			grpc.WithChainUnaryInterceptor(metricUnaryClientInterceptor()))
+			grpc.WithChainUnaryInterceptor(metricUnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(metricStreamClientInterceptor()))
 	}
 
 	c.opts = append(c.opts, opts...)
@@ -69,6 +100,22 @@ func WithDialOptions(options ...grpc.DialOption) ClientOptional {
 	}
 }
 
+// WithRetryPolicies is used before grpc_client_retries.
+func WithRetryPolicies(policies ...*RetryPolicy) ClientOptional {
+	return func(g *ClientOptions) {
+		g.retries = policies
+	}
+}
+
+// WithBreaker breaks by target and method, grpc_client_breaker uses a group of the breaker_* config.
+// fallback may be nil.
+func WithBreaker(group *breaker.Group, fallback BreakerFallback) ClientOptional {
+	return func(g *ClientOptions) {
+		g.breaker = group
+		g.breakerFallback = fallback
+	}
+}
+
 // NewClient create Client for business.
 // clientOptions clientOptions can not nil.
 func NewClient(clientOptions *ClientOptions) *Client {
@@ -79,24 +126,31 @@ func NewClient(clientOptions *ClientOptions) *Client {
 	return c
 }
 
+// DialContext dials the target, see the schemes of the resolvers, e.g. registry:///order-service.
 func (gc *Client) DialContext(ctx context.Context, target string) *grpc.ClientConn {
 	var cancel context.CancelFunc
 	var err error
 
+	opts := append([]grpc.DialOption(nil), gc.clientOpts.opts...)
+
 	// connect timeout ctrl
 	if dt := gc.clientOpts.config.DialTimeout; dt > 0 {
 		ctx, cancel = context.WithTimeout(ctx, dt)
 		defer cancel()
 
 		// grpc.WithBlock()等待链接建立完成; 否则dialTimeout无效
-		gc.clientOpts.opts = append(gc.clientOpts.opts, grpc.WithBlock())
+		opts = append(opts, grpc.WithBlock())
 
 		//todo debug
 		dl, _ := ctx.Deadline()
 		logx.Infoc(ctx, "拨号deadline:%v", dl.String())
 	}
 
-	gc.conn, err = grpc.DialContext(ctx, target, gc.clientOpts.opts...)
+	if lb := gc.clientOpts.config.balancerFor(target); lb != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, lb)))
+	}
+
+	gc.conn, err = grpc.DialContext(ctx, target, opts...)
 	if err != nil {
 		logx.Errorc(ctx, "grpc dial error: %v", err)
 		return nil
@@ -106,7 +160,9 @@ func (gc *Client) DialContext(ctx context.Context, target string) *grpc.ClientCo
 }
 
 func (gc *Client) Close() {
-	_ = gc.conn.Close()
+	if gc.conn != nil {
+		_ = gc.conn.Close()
+	}
 }
 
 type TimeoutCallOption struct {
@@ -62,6 +62,7 @@ func TestMain(m *testing.M) {
 	svr := NewServer()
 
 	test.RegisterHelloServerServer(svr.server, &server{})
+	svr.server.RegisterService(&echoStreamDesc, &server{})
 
 	svr.Use(panicResp())
 	svr.Start()
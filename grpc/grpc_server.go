@@ -5,10 +5,16 @@ import (
 
 	"google.golang.org/grpc/keepalive"
 
+	"github.com/Hyingerrr/mirco-esim/core/admin"
+	"github.com/Hyingerrr/mirco-esim/core/health"
+	"github.com/Hyingerrr/mirco-esim/core/limiter"
+	"github.com/Hyingerrr/mirco-esim/core/registry"
 	logx "github.com/Hyingerrr/mirco-esim/log"
+	"github.com/Hyingerrr/mirco-esim/pkg/hepler"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -17,9 +23,16 @@ type Server struct {
 
 	interceptors []grpc.UnaryServerInterceptor
 
+	streamInterceptors []grpc.StreamServerInterceptor
+
 	opts []grpc.ServerOption
 
 	config *ServerConfig
+
+	registrar registry.Registrar
+	keeper    *registry.Keeper
+
+	limiter *limiter.Manager
 }
 
 type ServerOption func(c *Server)
@@ -43,6 +56,15 @@ func NewServer(options ...ServerOption) *Server {
 			Time:    s.config.KeepTime,
 		}),
 		grpc.UnaryInterceptor(s.handlerInterceptor),
+		grpc.StreamInterceptor(s.streamHandlerInterceptor),
+	}
+
+	if s.config.TLS.Enable {
+		creds, err := NewServerTLSCreds(s.config.TLS)
+		if err != nil {
+			logx.Panicf("grpc server tls: %s", err.Error())
+		}
+		baseOpts = append(baseOpts, grpc.Creds(creds))
 	}
 
 	if len(s.opts) > 0 {
@@ -51,26 +73,50 @@ func NewServer(options ...ServerOption) *Server {
 
 	s.server = grpc.NewServer(baseOpts...)
 
+	if s.config.Health {
+		healthpb.RegisterHealthServer(s.server, newHealthServer(health.Default()))
+	}
+
 	s.Use(recoverServerInterceptor(), tracerIDServerInterceptor())
+	s.UseStream(recoverStreamServerInterceptor(), tracerIDStreamServerInterceptor())
+
+	if s.config.TLS.Enable {
+		s.Use(peerIdentityServerInterceptor())
+		s.UseStream(peerIdentityStreamServerInterceptor())
+	}
+
+	if s.limiter == nil && s.config.Limiter {
+		s.limiter = limiter.NewManager()
+	}
+
+	if s.limiter != nil {
+		s.Use(limiterUnaryServerInterceptor(s.limiter))
+		s.UseStream(limiterStreamServerInterceptor(s.limiter))
+	}
 
 	if s.config.Debug {
 		s.Use(debugUnaryServerInterceptor(s.config.SlowTime))
+		s.UseStream(debugStreamServerInterceptor(s.config.SlowTime))
 	}
 
 	if s.config.Validate {
 		s.Use(validateServerInterceptor())
+		s.UseStream(validateStreamServerInterceptor())
 	}
 
 	if s.config.Tracer {
 		s.Use(traceUnaryServerInterceptor)
+		s.UseStream(traceStreamServerInterceptor)
 	}
 
 	if s.config.Metrics {
 		s.Use(metricUnaryServerInterceptor)
+		s.UseStream(metricStreamServerInterceptor)
 	}
 
 	// timeout
 	s.Use(timeoutUnaryServerInterceptor(s.config.Timeout))
+	s.UseStream(timeoutStreamServerInterceptor(s.config.StreamTimeout))
 
 	return s
 }
@@ -81,6 +127,26 @@ func (ServerOptions) WithUnarySrvItcp(options ...grpc.UnaryServerInterceptor) Se
 	}
 }
 
+func (ServerOptions) WithStreamSrvItcp(options ...grpc.StreamServerInterceptor) ServerOption {
+	return func(g *Server) {
+		g.streamInterceptors = options
+	}
+}
+
+// WithLimiter limits the requests by the rules of m, grpc_server_limiter uses the limiter_rules.
+func (ServerOptions) WithLimiter(m *limiter.Manager) ServerOption {
+	return func(g *Server) {
+		g.limiter = m
+	}
+}
+
+// WithRegistrar registers the server after Start, and deregisters it at GracefulShutDown.
+func (ServerOptions) WithRegistrar(registrar registry.Registrar) ServerOption {
+	return func(g *Server) {
+		g.registrar = registrar
+	}
+}
+
 func (ServerOptions) WithServerOption(options ...grpc.ServerOption) ServerOption {
 	return func(g *Server) {
 		g.opts = options
@@ -138,9 +204,61 @@ func (gs *Server) Start() {
 			logx.Panicf("Failed to start server: %s", err.Error())
 		}
 	}()
+
+	if gs.registrar != nil {
+		gs.keeper = registry.NewKeeper(gs.registrar, gs.instance(lis.Addr()), gs.config.RegisterTTL)
+		// 注册失败时心跳会继续重试
+		if err = gs.keeper.Start(); err != nil {
+			logx.Errorf("Grpc server register %s: %s", gs.keeper.Instance().ID, err.Error())
+		} else {
+			logx.Infof("Grpc server registered %s", gs.keeper.Instance().ID)
+		}
+	}
 }
 
+// instance is what the server registers, the port is taken from the listener for grpc_server_tcp :0.
+func (gs *Server) instance(addr net.Addr) registry.Instance {
+	ins := registry.Instance{
+		Service:  gs.config.AppName,
+		Host:     gs.config.RegisterHost,
+		Version:  admin.GetBuildInfo().Version,
+		Weight:   gs.config.RegisterWeight,
+		Metadata: gs.config.RegisterMetadata,
+	}
+
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		ins.Port = tcpAddr.Port
+	}
+
+	if ins.Host == "" {
+		if host, _, err := net.SplitHostPort(gs.config.Addr); err == nil {
+			if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+				ins.Host = host
+			}
+		}
+	}
+
+	if ins.Host == "" {
+		ins.Host, _ = hepler.GetLocalIp()
+	}
+
+	if ins.Host == "" {
+		ins.Host = "127.0.0.1"
+	}
+
+	return ins
+}
+
+// GracefulShutDown deregisters the server first, then waits the pending RPCs.
 func (gs *Server) GracefulShutDown() {
+	health.SetReady(false)
+
+	if gs.keeper != nil {
+		if err := gs.keeper.Stop(); err != nil {
+			logx.Errorf("Grpc server deregister %s: %s", gs.keeper.Instance().ID, err.Error())
+		}
+	}
+
 	gs.server.GracefulStop()
 }
 
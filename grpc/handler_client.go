@@ -67,7 +67,7 @@ func timeOutUnaryClientInterceptor(timeout time.Duration) grpc.UnaryClientInterc
 func metadataHandler() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		// set metadata
-		md, err := setClientMetadata(ctx, req)
+		md, err := setClientMetadata(ctx, req, cc.Target())
 		if err != nil {
 			return handlerErr(err)
 		}
@@ -120,7 +120,7 @@ func debugUnaryClientInterceptor(slowTime time.Duration) grpc.UnaryClientInterce
 
 		// request params
 		logx.Infoc(ctx, "GRPC_Send_RequestParams: method[%v], target[%v], params:%+v",
-			method, cc.Target(), req)
+			method, cc.Target(), logx.Mask(req))
 
 		err := invoker(ctx, method, req, reply, cc, opts...)
 
@@ -131,7 +131,7 @@ func debugUnaryClientInterceptor(slowTime time.Duration) grpc.UnaryClientInterce
 
 		// response params
 		logx.Infoc(ctx, "GRPC_Get_ResponseParams: method[%v], target[%v], params:%+v",
-			method, cc.Target(), spew.Sdump(reply))
+			method, cc.Target(), spew.Sdump(logx.Mask(reply)))
 
 		return handlerErr(err)
 	}
@@ -183,7 +183,7 @@ func injectSpanContext(ctx context.Context, span opentracing.Span) context.Conte
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
-func setClientMetadata(ctx context.Context, req interface{}) (metadata.MD, error) {
+func setClientMetadata(ctx context.Context, req interface{}, target string) (metadata.MD, error) {
 	var (
 		cmd     = new(meta.CommonHeader)
 		d       = metadata.MD{}
@@ -216,9 +216,13 @@ func setClientMetadata(ctx context.Context, req interface{}) (metadata.MD, error
 		d.Set(meta.SrcSysId, srcSysId)
 	}
 
-	// 可以根据服务发现，自动获取目的服务名
+	// 服务发现的 target 以服务名为目的系统
 	if dstSysId := cmd.Head.DstSysId; dstSysId == "" {
-		d.Set(meta.DstSysId, config.GetString("appname"))
+		if service := serviceOf(target); service != "" {
+			d.Set(meta.DstSysId, service)
+		} else {
+			d.Set(meta.DstSysId, config.GetString("appname"))
+		}
 	} else {
 		d.Set(meta.DstSysId, dstSysId)
 	}
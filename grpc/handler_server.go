@@ -72,13 +72,13 @@ func debugUnaryServerInterceptor(slowTime time.Duration) grpc.UnaryServerInterce
 		)
 		// request logger
 		logx.Infoc(ctx, "Get_Request_Params: method[%v], server[%v], body:%+v",
-			info.FullMethod, info.Server, req)
+			info.FullMethod, info.Server, logx.Mask(req))
 
 		resp, err = handler(ctx, req)
 
 		// response logger
 		logx.Infoc(ctx, "Send_Response_Params: method[%v], server[%v], cost[%v], resp_params:%+v",
-			info.FullMethod, info.Server, time.Since(start).String(), spew.Sdump(resp))
+			info.FullMethod, info.Server, time.Since(start).String(), spew.Sdump(logx.Mask(resp)))
 
 		// check grpc slow
 		if sub := time.Now().Sub(start); sub > slowTime*time.Millisecond {
@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const healthWatchInterval = 5 * time.Second
+
+// healthServer implements the standard grpc health service by the health registry,
+// the empty service means the whole server, the others are the checker names.
+type healthServer struct {
+	registry *health.Registry
+
+	interval time.Duration
+}
+
+func newHealthServer(registry *health.Registry) *healthServer {
+	return &healthServer{
+		registry: registry,
+		interval: healthWatchInterval,
+	}
+}
+
+func (hs *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := hs.status(ctx, req.Service)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch sends the status at first and whenever it changes.
+func (hs *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(hs.interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, ok := hs.status(stream.Context(), req.Service)
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return status.Error(codes.Canceled, "stream has ended")
+			}
+			last = st
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (hs *healthServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if service == "" {
+		if hs.registry.Ready(ctx).OK() {
+			return healthpb.HealthCheckResponse_SERVING, true
+		}
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+
+	result, err := hs.registry.CheckOne(ctx, service)
+	if err != nil {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+
+	if result.Status == health.StatusOK {
+		return healthpb.HealthCheckResponse_SERVING, true
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING, true
+}
@@ -0,0 +1,40 @@
+package grpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthServer_Check(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Register("mysql", health.PingChecker(func() error { return nil }))
+	registry.Register("redis", health.PingChecker(func() error { return errors.New("refused") }),
+		health.Options{}.WithCritical(false))
+
+	hs := newHealthServer(registry)
+	ctx := context.Background()
+
+	resp, err := hs.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	resp, err = hs.Check(ctx, &healthpb.HealthCheckRequest{Service: "redis"})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	_, err = hs.Check(ctx, &healthpb.HealthCheckRequest{Service: "mongodb"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	registry.SetReady(false)
+	resp, err = hs.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
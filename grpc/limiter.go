@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/Hyingerrr/mirco-esim/core/limiter"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func limiterRequest(ctx context.Context, method string) limiter.Request {
+	req := limiter.Request{Method: method}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if appID := md.Get(meta.AppID); len(appID) > 0 {
+			req.AppID = appID[0]
+		}
+
+		if merID := md.Get(meta.MerID); len(merID) > 0 {
+			req.MerID = merID[0]
+		}
+	}
+
+	return req
+}
+
+func limiterUnaryServerInterceptor(m *limiter.Manager) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done, rule, ok := m.Allow(limiterRequest(ctx, info.FullMethod))
+		if !ok {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limited by %s", rule)
+		}
+		defer done()
+
+		return handler(ctx, req)
+	}
+}
+
+// limiterStreamServerInterceptor holds the concurrency until the stream ends.
+func limiterStreamServerInterceptor(m *limiter.Manager) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done, rule, ok := m.Allow(limiterRequest(ss.Context(), info.FullMethod))
+		if !ok {
+			return status.Errorf(codes.ResourceExhausted, "rate limited by %s", rule)
+		}
+		defer done()
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,43 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/limiter"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterUnaryServerInterceptor(t *testing.T) {
+	m := limiter.NewManager(limiter.ManagerOptions{}.WithRules(
+		limiter.Rule{Name: "merchant", Match: "/order.Order/*", Key: limiter.KeyMerID, Type: limiter.TypeSlidingWindow, Limit: 1},
+	))
+	interceptor := limiterUnaryServerInterceptor(m)
+
+	var calls int
+	invoke := func(method, merID string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(meta.MerID, merID))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				calls++
+				return nil, nil
+			})
+		return err
+	}
+
+	assert.Nil(t, invoke("/order.Order/Query", "m1"))
+
+	err := invoke("/order.Order/Query", "m1")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "merchant")
+
+	assert.Nil(t, invoke("/order.Order/Query", "m2"))
+	assert.Nil(t, invoke("/user.User/Query", "m1"))
+	assert.Equal(t, 3, calls)
+}
@@ -17,6 +17,17 @@ var (
 		[]string{meta.ServiceName, meta.Uri, meta.AppID}...,
 	)
 
+	_serverGRPCStreamTotal = metrics.CreateMetricCount(
+		"grpc_server_streams_total",
+		[]string{meta.ServiceName, meta.Uri, meta.StatusCode}...,
+	)
+
+	// 每条流消息, direction: sent|received
+	_serverGRPCStreamMsg = metrics.CreateMetricCount(
+		"grpc_server_stream_msg_total",
+		[]string{meta.ServiceName, meta.Uri, "direction"}...,
+	)
+
 	_clientGRPCReqQPS = metrics.CreateMetricCount(
 		"grpc_client_requests_QPS",
 		[]string{meta.ServiceName, meta.Uri, meta.AppID, meta.StatusCode}...,
@@ -27,4 +38,20 @@ var (
 		[]float64{5, 10, 25, 50, 100, 250, 500, 1000, 2000},
 		[]string{meta.ServiceName, meta.Uri, meta.AppID}...,
 	)
+
+	// 重试和对冲多发的请求, kind: retry|hedge
+	_clientGRPCReqRetry = metrics.CreateMetricCount(
+		"grpc_client_requests_retry_total",
+		[]string{meta.ServiceName, meta.Uri, meta.StatusCode, "kind"}...,
+	)
+
+	_clientGRPCStreamTotal = metrics.CreateMetricCount(
+		"grpc_client_streams_total",
+		[]string{meta.ServiceName, meta.Uri, meta.StatusCode}...,
+	)
+
+	_clientGRPCStreamMsg = metrics.CreateMetricCount(
+		"grpc_client_stream_msg_total",
+		[]string{meta.ServiceName, meta.Uri, "direction"}...,
+	)
 )
@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/health"
+	"github.com/Hyingerrr/mirco-esim/core/registry"
+	"github.com/Hyingerrr/mirco-esim/grpc/test"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestServer_Registrar(t *testing.T) {
+	defer health.SetReady(true)
+
+	mem := registry.NewMemory(time.Second)
+	defer mem.Close()
+	RegisterDiscovery(mem)
+
+	svr := NewServer(ServerOptions{}.WithRegistrar(mem))
+	svr.config.Addr = "127.0.0.1:0"
+	test.RegisterHelloServerServer(svr.server, &server{})
+	svr.Start()
+
+	eps, err := mem.Resolve(svr.config.AppName)
+	assert.Nil(t, err)
+	assert.Len(t, eps, 1)
+	assert.Equal(t, svr.keeper.Instance().Addr(), eps[0].Addr)
+	assert.Equal(t, "127.0.0.1", svr.keeper.Instance().Host)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, SchemeRegistry+":///"+svr.config.AppName, grpc.WithInsecure(), grpc.WithBlock())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	resp, err := test.NewHelloServerClient(conn).SayGoodbye(ctx, &test.HelloRequest{Name: esim})
+	assert.Nil(t, err)
+	assert.Equal(t, esim+"_en", resp.NameEn)
+
+	svr.GracefulShutDown()
+
+	eps, _ = mem.Resolve(svr.config.AppName)
+	assert.Len(t, eps, 0)
+}
@@ -0,0 +1,399 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/registry"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/fsnotify/fsnotify"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+// Schemes of the resolvers, e.g.
+//
+//	static:///10.0.0.1:50051,10.0.0.2:50051=3
+//	dns-refresh:///order.svc.local:50051
+//	file:///conf/order.endpoints    (relative to the working dir)
+//	file:////etc/esim/order.endpoints
+//	registry:///order-service
+const (
+	SchemeStatic     = "static"
+	SchemeDNSRefresh = "dns-refresh"
+	SchemeFile       = "file"
+	SchemeRegistry   = "registry"
+)
+
+const defaultResolveInterval = 30 * time.Second
+
+func init() {
+	resolver.Register(&staticBuilder{})
+	resolver.Register(&dnsBuilder{lookupHost: net.DefaultResolver.LookupHost})
+	resolver.Register(&fileBuilder{})
+}
+
+// RegisterDiscovery resolves the targets of registry scheme by d.
+func RegisterDiscovery(d registry.Discovery) {
+	resolver.Register(&discoveryBuilder{discovery: d})
+}
+
+type weightKey struct{}
+
+// 相同权重共用 Attributes, 避免 balancer 把地址当作新地址重建连接
+var weightAttrs sync.Map
+
+func toAddresses(eps []registry.Endpoint) []resolver.Address {
+	addrs := make([]resolver.Address, 0, len(eps))
+	for _, ep := range eps {
+		weight := ep.Weight
+		if weight < 1 {
+			weight = 1
+		}
+
+		attrs, _ := weightAttrs.LoadOrStore(weight, attributes.New(weightKey{}, weight))
+		addrs = append(addrs, resolver.Address{Addr: ep.Addr, Attributes: attrs.(*attributes.Attributes)})
+	}
+
+	return addrs
+}
+
+func addrWeight(addr resolver.Address) int {
+	if addr.Attributes == nil {
+		return 1
+	}
+
+	if weight, ok := addr.Attributes.Value(weightKey{}).(int); ok && weight > 0 {
+		return weight
+	}
+
+	return 1
+}
+
+// resolveInterval is grpc_client_resolve_interval, in second.
+func resolveInterval() time.Duration {
+	if config.Default() == nil {
+		return defaultResolveInterval
+	}
+
+	if interval := config.GetDuration("grpc_client_resolve_interval") * time.Second; interval > 0 {
+		return interval
+	}
+
+	return defaultResolveInterval
+}
+
+// serviceOf returns the service name of a registry target.
+func serviceOf(target string) string {
+	prefix := SchemeRegistry + "://"
+	if !strings.HasPrefix(target, prefix) {
+		return ""
+	}
+
+	rest := target[len(prefix):]
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[i+1:]
+	}
+
+	return ""
+}
+
+// esimResolver pushes the endpoints to grpc only when they change.
+type esimResolver struct {
+	cc resolver.ClientConn
+
+	mu   sync.Mutex
+	last []registry.Endpoint
+
+	resolveNow chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stop       func()
+}
+
+func newEsimResolver(cc resolver.ClientConn) *esimResolver {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &esimResolver{
+		cc:         cc,
+		resolveNow: make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+func (r *esimResolver) update(eps []registry.Endpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last != nil && registry.Equal(r.last, eps) {
+		return
+	}
+	r.last = eps
+
+	r.cc.UpdateState(resolver.State{Addresses: toAddresses(eps)})
+}
+
+func (r *esimResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *esimResolver) Close() {
+	r.cancel()
+	if r.stop != nil {
+		r.stop()
+	}
+}
+
+// loop calls resolve at once, then every interval or on ResolveNow.
+func (r *esimResolver) loop(interval time.Duration, resolve func() ([]registry.Endpoint, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		eps, err := resolve()
+		if err != nil {
+			r.cc.ReportError(err)
+		} else {
+			r.update(eps)
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.resolveNow:
+		}
+	}
+}
+
+type staticBuilder struct{}
+
+func (*staticBuilder) Scheme() string {
+	return SchemeStatic
+}
+
+func (*staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var eps []registry.Endpoint
+	for _, s := range strings.Split(target.Endpoint, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		ep, err := registry.ParseEndpoint(s)
+		if err != nil {
+			return nil, fmt.Errorf("static resolver: invalid endpoint %s", s)
+		}
+		eps = append(eps, ep)
+	}
+
+	if len(eps) == 0 {
+		return nil, fmt.Errorf("static resolver: no endpoint in %s", target.Endpoint)
+	}
+
+	r := newEsimResolver(cc)
+	r.update(eps)
+
+	return r, nil
+}
+
+// dnsBuilder looks up the host every interval,
+// the builtin dns resolver only resolves again after a connection fails.
+type dnsBuilder struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+func (*dnsBuilder) Scheme() string {
+	return SchemeDNSRefresh
+}
+
+func (b *dnsBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("dns resolver: %s", err.Error())
+	}
+
+	r := newEsimResolver(cc)
+	go r.loop(resolveInterval(), func() ([]registry.Endpoint, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []registry.Endpoint{{Addr: target.Endpoint, Weight: 1}}, nil
+		}
+
+		ctx, cancel := context.WithTimeout(r.ctx, 5*time.Second)
+		defer cancel()
+
+		ips, err := b.lookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		eps := make([]registry.Endpoint, 0, len(ips))
+		for _, ip := range ips {
+			eps = append(eps, registry.Endpoint{Addr: net.JoinHostPort(ip, port), Weight: 1})
+		}
+
+		return eps, nil
+	})
+
+	return r, nil
+}
+
+// fileBuilder reads the endpoints from a file, one "host:port [weight]"
+// a line, and reads again when the file changes.
+type fileBuilder struct{}
+
+func (*fileBuilder) Scheme() string {
+	return SchemeFile
+}
+
+func (*fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	abs, err := filepath.Abs(target.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	eps, err := readEndpoints(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// 监听目录, 以便捕获 rename 替换的文件
+	if err = watcher.Add(filepath.Dir(abs)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	r := newEsimResolver(cc)
+	r.update(eps)
+
+	reload := func() {
+		eps, err := readEndpoints(abs)
+		if err != nil {
+			logx.Errorf("file resolver: %s", err.Error())
+			r.cc.ReportError(err)
+			return
+		}
+		r.update(eps)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-r.resolveNow:
+				reload()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) != abs && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				reload()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logx.Errorf("file resolver watch %s: %s", abs, err.Error())
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+func readEndpoints(file string) ([]registry.Endpoint, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var eps []registry.Endpoint
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		s := fields[0]
+		if len(fields) > 1 {
+			s += "=" + fields[1]
+		}
+
+		ep, err := registry.ParseEndpoint(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid endpoint %s", file, line)
+		}
+		eps = append(eps, ep)
+	}
+
+	// 写了一半的空文件不生效
+	if len(eps) == 0 {
+		return nil, fmt.Errorf("%s: no endpoint", file)
+	}
+
+	return eps, nil
+}
+
+type discoveryBuilder struct {
+	discovery registry.Discovery
+}
+
+func (*discoveryBuilder) Scheme() string {
+	return SchemeRegistry
+}
+
+func (b *discoveryBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	service := target.Endpoint
+	eps, err := b.discovery.Resolve(service)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newEsimResolver(cc)
+	if len(eps) > 0 {
+		r.update(eps)
+	}
+
+	r.stop, err = b.discovery.Watch(service, func(eps []registry.Endpoint) {
+		if len(eps) == 0 {
+			r.cc.ReportError(fmt.Errorf("registry resolver: no endpoint of %s", service))
+			return
+		}
+		r.update(eps)
+	})
+	if err != nil {
+		r.cancel()
+		return nil, err
+	}
+
+	return r, nil
+}
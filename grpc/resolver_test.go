@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/registry"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeResolverCC struct {
+	resolver.ClientConn
+
+	mu      sync.Mutex
+	states  []resolver.State
+	errs    []error
+	updated chan struct{}
+}
+
+func newFakeResolverCC() *fakeResolverCC {
+	return &fakeResolverCC{updated: make(chan struct{}, 10)}
+}
+
+func (f *fakeResolverCC) UpdateState(state resolver.State) {
+	f.mu.Lock()
+	f.states = append(f.states, state)
+	f.mu.Unlock()
+	f.updated <- struct{}{}
+}
+
+func (f *fakeResolverCC) ReportError(err error) {
+	f.mu.Lock()
+	f.errs = append(f.errs, err)
+	f.mu.Unlock()
+}
+
+// wait returns the addrs and weights of the next state.
+func (f *fakeResolverCC) wait(t *testing.T) map[string]int {
+	select {
+	case <-f.updated:
+	case <-time.After(3 * time.Second):
+		t.Fatal("resolver state not updated")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	addrs := make(map[string]int)
+	for _, addr := range f.states[len(f.states)-1].Addresses {
+		addrs[addr.Addr] = addrWeight(addr)
+	}
+
+	return addrs
+}
+
+func TestResolver_Static(t *testing.T) {
+	cc := newFakeResolverCC()
+	r, err := (&staticBuilder{}).Build(resolver.Target{Endpoint: "10.0.0.1:50051, 10.0.0.2:50051=3"}, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	defer r.Close()
+
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 1, "10.0.0.2:50051": 3}, cc.wait(t))
+
+	_, err = (&staticBuilder{}).Build(resolver.Target{Endpoint: ""}, cc, resolver.BuildOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestResolver_DNSRefresh(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		ips = []string{"10.0.0.1"}
+	)
+
+	b := &dnsBuilder{lookupHost: func(ctx context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ips == nil {
+			return nil, errors.New("no such host")
+		}
+		return ips, nil
+	}}
+
+	cc := newFakeResolverCC()
+	r, err := b.Build(resolver.Target{Endpoint: "order.svc:50051"}, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	defer r.Close()
+
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 1}, cc.wait(t))
+
+	mu.Lock()
+	ips = []string{"10.0.0.1", "10.0.0.2"}
+	mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 1, "10.0.0.2:50051": 1}, cc.wait(t))
+
+	// 解析失败时保留上次的地址
+	mu.Lock()
+	ips = nil
+	mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	time.Sleep(50 * time.Millisecond)
+
+	cc.mu.Lock()
+	assert.Len(t, cc.states, 2)
+	assert.NotEmpty(t, cc.errs)
+	cc.mu.Unlock()
+}
+
+func TestResolver_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim-resolver")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "order.endpoints")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("# order\n10.0.0.1:50051\n10.0.0.2:50051 2\n"), 0600))
+
+	cc := newFakeResolverCC()
+	r, err := (&fileBuilder{}).Build(resolver.Target{Endpoint: file}, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	defer r.Close()
+
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 1, "10.0.0.2:50051": 2}, cc.wait(t))
+
+	// 以 rename 的方式替换文件
+	tmp := filepath.Join(dir, "order.tmp")
+	assert.Nil(t, ioutil.WriteFile(tmp, []byte("10.0.0.3:50051\n"), 0600))
+	assert.Nil(t, os.Rename(tmp, file))
+
+	assert.Equal(t, map[string]int{"10.0.0.3:50051": 1}, cc.wait(t))
+}
+
+type fakeDiscovery struct {
+	mu       sync.Mutex
+	eps      []registry.Endpoint
+	onChange func([]registry.Endpoint)
+	stopped  bool
+}
+
+func (fd *fakeDiscovery) Resolve(service string) ([]registry.Endpoint, error) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.eps, nil
+}
+
+func (fd *fakeDiscovery) Watch(service string, onChange func([]registry.Endpoint)) (func(), error) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	fd.onChange = onChange
+	return func() {
+		fd.mu.Lock()
+		fd.stopped = true
+		fd.mu.Unlock()
+	}, nil
+}
+
+func TestResolver_Registry(t *testing.T) {
+	fd := &fakeDiscovery{eps: []registry.Endpoint{{Addr: "10.0.0.1:50051", Weight: 2}}}
+
+	cc := newFakeResolverCC()
+	r, err := (&discoveryBuilder{discovery: fd}).Build(resolver.Target{Endpoint: "order-service"}, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 2}, cc.wait(t))
+
+	fd.onChange([]registry.Endpoint{{Addr: "10.0.0.1:50051", Weight: 2}, {Addr: "10.0.0.2:50051"}})
+	assert.Equal(t, map[string]int{"10.0.0.1:50051": 2, "10.0.0.2:50051": 1}, cc.wait(t))
+
+	// 没有变化不通知 grpc
+	fd.onChange([]registry.Endpoint{{Addr: "10.0.0.2:50051", Weight: 1}, {Addr: "10.0.0.1:50051", Weight: 2}})
+	assert.Len(t, cc.states, 2)
+
+	r.Close()
+	assert.True(t, fd.stopped)
+}
+
+func TestServiceOf(t *testing.T) {
+	assert.Equal(t, "order-service", serviceOf("registry:///order-service"))
+	assert.Equal(t, "", serviceOf("static:///10.0.0.1:50051"))
+	assert.Equal(t, "", serviceOf(net.JoinHostPort("0.0.0.0", "50051")))
+}
@@ -0,0 +1,280 @@
+package grpc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/core/rpcode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy is an item of grpc_client_retries, e.g.
+//
+//	grpc_client_retries:
+//	  - method: /order.Order/Query
+//	    max_attempts: 3
+//	    codes: [UNAVAILABLE, RESOURCE_EXHAUSTED]
+//	    per_try_timeout: 300
+//	    hedging: true
+type RetryPolicy struct {
+	// 完整方法名; /order.Order/* 匹配服务的所有方法, * 匹配所有方法
+	Method string `mapstructure:"method" validate:"required"`
+
+	// 包含第一次请求, 默认3
+	MaxAttempts int `mapstructure:"max_attempts" validate:"gte=0,lte=10"`
+
+	// 可重试的状态码, 默认 UNAVAILABLE, RESOURCE_EXHAUSTED
+	Codes []string `mapstructure:"codes"`
+
+	// 指数退避; ms, 默认 50, 1000, 2
+	InitialBackoff int     `mapstructure:"initial_backoff" validate:"gte=0"`
+	MaxBackoff     int     `mapstructure:"max_backoff" validate:"gte=0"`
+	Multiplier     float64 `mapstructure:"multiplier" validate:"gte=0"`
+
+	// 退避时间随机浮动的比例, 默认0.2
+	Jitter float64 `mapstructure:"jitter" validate:"gte=0,lte=1"`
+
+	// 每次请求的时限, 不超过整个请求的 deadline; ms, 0 不限制
+	PerTryTimeout int `mapstructure:"per_try_timeout" validate:"gte=0"`
+
+	// 对冲请求, 只能用于幂等的读:
+	// 每隔 hedging_delay 或上一次可重试的失败后再发一次, 取最先成功的响应
+	Hedging bool `mapstructure:"hedging"`
+	// ms, 默认100
+	HedgingDelay int `mapstructure:"hedging_delay" validate:"gte=0"`
+
+	retryCodes map[codes.Code]bool
+}
+
+// init sets the defaults and parses the codes.
+func (p *RetryPolicy) init() error {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+
+	if len(p.Codes) == 0 {
+		p.Codes = []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"}
+	}
+
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = 50
+	}
+
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = 1000
+	}
+
+	if p.Multiplier == 0 {
+		p.Multiplier = 2
+	}
+
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+
+	if p.HedgingDelay == 0 {
+		p.HedgingDelay = 100
+	}
+
+	p.retryCodes = make(map[codes.Code]bool, len(p.Codes))
+	for _, s := range p.Codes {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(strings.TrimSpace(s))))); err != nil {
+			return errors.Errorf("retry policy %s: invalid code %s", p.Method, s)
+		}
+		p.retryCodes[code] = true
+	}
+
+	return nil
+}
+
+// backoff returns the wait before the attempt+1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if max := float64(p.MaxBackoff); backoff > max {
+		backoff = max
+	}
+
+	backoff *= 1 + p.Jitter*(rand.Float64()*2-1)
+
+	return time.Duration(backoff * float64(time.Millisecond))
+}
+
+// retryable reports whether err of an attempt can be retried, ctx is the ctx of the whole request.
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	code := status.Code(errors.Cause(err))
+
+	// 单次超时但整个请求未超时
+	if code == codes.DeadlineExceeded && p.PerTryTimeout > 0 {
+		return true
+	}
+
+	return p.retryCodes[code]
+}
+
+func (p *RetryPolicy) try(ctx context.Context, call func(context.Context) error) error {
+	if p.PerTryTimeout <= 0 {
+		return call(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.PerTryTimeout)*time.Millisecond)
+	defer cancel()
+
+	return call(ctx)
+}
+
+// matchRetryPolicy prefers the full method, then /service/*, then *.
+func matchRetryPolicy(policies []*RetryPolicy, method string) *RetryPolicy {
+	var service, all *RetryPolicy
+
+	for _, p := range policies {
+		switch {
+		case p.Method == method:
+			return p
+		case p.Method == "*":
+			if all == nil {
+				all = p
+			}
+		case strings.HasSuffix(p.Method, "/*") && strings.HasPrefix(method, strings.TrimSuffix(p.Method, "*")):
+			if service == nil {
+				service = p
+			}
+		}
+	}
+
+	if service != nil {
+		return service
+	}
+
+	return all
+}
+
+// retryUnaryClientInterceptor retries within the deadline of timeOutUnaryClientInterceptor,
+// the interceptors after it see every attempt.
+func retryUnaryClientInterceptor(policies []*RetryPolicy, metrics bool) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		policy := matchRetryPolicy(policies, method)
+		if policy == nil || policy.MaxAttempts < 2 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		count := func(kind string, err error) {
+			if metrics {
+				_clientGRPCReqRetry.Inc(container.AppName(), method, rpcode.ExtractCode(errors.Cause(err)).Code, kind)
+			}
+		}
+
+		if _, ok := reply.(proto.Message); ok && policy.Hedging {
+			return hedge(ctx, policy, reply.(proto.Message), count,
+				func(ctx context.Context, reply interface{}) error {
+					return invoker(ctx, method, req, reply, cc, opts...)
+				})
+		}
+
+		for attempt := 1; ; attempt++ {
+			err := policy.try(ctx, func(ctx context.Context) error {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			})
+			if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(ctx, err) {
+				return err
+			}
+
+			backoff := policy.backoff(attempt)
+			// 剩余时间不够重试
+			if dl, ok := ctx.Deadline(); ok && time.Until(dl) <= backoff {
+				return err
+			}
+
+			count("retry", err)
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+type hedgeResult struct {
+	reply proto.Message
+	err   error
+}
+
+// hedge sends the request again every hedging delay or after a retryable failure,
+// until max attempts. The first success wins and cancels the others.
+func hedge(ctx context.Context, policy *RetryPolicy, reply proto.Message, count func(string, error),
+	call func(context.Context, interface{}) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		sent, pending int
+		lastErr       error
+		// 每次请求使用各自的 reply
+		results = make(chan hedgeResult, policy.MaxAttempts)
+		delay   = time.Duration(policy.HedgingDelay) * time.Millisecond
+		next    <-chan time.Time
+	)
+
+	send := func() {
+		sent++
+		pending++
+		next = time.After(delay)
+
+		r := proto.Clone(reply)
+		r.Reset()
+		go func() {
+			err := policy.try(ctx, func(ctx context.Context) error {
+				return call(ctx, r)
+			})
+			results <- hedgeResult{reply: r, err: err}
+		}()
+	}
+
+	send()
+	for {
+		select {
+		case <-next:
+			if sent < policy.MaxAttempts && ctx.Err() == nil {
+				count("hedge", nil)
+				send()
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				reply.Reset()
+				proto.Merge(reply, res.reply)
+				return nil
+			}
+
+			lastErr = res.err
+			if !policy.retryable(ctx, res.err) {
+				return res.err
+			}
+
+			if sent < policy.MaxAttempts {
+				count("retry", res.err)
+				send()
+			} else if pending == 0 {
+				return lastErr
+			}
+		}
+	}
+}
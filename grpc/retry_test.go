@@ -0,0 +1,148 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/grpc/test"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newRetryPolicy(t *testing.T, p *RetryPolicy) *RetryPolicy {
+	assert.Nil(t, p.init())
+	return p
+}
+
+func TestRetryPolicy(t *testing.T) {
+	exact := newRetryPolicy(t, &RetryPolicy{Method: "/order.Order/Query"})
+	service := newRetryPolicy(t, &RetryPolicy{Method: "/order.Order/*"})
+	all := newRetryPolicy(t, &RetryPolicy{Method: "*"})
+	policies := []*RetryPolicy{all, service, exact}
+
+	assert.Equal(t, exact, matchRetryPolicy(policies, "/order.Order/Query"))
+	assert.Equal(t, service, matchRetryPolicy(policies, "/order.Order/Create"))
+	assert.Equal(t, all, matchRetryPolicy(policies, "/pay.Pay/Query"))
+	assert.Nil(t, matchRetryPolicy(policies[1:], "/pay.Pay/Query"))
+
+	assert.Equal(t, 3, exact.MaxAttempts)
+	assert.True(t, exact.retryCodes[codes.Unavailable])
+	assert.True(t, exact.retryCodes[codes.ResourceExhausted])
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		backoff := exact.backoff(attempt)
+		assert.True(t, backoff >= 40*time.Millisecond && backoff <= 1200*time.Millisecond, backoff)
+	}
+
+	assert.NotNil(t, (&RetryPolicy{Method: "*", Codes: []string{"unavailable", "NOT_A_CODE"}}).init())
+}
+
+func TestRetryUnaryClientInterceptor(t *testing.T) {
+	interceptor := retryUnaryClientInterceptor([]*RetryPolicy{
+		newRetryPolicy(t, &RetryPolicy{Method: "/order.Order/*", InitialBackoff: 1, PerTryTimeout: 50}),
+	}, true)
+
+	invoke := func(method string, errs ...error) (int32, error) {
+		var attempts int32
+		err := interceptor(context.Background(), method, nil, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				n := atomic.AddInt32(&attempts, 1)
+				if int(n) > len(errs) {
+					return nil
+				}
+				if errs[n-1] == context.DeadlineExceeded {
+					<-ctx.Done()
+					return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+				}
+				return errs[n-1]
+			})
+		return attempts, err
+	}
+
+	unavailable := handlerErr(status.Error(codes.Unavailable, "unavailable"))
+	exhausted := status.Error(codes.ResourceExhausted, "exhausted")
+
+	attempts, err := invoke("/order.Order/Query", unavailable, exhausted)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), attempts)
+
+	// 超过最大次数
+	attempts, err = invoke("/order.Order/Query", unavailable, unavailable, unavailable)
+	assert.Equal(t, codes.Unavailable, status.Code(errors.Cause(err)))
+	assert.Equal(t, int32(3), attempts)
+
+	// 不可重试的状态码
+	attempts, err = invoke("/order.Order/Query", status.Error(codes.InvalidArgument, "invalid"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, int32(1), attempts)
+
+	// 单次超时后重试
+	attempts, err = invoke("/order.Order/Query", context.DeadlineExceeded)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), attempts)
+
+	// 没有配置的方法不重试
+	attempts, err = invoke("/pay.Pay/Query", unavailable)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), attempts)
+}
+
+func TestRetryUnaryClientInterceptor_Deadline(t *testing.T) {
+	interceptor := retryUnaryClientInterceptor([]*RetryPolicy{
+		newRetryPolicy(t, &RetryPolicy{Method: "*", MaxAttempts: 5, InitialBackoff: 100, Jitter: 0.01}),
+	}, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	var attempts int32
+	err := interceptor(ctx, "/order.Order/Query", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			atomic.AddInt32(&attempts, 1)
+			return status.Error(codes.Unavailable, "unavailable")
+		})
+
+	// 第二次退避 200ms 超过剩余时间
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryUnaryClientInterceptor_Hedging(t *testing.T) {
+	interceptor := retryUnaryClientInterceptor([]*RetryPolicy{
+		newRetryPolicy(t, &RetryPolicy{Method: "/order.Order/Query", Hedging: true, HedgingDelay: 20}),
+	}, true)
+
+	var (
+		attempts int32
+		canceled = make(chan struct{})
+	)
+
+	reply := &test.HelloResponse{NameEn: "stale"}
+	err := interceptor(context.Background(), "/order.Order/Query", nil, reply, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			// 第一次请求很慢, 对冲的请求先返回
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				<-ctx.Done()
+				close(canceled)
+				return status.Error(codes.Canceled, ctx.Err().Error())
+			}
+			reply.(*test.HelloResponse).NameEn = "hedged"
+			return nil
+		})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hedged", reply.NameEn)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow attempt is not canceled")
+	}
+}
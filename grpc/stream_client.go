@@ -0,0 +1,263 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	"github.com/Hyingerrr/mirco-esim/core/rpcode"
+	"github.com/Hyingerrr/mirco-esim/core/tracer"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	opentracinglog "github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// clientStream calls the hooks after each message,
+// and calls finish once when the stream ends, i.e. RecvMsg returns io.EOF or an error,
+// or Header and SendMsg return an error other than io.EOF, the same as grpc.ClientStream.
+// No goroutine watches ctx, a caller abandoning the stream must cancel ctx
+// and call RecvMsg once, or finish is not called.
+type clientStream struct {
+	grpc.ClientStream
+
+	desc *grpc.StreamDesc
+
+	onSend func(m interface{}, err error)
+	onRecv func(m interface{}, err error)
+
+	finishOnce sync.Once
+	finish     func(err error)
+}
+
+func wrapClientStream(cs grpc.ClientStream, desc *grpc.StreamDesc, finish func(err error)) *clientStream {
+	return &clientStream{ClientStream: cs, desc: desc, finish: finish}
+}
+
+func (cs *clientStream) done(err error) {
+	cs.finishOnce.Do(func() {
+		if cs.finish != nil {
+			cs.finish(err)
+		}
+	})
+}
+
+func (cs *clientStream) Header() (metadata.MD, error) {
+	md, err := cs.ClientStream.Header()
+	if err != nil {
+		cs.done(err)
+	}
+
+	return md, err
+}
+
+func (cs *clientStream) SendMsg(m interface{}) error {
+	err := cs.ClientStream.SendMsg(m)
+	if cs.onSend != nil {
+		cs.onSend(m, err)
+	}
+
+	// io.EOF 表示流已终止, 真正的错误由 RecvMsg 返回
+	if err != nil && err != io.EOF {
+		cs.done(err)
+	}
+
+	return err
+}
+
+func (cs *clientStream) RecvMsg(m interface{}) error {
+	err := cs.ClientStream.RecvMsg(m)
+	if cs.onRecv != nil {
+		cs.onRecv(m, err)
+	}
+
+	switch {
+	case err == io.EOF:
+		cs.done(nil)
+	case err != nil:
+		cs.done(err)
+	case !cs.desc.ServerStreams:
+		// 服务端只回一条消息
+		cs.done(nil)
+	}
+
+	return err
+}
+
+// timeOutStreamClientInterceptor limits the whole stream by WithTimeout
+// or the timeout, a stream lives as long as ctx if both are 0.
+func timeOutStreamClientInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamTimeout := timeout
+		for _, opt := range opts {
+			if timeOpt, ok := opt.(*TimeoutCallOption); ok && timeOpt.Timeout > 0 {
+				streamTimeout = timeOpt.Timeout
+				break
+			}
+		}
+
+		if streamTimeout <= 0 {
+			cs, err := streamer(ctx, desc, cc, method, opts...)
+			return cs, handlerErr(err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, streamTimeout)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, handlerErr(err)
+		}
+
+		return wrapClientStream(cs, desc, func(error) { cancel() }), nil
+	}
+}
+
+// metadataStreamHandler sets the metadata not bound to a request,
+// the stream is opened before any message.
+func metadataStreamHandler() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md := metadata.MD{}
+		md.Set(meta.Protocol, meta.RPCProtocol)
+		if config.Default() != nil {
+			md.Set(meta.SrcSysId, config.GetString("appname"))
+		}
+		md.Set(meta.TraceID, fmt.Sprintf("%v", time.Now().UnixNano()))
+
+		// 已有的 metadata 优先
+		if oldmd, ok := metadata.FromOutgoingContext(ctx); ok {
+			for k, v := range oldmd {
+				md[k] = v
+			}
+		}
+
+		return streamer(metadata.NewOutgoingContext(ctx, md), desc, cc, method, opts...)
+	}
+}
+
+func metricStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var (
+			beg        = time.Now()
+			serverName = container.AppName()
+			appID      string
+		)
+
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			if ai := md.Get(meta.AppID); len(ai) > 0 {
+				appID = ai[0]
+			}
+		}
+
+		observe := func(err error) {
+			rpcStatus := rpcode.ExtractCode(err)
+			_clientGRPCReqDuration.Observe(float64(time.Since(beg)/time.Millisecond), serverName, method, appID)
+			_clientGRPCReqQPS.Inc(serverName, method, appID, rpcStatus.Code)
+			_clientGRPCStreamTotal.Inc(serverName, method, rpcStatus.Code)
+		}
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			observe(err)
+			return nil, handlerErr(err)
+		}
+
+		ws := wrapClientStream(cs, desc, observe)
+		ws.onSend = func(m interface{}, err error) {
+			if err == nil {
+				_clientGRPCStreamMsg.Inc(serverName, method, msgSent)
+			}
+		}
+		ws.onRecv = func(m interface{}, err error) {
+			if err == nil {
+				_clientGRPCStreamMsg.Inc(serverName, method, msgReceived)
+			}
+		}
+
+		return ws, nil
+	}
+}
+
+func debugStreamClientInterceptor(slowTime time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		beg := time.Now()
+
+		logx.Infoc(ctx, "GRPC_Stream_Open: method[%v], target[%v]", method, cc.Target())
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logx.Errorc(ctx, "GRPC_Stream_Open_Failed: method[%v], target[%v], err[%v]", method, cc.Target(), err)
+			return nil, handlerErr(err)
+		}
+
+		ws := wrapClientStream(cs, desc, func(err error) {
+			sub := time.Since(beg)
+			logx.Infoc(ctx, "GRPC_Stream_Close: method[%v], target[%v], cost[%v], err[%v]",
+				method, cc.Target(), sub, err)
+
+			if sub > slowTime {
+				logx.Warnc(ctx, "Slow_Client_GRPC stream: %s, target: %v, cost: %v", method, cc.Target(), sub)
+			}
+		})
+		ws.onSend = func(m interface{}, err error) {
+			if err == nil {
+				logx.Infoc(ctx, "GRPC_Stream_Send_Msg: method[%v], params:%+v", method, logx.Mask(m))
+			}
+		}
+		ws.onRecv = func(m interface{}, err error) {
+			if err == nil {
+				logx.Infoc(ctx, "GRPC_Stream_Recv_Msg: method[%v], params:%+v", method, logx.Mask(m))
+			}
+		}
+
+		return ws, nil
+	}
+}
+
+func traceStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := opentracing.StartSpanFromContext(
+			ctx,
+			method,
+			tracer.TagComponent("gRPC"),
+			tracer.TagLocalIPV4(),
+			ext.SpanKindRPCClient,
+		)
+
+		cs, err := streamer(injectSpanContext(ctx, span), desc, cc, method, opts...)
+		if err != nil {
+			finishStreamSpan(span, err)
+			span.Finish()
+			return nil, err
+		}
+
+		ws := wrapClientStream(cs, desc, func(err error) {
+			finishStreamSpan(span, err)
+			span.Finish()
+		})
+
+		var sent, received int64
+		ws.onSend = func(m interface{}, err error) {
+			if err == nil {
+				span.LogFields(opentracinglog.String("event", "message_"+msgSent),
+					opentracinglog.Int64("seq", atomic.AddInt64(&sent, 1)))
+			}
+		}
+		ws.onRecv = func(m interface{}, err error) {
+			if err == nil {
+				span.LogFields(opentracinglog.String("event", "message_"+msgReceived),
+					opentracinglog.Int64("seq", atomic.AddInt64(&received, 1)))
+			}
+		}
+
+		return ws, nil
+	}
+}
@@ -0,0 +1,294 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	"github.com/Hyingerrr/mirco-esim/core/rpcode"
+	"github.com/Hyingerrr/mirco-esim/core/tracer"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+	tracerid "github.com/Hyingerrr/mirco-esim/pkg/tracer-id"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	opentracinglog "github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Direction of a stream message.
+const (
+	msgSent     = "sent"
+	msgReceived = "received"
+)
+
+// serverStream replaces the context of grpc.ServerStream,
+// and calls the hooks after each message.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+
+	onSend func(m interface{}, err error)
+	onRecv func(m interface{}, err error) error
+}
+
+func wrapServerStream(ss grpc.ServerStream) *serverStream {
+	return &serverStream{ServerStream: ss, ctx: ss.Context()}
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) SendMsg(m interface{}) error {
+	err := ss.ServerStream.SendMsg(m)
+	if ss.onSend != nil {
+		ss.onSend(m, err)
+	}
+
+	return err
+}
+
+func (ss *serverStream) RecvMsg(m interface{}) error {
+	err := ss.ServerStream.RecvMsg(m)
+	if ss.onRecv != nil {
+		return ss.onRecv(m, err)
+	}
+
+	return err
+}
+
+// stream handler chain
+func (gs *Server) streamHandlerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	var (
+		i     int
+		chain grpc.StreamHandler
+	)
+
+	n := len(gs.streamInterceptors)
+	if n == 0 {
+		return handler(srv, ss)
+	}
+
+	chain = func(isrv interface{}, iss grpc.ServerStream) error {
+		if i == n-1 {
+			return handler(isrv, iss)
+		}
+		i++
+		return gs.streamInterceptors[i](isrv, iss, info, chain)
+	}
+
+	return gs.streamInterceptors[0](srv, ss, info, chain)
+}
+
+func (gs *Server) UseStream(interceptors ...grpc.StreamServerInterceptor) *Server {
+	finalSize := len(gs.streamInterceptors) + len(interceptors)
+	if finalSize >= _abortIndex {
+		panic("ESIM: server use too many stream interceptors")
+	}
+
+	mergedHandlers := make([]grpc.StreamServerInterceptor, finalSize)
+	copy(mergedHandlers, gs.streamInterceptors)
+	copy(mergedHandlers[len(gs.streamInterceptors):], interceptors)
+	gs.streamInterceptors = mergedHandlers
+	return gs
+}
+
+func recoverStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverFrom(r, info.FullMethod)
+			}
+		}()
+		err = handler(srv, ss)
+		return
+	}
+}
+
+func tracerIDStreamServerInterceptor() grpc.StreamServerInterceptor {
+	tracerID := tracerid.TracerID()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if sp := opentracing.SpanFromContext(ss.Context()); sp != nil {
+			return handler(srv, ss)
+		}
+
+		ws := wrapServerStream(ss)
+		ws.ctx = context.WithValue(ws.ctx, tracerid.ActiveEsimKey, tracerID())
+		return handler(srv, ws)
+	}
+}
+
+// timeoutStreamServerInterceptor limits the whole stream,
+// a stream lives as long as the client deadline if timeout is 0.
+func timeoutStreamServerInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if timeout <= 0 {
+			return handler(srv, ss)
+		}
+
+		ws := wrapServerStream(ss)
+		var cancel context.CancelFunc
+		ws.ctx, cancel = context.WithTimeout(ws.ctx, timeout)
+		defer cancel()
+
+		return handler(srv, ws)
+	}
+}
+
+func debugStreamServerInterceptor(slowTime time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		var (
+			start = time.Now()
+			ws    = wrapServerStream(ss)
+			ctx   = ws.ctx
+		)
+
+		logx.Infoc(ctx, "Stream_Open: method[%v], client_stream[%v], server_stream[%v]",
+			info.FullMethod, info.IsClientStream, info.IsServerStream)
+
+		ws.onRecv = func(m interface{}, err error) error {
+			if err == nil {
+				logx.Infoc(ctx, "Stream_Recv_Msg: method[%v], body:%+v", info.FullMethod, logx.Mask(m))
+			}
+			return err
+		}
+		ws.onSend = func(m interface{}, err error) {
+			if err == nil {
+				logx.Infoc(ctx, "Stream_Send_Msg: method[%v], body:%+v", info.FullMethod, logx.Mask(m))
+			}
+		}
+
+		err := handler(srv, ws)
+
+		logx.Infoc(ctx, "Stream_Close: method[%v], cost[%v], err[%v]", info.FullMethod, time.Since(start).String(), err)
+
+		// check grpc slow
+		if sub := time.Since(start); sub > slowTime*time.Millisecond {
+			logx.Warnc(ctx, "Slow stream server %s, cost:%v", info.FullMethod, sub)
+		}
+
+		return err
+	}
+}
+
+// validateStreamServerInterceptor validates every received message.
+func validateStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ws := wrapServerStream(ss)
+		ws.onRecv = func(m interface{}, err error) error {
+			if err != nil {
+				return err
+			}
+			if err = checker.ValidateStruct(m); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			return nil
+		}
+
+		return handler(srv, ws)
+	}
+}
+
+func metricStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	var (
+		start       = time.Now()
+		serviceName = container.AppName()
+		appId       string
+		ws          = wrapServerStream(ss)
+	)
+
+	if mdCtx, ok := metadata.FromIncomingContext(ss.Context()); ok {
+		if ai := mdCtx.Get(meta.AppID); len(ai) > 0 {
+			appId = ai[0]
+		}
+	}
+
+	_serverGRPCReqQPS.Inc(serviceName, info.FullMethod, appId)
+
+	ws.onRecv = func(m interface{}, err error) error {
+		if err == nil {
+			_serverGRPCStreamMsg.Inc(serviceName, info.FullMethod, msgReceived)
+		}
+		return err
+	}
+	ws.onSend = func(m interface{}, err error) {
+		if err == nil {
+			_serverGRPCStreamMsg.Inc(serviceName, info.FullMethod, msgSent)
+		}
+	}
+
+	err := handler(srv, ws)
+
+	_serverGRPCReqDuration.Observe(float64(time.Since(start)/time.Millisecond),
+		serviceName, info.FullMethod, appId)
+	_serverGRPCStreamTotal.Inc(serviceName, info.FullMethod, rpcode.ExtractCode(err).Code)
+
+	return err
+}
+
+func traceStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	esimTracer := opentracing.GlobalTracer()
+	md, ok := metadata.FromIncomingContext(ss.Context())
+	if !ok {
+		md = metadata.New(nil)
+	}
+	spCtx, err := esimTracer.Extract(opentracing.TextMap, tracer.MetadataReaderWriter{MD: md})
+	if err != nil && err != opentracing.ErrSpanContextNotFound {
+		logx.Errorc(ss.Context(), "extract from metadata err:%v", err)
+		return handler(srv, ss)
+	}
+
+	span := esimTracer.StartSpan(
+		info.FullMethod,
+		ext.RPCServerOption(spCtx),
+		tracer.TagComponent("gRPC"),
+		ext.SpanKindRPCServer,
+		tracer.TagLocalIPV4(),
+	)
+	defer span.Finish()
+
+	ws := wrapServerStream(ss)
+	ws.ctx = opentracing.ContextWithSpan(ws.ctx, span)
+
+	var sent, received int64
+	ws.onRecv = func(m interface{}, err error) error {
+		if err == nil {
+			span.LogFields(opentracinglog.String("event", "message_"+msgReceived),
+				opentracinglog.Int64("seq", atomic.AddInt64(&received, 1)))
+		}
+		return err
+	}
+	ws.onSend = func(m interface{}, err error) {
+		if err == nil {
+			span.LogFields(opentracinglog.String("event", "message_"+msgSent),
+				opentracinglog.Int64("seq", atomic.AddInt64(&sent, 1)))
+		}
+	}
+
+	err = handler(srv, ws)
+	finishStreamSpan(span, err)
+
+	return err
+}
+
+func finishStreamSpan(span opentracing.Span, err error) {
+	if err == nil {
+		span.SetTag("gRPC_code", codes.OK)
+		return
+	}
+
+	code := codes.Unknown
+	if s, ok := status.FromError(err); ok {
+		code = s.Code()
+	}
+	span.SetTag("gRPC_code", code)
+	ext.Error.Set(span, true)
+	span.LogFields(opentracinglog.String("event", "error"), opentracinglog.String("message", err.Error()))
+}
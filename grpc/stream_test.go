@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/grpc/test"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const echoMethod = "/test.Echo/Echo"
+
+var echoStream = grpc.StreamDesc{
+	StreamName:    "Echo",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+var echoStreamDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    echoStream.StreamName,
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler:       echoHandler,
+	}},
+}
+
+func echoHandler(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		in := new(test.HelloRequest)
+		err := stream.RecvMsg(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if in.Name == callPanic {
+			panic(isTest)
+		}
+
+		if err = stream.SendMsg(&test.HelloResponse{NameEn: in.Name + "_en", AgeEn: in.Age}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStream_Echo(t *testing.T) {
+	ctx := context.Background()
+	conn := NewClient(clientOpt).DialContext(ctx, tcpAddr.String())
+	defer conn.Close()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "appid", "QY0002")
+	stream, err := conn.NewStream(ctx, &echoStream, echoMethod)
+	assert.Nil(t, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, stream.SendMsg(&test.HelloRequest{Name: name, Age: 1}))
+
+		out := new(test.HelloResponse)
+		assert.Nil(t, stream.RecvMsg(out))
+		assert.Equal(t, name+"_en", out.NameEn)
+	}
+
+	assert.Nil(t, stream.CloseSend())
+	assert.Equal(t, io.EOF, stream.RecvMsg(new(test.HelloResponse)))
+}
+
+func TestStream_Recover(t *testing.T) {
+	ctx := context.Background()
+	conn := NewClient(clientOpt).DialContext(ctx, tcpAddr.String())
+	defer conn.Close()
+
+	stream, err := conn.NewStream(ctx, &echoStream, echoMethod)
+	assert.Nil(t, err)
+
+	assert.Nil(t, stream.SendMsg(&test.HelloRequest{Name: callPanic}))
+	err = stream.RecvMsg(new(test.HelloResponse))
+	assert.Equal(t, codes.Unknown, status.Code(err))
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent int
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream) SendMsg(m interface{}) error {
+	f.sent++
+	return nil
+}
+
+func TestServer_UseStream(t *testing.T) {
+	type ctxKey string
+	var order []string
+
+	gs := &Server{}
+	gs.UseStream(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		order = append(order, "first")
+		ws := wrapServerStream(ss)
+		ws.ctx = context.WithValue(ws.ctx, ctxKey("k"), "v")
+		ws.onSend = func(m interface{}, err error) {
+			order = append(order, "sent")
+		}
+		return handler(srv, ws)
+	}, func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		order = append(order, "second")
+		return handler(srv, ss)
+	})
+
+	fss := &fakeServerStream{ctx: context.Background()}
+	err := gs.streamHandlerInterceptor(nil, fss, &grpc.StreamServerInfo{FullMethod: echoMethod},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			order = append(order, "handler")
+			assert.Equal(t, "v", ss.Context().Value(ctxKey("k")))
+			return ss.SendMsg(nil)
+		})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fss.sent)
+	assert.Equal(t, []string{"first", "second", "handler", "sent"}, order)
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	msgs int
+}
+
+func (f *fakeClientStream) RecvMsg(m interface{}) error {
+	if f.msgs == 0 {
+		return io.EOF
+	}
+	f.msgs--
+	return nil
+}
+
+func TestClientStream_Finish(t *testing.T) {
+	var finished []error
+	ws := wrapClientStream(&fakeClientStream{msgs: 2}, &echoStream, func(err error) {
+		finished = append(finished, err)
+	})
+
+	assert.Nil(t, ws.RecvMsg(nil))
+	assert.Nil(t, ws.RecvMsg(nil))
+	assert.Empty(t, finished)
+
+	assert.Equal(t, io.EOF, ws.RecvMsg(nil))
+	assert.Equal(t, io.EOF, ws.RecvMsg(nil))
+	assert.Equal(t, []error{nil}, finished)
+}
@@ -0,0 +1,317 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+	"github.com/Hyingerrr/mirco-esim/pkg/security"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const defaultTLSReloadInterval = 60 * time.Second
+
+// TLSConfig of the grpc server or client, the keys are under
+// grpc_server_tls or grpc_client_tls, e.g. grpc_server_tls_cert_file.
+type TLSConfig struct {
+	Enable bool
+
+	CertFile string
+	KeyFile  string
+	// pkcs12 证书, 优先于 CertFile 和 KeyFile
+	PfxFile     string
+	PfxPassword string
+
+	// server 用于验证客户端证书, client 用于验证服务端证书
+	CAFile string
+
+	// server: none|request|verify_if_given|require
+	ClientAuth string
+
+	// client
+	ServerName         string
+	InsecureSkipVerify bool
+
+	// 检查证书文件是否更新的间隔, 负数不检查; s
+	ReloadInterval time.Duration
+}
+
+func newTLSConfig(prefix string) *TLSConfig {
+	tc := &TLSConfig{}
+	tc.Enable = config.GetBool(prefix)
+	tc.CertFile = config.GetString(prefix + "_cert_file")
+	tc.KeyFile = config.GetString(prefix + "_key_file")
+	tc.PfxFile = config.GetString(prefix + "_pfx_file")
+	tc.PfxPassword = config.GetString(prefix + "_pfx_password")
+	tc.CAFile = config.GetString(prefix + "_ca_file")
+	tc.ClientAuth = config.GetString(prefix + "_client_auth")
+	tc.ServerName = config.GetString(prefix + "_server_name")
+	tc.InsecureSkipVerify = config.GetBool(prefix + "_insecure_skip_verify")
+
+	tc.ReloadInterval = config.GetDuration(prefix+"_reload_interval") * time.Second
+	if tc.ReloadInterval == 0 {
+		tc.ReloadInterval = defaultTLSReloadInterval
+	}
+
+	return tc
+}
+
+func (tc *TLSConfig) files() []string {
+	var files []string
+	for _, file := range []string{tc.PfxFile, tc.CertFile, tc.KeyFile, tc.CAFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+func (tc *TLSConfig) clientAuth() (tls.ClientAuthType, error) {
+	switch strings.ToLower(tc.ClientAuth) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth %s", tc.ClientAuth)
+	}
+}
+
+// build loads the certificates into a new tls.Config.
+func (tc *TLSConfig) build(server bool) (*tls.Config, error) {
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	switch {
+	case tc.PfxFile != "":
+		cert, err = security.LoadPKCS12KeyPair(tc.PfxFile, tc.PfxPassword)
+	case tc.CertFile != "":
+		cert, err = tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+	case server:
+		err = fmt.Errorf("no certificate")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cert.Certificate) > 0 {
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	if tc.CAFile != "" {
+		pool, err := security.LoadCertPool(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if server {
+			tlsConf.ClientCAs = pool
+		} else {
+			tlsConf.RootCAs = pool
+		}
+	}
+
+	if server {
+		if tlsConf.ClientAuth, err = tc.clientAuth(); err != nil {
+			return nil, err
+		}
+	} else {
+		tlsConf.ServerName = tc.ServerName
+		tlsConf.InsecureSkipVerify = tc.InsecureSkipVerify
+	}
+
+	return tlsConf, nil
+}
+
+// tlsReloader rebuilds the tls.Config when the certificate files change,
+// the files are checked by the handshakes at most once an interval.
+type tlsReloader struct {
+	conf   *TLSConfig
+	server bool
+
+	mu        sync.Mutex
+	tlsConf   *tls.Config
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newTLSReloader(conf *TLSConfig, server bool) (*tlsReloader, error) {
+	r := &tlsReloader{conf: conf, server: server}
+
+	tlsConf, err := conf.build(server)
+	if err != nil {
+		return nil, err
+	}
+
+	r.tlsConf = tlsConf
+	r.modTimes = r.stat()
+	r.lastCheck = time.Now()
+
+	return r, nil
+}
+
+func (r *tlsReloader) stat() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.conf.files() {
+		if fi, err := os.Stat(file); err == nil {
+			modTimes[file] = fi.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+func (r *tlsReloader) current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conf.ReloadInterval < 0 || time.Since(r.lastCheck) < r.conf.ReloadInterval {
+		return r.tlsConf
+	}
+	r.lastCheck = time.Now()
+
+	modTimes := r.stat()
+	changed := len(modTimes) != len(r.modTimes)
+	for file, modTime := range modTimes {
+		if !r.modTimes[file].Equal(modTime) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return r.tlsConf
+	}
+
+	// 证书可能只更新了一半, 失败时沿用旧的, 下次再试
+	tlsConf, err := r.conf.build(r.server)
+	if err != nil {
+		logx.Errorf("reload grpc tls certificates: %s", err.Error())
+		return r.tlsConf
+	}
+
+	logx.Infof("grpc tls certificates reloaded: %s", strings.Join(r.conf.files(), ", "))
+	r.tlsConf = tlsConf
+	r.modTimes = modTimes
+
+	return r.tlsConf
+}
+
+type tlsCreds struct {
+	*tlsReloader
+
+	serverName string
+}
+
+// NewServerTLSCreds returns the credentials reloading the certificates.
+func NewServerTLSCreds(conf *TLSConfig) (credentials.TransportCredentials, error) {
+	r, err := newTLSReloader(conf, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlsCreds{tlsReloader: r}, nil
+}
+
+// NewClientTLSCreds returns the credentials reloading the certificates.
+func NewClientTLSCreds(conf *TLSConfig) (credentials.TransportCredentials, error) {
+	r, err := newTLSReloader(conf, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlsCreds{tlsReloader: r, serverName: conf.ServerName}, nil
+}
+
+func (c *tlsCreds) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	tlsConf := c.current()
+	if c.serverName != "" && c.serverName != tlsConf.ServerName {
+		tlsConf = tlsConf.Clone()
+		tlsConf.ServerName = c.serverName
+	}
+
+	return credentials.NewTLS(tlsConf).ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c *tlsCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.current()).ServerHandshake(rawConn)
+}
+
+func (c *tlsCreds) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       c.serverName,
+	}
+}
+
+func (c *tlsCreds) Clone() credentials.TransportCredentials {
+	return &tlsCreds{tlsReloader: c.tlsReloader, serverName: c.serverName}
+}
+
+func (c *tlsCreds) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
+
+// peerIdentity puts the CN and SANs of the client certificate into meta.
+func peerIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return ctx
+	}
+
+	cert := tlsInfo.State.PeerCertificates[0]
+	sans := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+
+	md, _ := meta.FromContext(ctx)
+	return meta.NewContext(ctx, meta.Join(md, meta.MD{
+		meta.PeerCN:  cert.Subject.CommonName,
+		meta.PeerSAN: sans,
+	}))
+}
+
+func peerIdentityServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(peerIdentity(ctx), req)
+	}
+}
+
+func peerIdentityStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ws := wrapServerStream(ss)
+		ws.ctx = peerIdentity(ws.ctx)
+		return handler(srv, ws)
+	}
+}
@@ -0,0 +1,230 @@
+package grpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "esim-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes the cert and key signed by the ca into dir.
+func (ca *testCA) issue(t *testing.T, dir, name, cn string, uris ...string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		assert.Nil(t, err)
+		tmpl.URIs = append(tmpl.URIs, parsed)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certFile, keyFile
+}
+
+type identityHealth struct {
+	healthpb.UnimplementedHealthServer
+
+	mu   sync.Mutex
+	cn   string
+	sans []string
+}
+
+func (ih *identityHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	ih.mu.Lock()
+	ih.cn = meta.String(ctx, meta.PeerCN)
+	ih.sans = meta.Strings(ctx, meta.PeerSAN)
+	ih.mu.Unlock()
+
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func startTLSServer(t *testing.T, conf *TLSConfig) (string, *identityHealth, func()) {
+	creds, err := NewServerTLSCreds(conf)
+	assert.Nil(t, err)
+
+	svr := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(peerIdentityServerInterceptor()))
+	ih := &identityHealth{}
+	healthpb.RegisterHealthServer(svr, ih)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		_ = svr.Serve(lis)
+	}()
+
+	return lis.Addr().String(), ih, svr.Stop
+}
+
+func dialTLS(t *testing.T, addr string, conf *TLSConfig) (*grpc.ClientConn, error) {
+	creds, err := NewClientTLSCreds(conf)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds), grpc.WithBlock())
+}
+
+func TestTLS_MutualIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.Nil(t, ioutil.WriteFile(caFile, ca.pem, 0600))
+
+	serverCert, serverKey := ca.issue(t, dir, "server", "esim-server")
+	clientCert, clientKey := ca.issue(t, dir, "client", "order-service", "spiffe://esim/order")
+
+	addr, ih, stop := startTLSServer(t, &TLSConfig{
+		CertFile:   serverCert,
+		KeyFile:    serverKey,
+		CAFile:     caFile,
+		ClientAuth: "require",
+	})
+	defer stop()
+
+	conn, err := dialTLS(t, addr, &TLSConfig{
+		CertFile:   clientCert,
+		KeyFile:    clientKey,
+		CAFile:     caFile,
+		ServerName: "localhost",
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+
+	ih.mu.Lock()
+	assert.Equal(t, "order-service", ih.cn)
+	assert.Equal(t, []string{"localhost", "127.0.0.1", "spiffe://esim/order"}, ih.sans)
+	ih.mu.Unlock()
+
+	// 没有客户端证书
+	noCert, err := NewClientTLSCreds(&TLSConfig{CAFile: caFile, ServerName: "localhost"})
+	assert.Nil(t, err)
+	anonymous, err := grpc.Dial(addr, grpc.WithTransportCredentials(noCert))
+	assert.Nil(t, err)
+	defer anonymous.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(anonymous).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NotNil(t, err)
+}
+
+func TestTLS_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.Nil(t, ioutil.WriteFile(caFile, ca.pem, 0600))
+
+	serverCert, serverKey := ca.issue(t, dir, "server", "server-1")
+
+	addr, _, stop := startTLSServer(t, &TLSConfig{
+		CertFile:       serverCert,
+		KeyFile:        serverKey,
+		ReloadInterval: time.Nanosecond,
+	})
+	defer stop()
+
+	serverCN := func() string {
+		conn, err := dialTLS(t, addr, &TLSConfig{CAFile: caFile, ServerName: "localhost"})
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		p := &peer.Peer{}
+		_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Peer(p))
+		assert.Nil(t, err)
+
+		return p.AuthInfo.(credentials.TLSInfo).State.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "server-1", serverCN())
+
+	ca.issue(t, dir, "server", "server-2")
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(serverCert, later, later))
+	assert.Nil(t, os.Chtimes(serverKey, later, later))
+
+	assert.Equal(t, "server-2", serverCN())
+
+	// 证书损坏时沿用旧的
+	assert.Nil(t, ioutil.WriteFile(serverCert, []byte("broken"), 0600))
+	later = later.Add(time.Minute)
+	assert.Nil(t, os.Chtimes(serverCert, later, later))
+
+	assert.Equal(t, "server-2", serverCN())
+}
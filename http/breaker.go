@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/core/breaker"
+)
+
+// BreakerFallback is called when the breaker rejects the request or the request fails,
+// err is breaker.ErrOpen if rejected.
+type BreakerFallback func(req *http.Request, err error) (*http.Response, error)
+
+// BreakerKeyFunc names the breaker of the request, e.g. the host and a route template
+// like "api.example.com/users/:id". The keys should be bounded,
+// each key holds a breaker and a circuit_breaker_state series.
+type BreakerKeyFunc func(req *http.Request) string
+
+// BreakerKeyByHost is the default BreakerKeyFunc.
+func BreakerKeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+type breakerTransport struct {
+	next     http.RoundTripper
+	group    *breaker.Group
+	fallback BreakerFallback
+	key      BreakerKeyFunc
+}
+
+// NewBreakerTransport breaks by key, the network errors,
+// 5xx and 429 are the failures. fallback may be nil, key is BreakerKeyByHost if nil.
+func NewBreakerTransport(next http.RoundTripper, group *breaker.Group, fallback BreakerFallback, key BreakerKeyFunc) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if key == nil {
+		key = BreakerKeyByHost
+	}
+
+	return &breakerTransport{next: next, group: group, fallback: fallback, key: key}
+}
+
+func (bt *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := bt.group.Allow(bt.key(req))
+	if err != nil {
+		return bt.fail(req, err)
+	}
+
+	beg := time.Now()
+	resp, err := bt.next.RoundTrip(req)
+
+	failed := err != nil ||
+		resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+	done(failed, time.Since(beg))
+
+	if err != nil {
+		return bt.fail(req, err)
+	}
+
+	return resp, nil
+}
+
+func (bt *breakerTransport) fail(req *http.Request, err error) (*http.Response, error) {
+	if bt.fallback != nil {
+		return bt.fallback(req, err)
+	}
+
+	return nil, err
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/Hyingerrr/mirco-esim/config"
 
 	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/core/breaker"
 	logx "github.com/Hyingerrr/mirco-esim/log"
 
 	"github.com/opentracing/opentracing-go/ext"
@@ -25,6 +26,10 @@ type Client struct {
 	transports []func() interface{}
 	isTrace    bool
 	isMetric   bool
+
+	breaker         *breaker.Group
+	breakerFallback BreakerFallback
+	breakerKey      BreakerKeyFunc
 }
 
 type Options func(*Client)
@@ -43,6 +48,14 @@ func NewClient(opts ...Options) *Client {
 	c.isMetric = config.GetBool("http_client_metrics")
 	c.isTrace = config.GetBool("http_client_tracer")
 
+	if c.breaker == nil && config.GetBool("http_client_breaker") {
+		c.breaker = breaker.NewGroup()
+	}
+
+	if c.breaker != nil {
+		c.SetTransport(c.client.GetClient().Transport)
+	}
+
 	return c
 }
 
@@ -58,6 +71,23 @@ func WithTimeOut(timeout time.Duration) Options {
 	}
 }
 
+// WithBreaker breaks by host, http_client_breaker uses a group of the breaker_* config.
+// fallback may be nil. The transport is wrapped, so use SetTransport instead of
+// the proxy or tls setters of resty, which require an *http.Transport.
+func WithBreaker(group *breaker.Group, fallback BreakerFallback) Options {
+	return func(c *Client) {
+		c.breaker = group
+		c.breakerFallback = fallback
+	}
+}
+
+// WithBreakerKey breaks by key instead of host, e.g. by the route templates of a host.
+func WithBreakerKey(key BreakerKeyFunc) Options {
+	return func(c *Client) {
+		c.breakerKey = key
+	}
+}
+
 func (c *Client) RC() *resty.Client {
 	return c.client
 }
@@ -67,7 +97,17 @@ func (c *Client) SetTimeout(ttl time.Duration) *Client {
 	return c
 }
 
+// SetTransport wraps transport with the breaker, e.g. c.RC().GetClient().Transport
+// is unwrapped first, so it is not wrapped twice.
 func (c *Client) SetTransport(transport http.RoundTripper) *Client {
+	if bt, ok := transport.(*breakerTransport); ok {
+		transport = bt.next
+	}
+
+	if c.breaker != nil {
+		transport = NewBreakerTransport(transport, c.breaker, c.breakerFallback, c.breakerKey)
+	}
+
 	c.client.SetTransport(transport)
 	return c
 }
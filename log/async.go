@@ -0,0 +1,309 @@
+package log
+
+import (
+	"bufio"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy decides what to do when the async buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits until there is space in the buffer.
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDropOldest drops the oldest entry in the buffer.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+
+	// OverflowDropNewest drops the entry being written.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+)
+
+const (
+	defaultAsyncBufferSize    = 4096
+	defaultAsyncFlushInterval = time.Second
+	asyncWriterBufSize        = 256 * 1024
+)
+
+// asyncWriter writes the entries to ws in a background goroutine,
+// the entries wait in a bounded ring buffer.
+// It keeps the levels for the LevelWriter ws, e.g. syslog.
+type asyncWriter struct {
+	name          string
+	ws            zapcore.WriteSyncer
+	lw            LevelWriter
+	policy        OverflowPolicy
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	drained  *sync.Cond
+	ring     []asyncEntry
+	head     int
+	size     int
+	writing  bool
+	closed   bool
+
+	// 保护 bw 和 ws
+	wmu sync.Mutex
+	bw  *bufio.Writer
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type asyncEntry struct {
+	p []byte
+
+	// WriteLevel 写入的条目
+	leveled bool
+	lvl     zapcore.Level
+}
+
+type AsyncOption func(*asyncWriter)
+
+type AsyncOptions struct{}
+
+// NewAsyncWriteSyncer returns a WriteSyncer which never waits for ws,
+// except the block policy with a full buffer.
+// The entries are flushed to ws periodically and by Sync.
+// The result is an io.Closer, Close stops the background goroutines.
+func NewAsyncWriteSyncer(ws zapcore.WriteSyncer, options ...AsyncOption) zapcore.WriteSyncer {
+	w := &asyncWriter{
+		name:          "default",
+		ws:            ws,
+		policy:        OverflowBlock,
+		flushInterval: defaultAsyncFlushInterval,
+		stop:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if len(w.ring) == 0 {
+		w.ring = make([]asyncEntry, defaultAsyncBufferSize)
+	}
+	w.lw, _ = ws.(LevelWriter)
+
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.drained = sync.NewCond(&w.mu)
+	w.bw = bufio.NewWriterSize(ws, asyncWriterBufSize)
+
+	w.wg.Add(2)
+	go w.run()
+	go w.flushLoop()
+
+	return w
+}
+
+func (AsyncOptions) WithBufferSize(size int) AsyncOption {
+	return func(w *asyncWriter) {
+		if size > 0 {
+			w.ring = make([]asyncEntry, size)
+		}
+	}
+}
+
+func (AsyncOptions) WithPolicy(policy OverflowPolicy) AsyncOption {
+	return func(w *asyncWriter) {
+		w.policy = policy
+	}
+}
+
+func (AsyncOptions) WithFlushInterval(interval time.Duration) AsyncOption {
+	return func(w *asyncWriter) {
+		if interval > 0 {
+			w.flushInterval = interval
+		}
+	}
+}
+
+// WithName names the writer in the metrics.
+func (AsyncOptions) WithName(name string) AsyncOption {
+	return func(w *asyncWriter) {
+		w.name = name
+	}
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	return w.enqueue(asyncEntry{p: p})
+}
+
+// WriteLevel passes the level to the LevelWriter ws, otherwise it is the same as Write.
+func (w *asyncWriter) WriteLevel(lvl zapcore.Level, p []byte) (int, error) {
+	return w.enqueue(asyncEntry{p: p, leveled: true, lvl: lvl})
+}
+
+func (w *asyncWriter) enqueue(entry asyncEntry) (int, error) {
+	p := entry.p
+	// zap 会复用 p
+	entry.p = make([]byte, len(p))
+	copy(entry.p, p)
+
+	w.mu.Lock()
+	if !w.closed && w.size == len(w.ring) {
+		switch w.policy {
+		case OverflowDropNewest:
+			w.mu.Unlock()
+			logDroppedCounter.Inc(string(OverflowDropNewest), "")
+			return len(p), nil
+		case OverflowDropOldest:
+			w.ring[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.ring)
+			w.size--
+			logDroppedCounter.Inc(string(OverflowDropOldest), "")
+		default:
+			for !w.closed && w.size == len(w.ring) {
+				w.notFull.Wait()
+			}
+		}
+	}
+
+	// 关闭后直接写入
+	if w.closed {
+		w.mu.Unlock()
+
+		w.wmu.Lock()
+		defer w.wmu.Unlock()
+		if entry.leveled && w.lw != nil {
+			return w.lw.WriteLevel(entry.lvl, entry.p)
+		}
+		return w.ws.Write(entry.p)
+	}
+
+	w.ring[(w.head+w.size)%len(w.ring)] = entry
+	w.size++
+	size := w.size
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+
+	logAsyncQueueGauge.Set(float64(size), w.name)
+
+	return len(p), nil
+}
+
+func (w *asyncWriter) run() {
+	defer w.wg.Done()
+
+	var batch []asyncEntry
+
+	for {
+		w.mu.Lock()
+		for w.size == 0 && !w.closed {
+			w.writing = false
+			w.drained.Broadcast()
+			w.notEmpty.Wait()
+		}
+
+		// 关闭时写完缓冲区再退出
+		if w.size == 0 {
+			w.writing = false
+			w.drained.Broadcast()
+			w.mu.Unlock()
+			return
+		}
+
+		w.writing = true
+		batch = batch[:0]
+		for w.size > 0 {
+			batch = append(batch, w.ring[w.head])
+			w.ring[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.ring)
+			w.size--
+		}
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		logAsyncQueueGauge.Set(0, w.name)
+
+		w.wmu.Lock()
+		for _, entry := range batch {
+			// 写失败时无处可报, 与同步写入时 zap 的处理一致
+			_ = w.write(entry)
+		}
+		w.wmu.Unlock()
+	}
+}
+
+// write is called with wmu held, the leveled entries skip bw to keep their levels.
+func (w *asyncWriter) write(entry asyncEntry) error {
+	if entry.leveled && w.lw != nil {
+		// 保持顺序
+		if err := w.bw.Flush(); err != nil {
+			return err
+		}
+		_, err := w.lw.WriteLevel(entry.lvl, entry.p)
+		return err
+	}
+
+	_, err := w.bw.Write(entry.p)
+	return err
+}
+
+func (w *asyncWriter) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.wmu.Lock()
+			_ = w.bw.Flush()
+			w.wmu.Unlock()
+		}
+	}
+}
+
+// Sync waits for the buffered entries, then flushes and syncs ws.
+func (w *asyncWriter) Sync() error {
+	w.mu.Lock()
+	for w.size > 0 || w.writing {
+		w.drained.Wait()
+	}
+	w.mu.Unlock()
+
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+
+	return w.ws.Sync()
+}
+
+// Close writes the buffered entries, stops the background goroutines,
+// then flushes and syncs ws. The later entries are written to ws directly.
+func (w *asyncWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.notEmpty.Broadcast()
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		close(w.stop)
+		w.wg.Wait()
+
+		w.wmu.Lock()
+		defer w.wmu.Unlock()
+
+		if w.closeErr = w.bw.Flush(); w.closeErr == nil {
+			w.closeErr = w.ws.Sync()
+		}
+	})
+
+	return w.closeErr
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+type slowSyncer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	delay  time.Duration
+	synced int
+}
+
+func (s *slowSyncer) Write(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *slowSyncer) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synced++
+	return nil
+}
+
+func (s *slowSyncer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func TestAsyncWriteSyncer_Sync(t *testing.T) {
+	ws := &slowSyncer{}
+	opt := AsyncOptions{}
+	w := NewAsyncWriteSyncer(ws, opt.WithBufferSize(8), opt.WithFlushInterval(time.Hour))
+
+	var want bytes.Buffer
+	for i := 0; i < 100; i++ {
+		line := "line " + strconv.Itoa(i) + "\n"
+		want.WriteString(line)
+		n, err := w.Write([]byte(line))
+		assert.Nil(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	assert.Nil(t, w.Sync())
+	assert.Equal(t, want.String(), ws.String())
+	assert.Equal(t, 1, ws.synced)
+}
+
+func TestAsyncWriteSyncer_FlushInterval(t *testing.T) {
+	ws := &slowSyncer{}
+	opt := AsyncOptions{}
+	w := NewAsyncWriteSyncer(ws, opt.WithFlushInterval(10*time.Millisecond))
+
+	_, _ = w.Write([]byte("tick\n"))
+	assert.Eventually(t, func() bool {
+		return ws.String() == "tick\n"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestAsyncWriteSyncer_Drop(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowDropOldest} {
+		ws := &slowSyncer{delay: 20 * time.Millisecond}
+		opt := AsyncOptions{}
+		w := NewAsyncWriteSyncer(ws, opt.WithBufferSize(2), opt.WithPolicy(policy))
+
+		start := time.Now()
+		for i := 0; i < 50; i++ {
+			_, _ = w.Write([]byte(strconv.Itoa(i) + "\n"))
+		}
+		// never wait for the slow writer
+		assert.True(t, time.Since(start) < 20*time.Millisecond*10, policy)
+
+		assert.Nil(t, w.Sync())
+		out := ws.String()
+		assert.True(t, len(out) < len("0\n")*50, policy)
+		if policy == OverflowDropOldest {
+			assert.Contains(t, out, "49\n")
+		} else {
+			assert.NotContains(t, out, "49\n")
+		}
+	}
+}
+
+func TestAsyncWriteSyncer_Close(t *testing.T) {
+	ws := &slowSyncer{delay: time.Millisecond}
+	opt := AsyncOptions{}
+	w := NewAsyncWriteSyncer(ws, opt.WithBufferSize(4), opt.WithFlushInterval(time.Hour)).(*asyncWriter)
+
+	for i := 0; i < 20; i++ {
+		_, _ = w.Write([]byte(strconv.Itoa(i) + "\n"))
+	}
+
+	assert.Nil(t, w.Close())
+	assert.Nil(t, w.Close())
+	assert.Contains(t, ws.String(), "19\n")
+	assert.Equal(t, 1, ws.synced)
+
+	// 两个后台协程都已退出
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the goroutines are not stopped")
+	}
+
+	// 关闭后直接写入
+	_, _ = w.Write([]byte("late\n"))
+	assert.Contains(t, ws.String(), "late\n")
+	assert.Nil(t, w.Sync())
+}
+
+func TestLogger_Async(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_async", true)
+	conf.Set("log_async_policy", "drop_newest")
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf)).(*logger)
+	assert.Equal(t, 4096, l.Config.AsyncBufferSize)
+
+	_, ok := l.wrapWriter("test", zapcore.AddSync(&bytes.Buffer{})).(*asyncWriter)
+	assert.True(t, ok)
+
+	l.Infof("async")
+	_ = l.Sync()
+
+	assert.Len(t, l.closers, 2)
+	_ = Close(l)
+	for _, c := range l.closers {
+		assert.True(t, c.(*asyncWriter).closed)
+	}
+}
@@ -2,18 +2,20 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"time"
 
 	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
 	tracerid "github.com/Hyingerrr/mirco-esim/pkg/tracer-id"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type logger struct {
@@ -22,10 +24,25 @@ type logger struct {
 	debug bool
 	sugar *zap.SugaredLogger
 	conf  config.Config
+	level zap.AtomicLevel
+
+	sampler *sampler
+
+	masker *masker
+
+	// the async writers, stopped by Close
+	closers []io.Closer
+
+	// key-value pairs bound by With
+	fields    []interface{}
+	boundKeys map[string]bool
 }
 
 type Field map[string]interface{}
 
+// the meta values logged with every *c call.
+var metaKeys = []string{meta.AppID, meta.TranCd, meta.RequestNo, meta.MerID}
+
 type LoggerOptions struct{}
 
 type Option func(*logger)
@@ -46,26 +63,31 @@ func NewLogger(options ...Option) Logger {
 		l.conf = config.NewMemConfig()
 	}
 
-	l.Config.fillWithDefaultConfig(l.conf)
+	if err := l.Config.fillWithDefaultConfig(l.conf); err != nil {
+		fmt.Fprintf(os.Stderr, "log: %s, use the default config\n", err.Error())
+	}
 
-	hook := &lumberjack.Logger{
-		Filename:   l.Config.File,
-		MaxSize:    l.Config.MaxSize,
-		MaxBackups: l.Config.BackupCount,
-		MaxAge:     l.Config.MaxAge,
-		Compress:   false,
+	if !l.Config.MaskOff {
+		m, err := newMasker(l.Config.MaskFields, l.Config.MaskPatterns)
+		if err != nil {
+			panic(err)
+		}
+		l.masker = m
 	}
 
 	switch {
 	case l.Config.IsBothFileStdout():
-		writer = append(writer, zapcore.AddSync(hook), zapcore.AddSync(os.Stdout))
+		writer = append(writer, l.wrapWriter("file", l.Config.newFileWriter(l.Config.File)),
+			l.wrapWriter("stdout", zapcore.AddSync(os.Stdout)))
 	case l.Config.IsOutFile():
-		writer = append(writer, zapcore.AddSync(hook))
+		writer = append(writer, l.wrapWriter("file", l.Config.newFileWriter(l.Config.File)))
 	case l.Config.IsOutStdout():
-		writer = append(writer, zapcore.AddSync(os.Stdout))
+		writer = append(writer, l.wrapWriter("stdout", zapcore.AddSync(os.Stdout)))
 	}
 
 	lever := ParseLevel(l.Config.Level)
+	l.level = zap.NewAtomicLevelAt(lever)
+	l.sampler = newSampler(l.Config.SampleTick, l.Config.SampleInitial, l.Config.SampleThereafter)
 
 	if l.Config.ReportCaller {
 		opts = append(opts, zap.AddCaller())
@@ -80,20 +102,62 @@ func NewLogger(options ...Option) Logger {
 	}
 
 	for _, w := range writer {
-		core = append(core, zapcore.NewCore(l.buildEncoder(), w, zap.NewAtomicLevelAt(lever)))
+		core = append(core, zapcore.NewCore(l.buildEncoder(l.Config.Format), w, l.level))
+	}
+
+	sinks, err := l.buildSinks()
+	if err != nil {
+		panic(err)
+	}
+	core = append(core, sinks...)
+
+	if l.Config.ErrorFile != "" {
+		errLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl >= zap.ErrorLevel && l.level.Enabled(lvl)
+		})
+		core = append(core, zapcore.NewCore(l.buildEncoder(l.Config.Format),
+			l.wrapWriter("error_file", l.Config.newFileWriter(l.Config.ErrorFile)), errLevel))
 	}
 
 	l.log = zap.New(zapcore.NewTee(core...), opts...)
 	l.sugar = l.log.Sugar()
-	return setInstance(l)
+	l.watchLevel()
+	setDefaultIfNil(l)
+
+	return l
+}
+
+// wrapWriter makes ws asynchronous when log_async is on,
+// ws is closed by Close if it is an io.Closer, e.g. the files and the net sinks.
+func (l *logger) wrapWriter(name string, ws zapcore.WriteSyncer) zapcore.WriteSyncer {
+	if !l.Config.Async {
+		l.addCloser(ws)
+		return ws
+	}
+
+	opt := AsyncOptions{}
+	aw := NewAsyncWriteSyncer(ws,
+		opt.WithName(name),
+		opt.WithBufferSize(l.Config.AsyncBufferSize),
+		opt.WithPolicy(OverflowPolicy(l.Config.AsyncPolicy)),
+		opt.WithFlushInterval(l.Config.AsyncFlushInterval),
+	)
+	// 先写完缓冲区再关闭 ws
+	l.closers = append(l.closers, aw.(io.Closer))
+	l.addCloser(ws)
+
+	return aw
 }
 
-func setInstance(l *logger) Logger {
-	if _log != nil {
-		return _log
+func (l *logger) addCloser(ws zapcore.WriteSyncer) {
+	// zapcore.AddSync(os.Stdout) 返回 *os.File 本身, 不关闭
+	if ws == os.Stdout || ws == os.Stderr {
+		return
+	}
+
+	if c, ok := ws.(io.Closer); ok {
+		l.closers = append(l.closers, c)
 	}
-	_log = l
-	return _log
 }
 
 func (LoggerOptions) WithLoggerConf(conf config.Config) Option {
@@ -108,7 +172,7 @@ func (LoggerOptions) WithDebug(debug bool) Option {
 	}
 }
 
-func (l *logger) buildEncoder() zapcore.Encoder {
+func (l *logger) buildEncoder(format string) zapcore.Encoder {
 	var (
 		encoder zapcore.EncoderConfig
 	)
@@ -126,10 +190,10 @@ func (l *logger) buildEncoder() zapcore.Encoder {
 	encoder.EncodeCaller = zapcore.FullCallerEncoder
 	encoder.EncodeName = zapcore.FullNameEncoder
 
-	if l.Config.Format == "json" {
-		return zapcore.NewJSONEncoder(encoder)
+	if format == "json" {
+		return newMaskEncoder(zapcore.NewJSONEncoder(encoder), l.masker)
 	} else {
-		return zapcore.NewConsoleEncoder(encoder)
+		return newMaskEncoder(zapcore.NewConsoleEncoder(encoder), l.masker)
 	}
 }
 
@@ -138,50 +202,98 @@ func (l *logger) buildEncoder() zapcore.Encoder {
 //}
 
 func (l *logger) Infof(template string, args ...interface{}) {
+	if !l.allow(zap.InfoLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Infof(template, args...)
 }
 
 func (l *logger) Info(args ...interface{}) {
+	if !l.allow(zap.InfoLevel, firstString(args)) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Info(args...)
 }
 
 func (l *logger) Infoc(ctx context.Context, template string, args ...interface{}) {
+	if !l.allow(zap.InfoLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(ctx)...).Infof(template, args...)
 }
 
 func (l *logger) InfoW(msg string, args ...interface{}) {
+	if !l.allow(zap.InfoLevel, msg) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Infow(msg, args...)
 }
 
 func (l *logger) Warnf(template string, args ...interface{}) {
+	if !l.allow(zap.WarnLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Warnf(template, args...)
 }
 
 func (l *logger) WarnW(msg string, args ...interface{}) {
+	if !l.allow(zap.WarnLevel, msg) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Warnw(msg, args...)
 }
 
 func (l *logger) Warnc(ctx context.Context, template string, args ...interface{}) {
+	if !l.allow(zap.WarnLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(ctx)...).Warnf(template, args...)
 }
 
 func (l *logger) Error(msg string) {
-	l.log.Error(msg)
+	if !l.allow(zap.ErrorLevel, msg) {
+		return
+	}
+
+	l.sugar.With(l.getArgs(context.TODO())...).Error(msg)
 }
 
 func (l *logger) Errorf(template string, args ...interface{}) {
+	if !l.allow(zap.ErrorLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Errorf(template, args...)
 }
 
 func (l *logger) Errorc(ctx context.Context, template string, args ...interface{}) {
+	if !l.allow(zap.ErrorLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(ctx)...).Errorf(template, args...)
 }
 
 func (l *logger) ErrorW(msg string, args ...interface{}) {
+	if !l.allow(zap.ErrorLevel, msg) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Errorw(msg, args...)
 }
 
 func (l *logger) Debugf(template string, args ...interface{}) {
+	if !l.allow(zap.DebugLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(context.TODO())...).Debugf(template, args...)
 }
 
@@ -194,6 +306,10 @@ func (l *logger) Panicf(template string, args ...interface{}) {
 }
 
 func (l *logger) Debugc(ctx context.Context, template string, args ...interface{}) {
+	if !l.allow(zap.DebugLevel, template) {
+		return
+	}
+
 	l.sugar.With(l.getArgs(ctx)...).Debugf(template, args...)
 }
 
@@ -213,10 +329,71 @@ func (l *logger) Fatalc(ctx context.Context, template string, args ...interface{
 	l.sugar.With(l.getArgs(ctx)...).Fatalf(template, args...)
 }
 
+// allow checks the level first, so the disabled entries never reach the sampler.
+func (l *logger) allow(lvl zapcore.Level, template string) bool {
+	return l.sampler == nil || !l.level.Enabled(lvl) || l.sampler.allow(lvl, template)
+}
+
+func firstString(args []interface{}) string {
+	if len(args) > 0 {
+		if s, ok := args[0].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
 func (l *logger) standardTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02 15:04:05"))
 }
 
+// With returns a child logger carrying the ctx values and the key-value pairs,
+// the fields are passed on to the children of the child.
+func (l *logger) With(ctx context.Context, fields ...interface{}) Logger {
+	child := *l
+
+	child.fields = make([]interface{}, 0, len(l.fields)+len(fields)+2*(len(metaKeys)+1))
+	child.fields = append(child.fields, l.fields...)
+	if ctx != nil {
+		child.fields = append(child.fields, l.ctxArgs(ctx)...)
+	}
+	child.fields = append(child.fields, fields...)
+
+	child.boundKeys = make(map[string]bool, len(child.fields)/2)
+	for i := 0; i < len(child.fields)-1; i += 2 {
+		if key, ok := child.fields[i].(string); ok {
+			child.boundKeys[key] = true
+		}
+	}
+
+	return &child
+}
+
+// Sync flushes the buffered entries, call it before the process exits.
+func (l *logger) Sync() error {
+	return l.log.Sync()
+}
+
+// Close flushes the buffered entries and stops the async writers,
+// the children made by With share the writers with l.
+// Close the replaced logger after rebuilding one, or the writers leak.
+func (l *logger) Close() error {
+	err := l.Sync()
+	for _, c := range l.closers {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Mask returns a masked copy of v for logging, e.g. the request and response bodies.
+func (l *logger) Mask(v interface{}) interface{} {
+	return l.masker.mask(v)
+}
+
 func (l *logger) WithFields(field Field) *zap.SugaredLogger {
 	return l.withFields(context.TODO(), field)
 }
@@ -226,7 +403,7 @@ func (l *logger) withFields(ctx context.Context, field Field) *zap.SugaredLogger
 }
 
 func (l *logger) getArgs(ctx context.Context, field ...Field) []interface{} {
-	args := make([]interface{}, 0)
+	args := make([]interface{}, 0, len(l.fields)+8)
 
 	args = append(args, "caller", l.getCaller(runtime.Caller(2)))
 
@@ -236,11 +413,31 @@ func (l *logger) getArgs(ctx context.Context, field ...Field) []interface{} {
 		}
 	}
 
+	args = append(args, l.fields...)
+
+	return append(args, l.ctxArgs(ctx)...)
+}
+
+// ctxArgs extracts tracer_id and the meta values from ctx,
+// the keys already bound by With are skipped.
+func (l *logger) ctxArgs(ctx context.Context) []interface{} {
+	args := make([]interface{}, 0, 2*(len(metaKeys)+1))
+
 	tracerID := l.getTracerID(ctx)
-	if tracerID != "" {
+	if tracerID != "" && !l.boundKeys["tracer_id"] {
 		args = append(args, "tracer_id", tracerID)
 	}
 
+	for _, key := range metaKeys {
+		if l.boundKeys[key] {
+			continue
+		}
+
+		if val := meta.String(ctx, key); val != "" {
+			args = append(args, key, val)
+		}
+	}
+
 	return args
 }
 
@@ -2,6 +2,7 @@ package log
 
 import (
 	"strings"
+	"time"
 
 	"github.com/Hyingerrr/mirco-esim/config"
 
@@ -10,56 +11,52 @@ import (
 )
 
 type Config struct {
-	Output       string // 日志的位置 file|both|stdout
-	Level        string
-	Format       string
-	ReportCaller bool
-	Stacktrace   bool
-	ErrStats     bool
-	File         string
-	MaxSize      int  // 单个文件最大size
-	MaxAge       int  // 保留旧文件的最大天数
-	BackupCount  int  // 保留旧文件的最大个数
-	Compress     bool // 是否压缩/归档旧文件
-}
+	Output       string `mapstructure:"log_output" default:"stdout" validate:"oneof=file both stdout none"` // 日志的位置 file|both|stdout|none
+	Level        string `mapstructure:"log_level" default:"debug"`
+	Format       string `mapstructure:"log_format" default:"text"`
+	ReportCaller bool   `mapstructure:"log_report_caller"`
+	Stacktrace   bool   `mapstructure:"log_stack_trace"`
+	ErrStats     bool   `mapstructure:"log_err_stats"`
+	File         string `mapstructure:"log_file" default:"./logs/esim.log"`
+	MaxSize      int    `mapstructure:"log_max_size" default:"1000"`   // 单个文件最大size
+	MaxAge       int    `mapstructure:"log_max_age" default:"15"`      // 保留旧文件的最大天数
+	BackupCount  int    `mapstructure:"log_backup_count" default:"20"` // 保留旧文件的最大个数
+	Compress     bool   `mapstructure:"log_compress"`                  // 是否压缩/归档旧文件
 
-func (c *Config) fillWithDefaultConfig(conf config.Config) {
-	c.Output = conf.GetString("log_output")
-	if c.Output == "" {
-		c.Output = "stdout"
-	}
+	Rotate        string `mapstructure:"log_rotate" default:"size" validate:"oneof=size daily hourly"` // 切割方式 size|daily|hourly
+	RotatePattern string `mapstructure:"log_rotate_pattern"`                                           // 按时间切割的文件后缀, 如 2006-01-02
+	ErrorFile     string `mapstructure:"log_error_file"`                                               // error及以上级别的日志另写一份
 
-	c.File = conf.GetString("log_file")
-	if c.File == "" {
-		c.File = "./logs/esim.log"
-	}
+	// 额外的输出, 见 SinkConfig
+	Sinks []SinkConfig `mapstructure:"log_sinks"`
 
-	c.Level = conf.GetString("log_level")
-	if c.Level == "" {
-		c.Level = "debug"
-	}
+	// 采样: 每个tick内同一模板同一级别的日志先输出前SampleInitial条, 之后每SampleThereafter条输出一条
+	SampleInitial    int           `mapstructure:"log_sample_initial" validate:"gte=0"`
+	SampleThereafter int           `mapstructure:"log_sample_thereafter" validate:"gte=0"`
+	SampleTick       time.Duration `mapstructure:"log_sample_tick" default:"1s"`
 
-	c.Compress = conf.GetBool("log_compress")
-	c.BackupCount = conf.GetInt("log_backup_count")
-	if c.BackupCount == 0 {
-		c.BackupCount = 20
-	}
-	c.MaxAge = conf.GetInt("log_max_age")
-	if c.MaxAge == 0 {
-		c.MaxAge = 15
-	}
-	c.MaxSize = conf.GetInt("log_max_size")
-	if c.MaxSize == 0 {
-		c.MaxSize = 1000 // 10G
-	}
-	c.Stacktrace = conf.GetBool("log_stack_trace")
-	c.ReportCaller = conf.GetBool("log_report_caller")
-	c.Format = conf.GetString("log_format")
-	if c.Format == "" {
-		c.Format = "text"
+	// 脱敏: 按字段名, 正则和 `log:"mask"` 标签隐藏敏感数据
+	MaskOff      bool     `mapstructure:"log_mask_off"`
+	MaskFields   []string `mapstructure:"log_mask_fields" default:"card_no,id_card,mobile,password"`
+	MaskPatterns []string `mapstructure:"log_mask_patterns"`
+
+	// 异步写入: 缓冲区满时的策略 block|drop_oldest|drop_newest
+	Async              bool          `mapstructure:"log_async"`
+	AsyncBufferSize    int           `mapstructure:"log_async_buffer_size" default:"4096" validate:"gte=1"`
+	AsyncPolicy        string        `mapstructure:"log_async_policy" default:"block" validate:"oneof=block drop_oldest drop_newest"`
+	AsyncFlushInterval time.Duration `mapstructure:"log_async_flush_interval" default:"1s"`
+}
+
+// fillWithDefaultConfig falls back to the defaults if conf is invalid,
+// the logger is needed to report the other errors.
+func (c *Config) fillWithDefaultConfig(conf config.Config) error {
+	err := config.BindFrom(conf, "", c)
+	if err != nil {
+		*c = Config{}
+		_ = config.BindFrom(config.NewNullConfig(), "", c)
 	}
 
-	c.ErrStats = conf.GetBool("log_err_stats")
+	return err
 }
 
 func (c *Config) IsOutStdout() bool {
@@ -70,6 +67,10 @@ func (c *Config) IsBothFileStdout() bool {
 	return c.Output == "both"
 }
 
+func (c *Config) IsOutNone() bool {
+	return c.Output == "none"
+}
+
 func (c *Config) IsOutFile() bool {
 	return c.Output == "file"
 }
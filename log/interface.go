@@ -2,13 +2,20 @@ package log
 
 import (
 	"context"
+	"io"
+	"sync"
 
 	"github.com/Hyingerrr/mirco-esim/config"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-var _log Logger
+var (
+	_log Logger
+
+	defaultMu sync.RWMutex
+)
 
 func NewNullLogger() Logger {
 	opt := LoggerOptions{}
@@ -57,80 +64,159 @@ type Logger interface {
 	Fatalc(context.Context, string, ...interface{})
 
 	WithFields(Field) *zap.SugaredLogger
+
+	// With returns a Logger carrying the ctx values and the key-value pairs.
+	With(ctx context.Context, fields ...interface{}) Logger
+
+	// Mask returns a masked copy of v.
+	Mask(v interface{}) interface{}
+
+	// Sync flushes the buffered entries.
+	Sync() error
+
+	// SetLevel changes the level at runtime.
+	SetLevel(zapcore.Level)
+
+	GetLevel() zapcore.Level
 }
 
 func Error(msg string) {
-	_log.Error(msg)
+	Default().Error(msg)
 }
 
 func Errorc(ctx context.Context, format string, args ...interface{}) {
-	_log.Errorc(ctx, format, args...)
+	Default().Errorc(ctx, format, args...)
 }
 
 func Errorf(format string, args ...interface{}) {
-	_log.Errorf(format, args...)
+	Default().Errorf(format, args...)
 }
 
 func ErrorW(format string, args ...interface{}) {
-	_log.ErrorW(format, args...)
+	Default().ErrorW(format, args...)
 }
 
 func Debugf(format string, args ...interface{}) {
-	_log.Debugf(format, args...)
+	Default().Debugf(format, args...)
 }
 
 func Debugc(ctx context.Context, format string, args ...interface{}) {
-	_log.Debugc(ctx, format, args...)
+	Default().Debugc(ctx, format, args...)
 }
 
 func Infof(format string, args ...interface{}) {
-	_log.Infof(format, args...)
+	Default().Infof(format, args...)
 }
 
 func Info(args ...interface{}) {
-	_log.Info(args...)
+	Default().Info(args...)
 }
 
 func InfoW(format string, args ...interface{}) {
-	_log.InfoW(format, args...)
+	Default().InfoW(format, args...)
 }
 
 func Infoc(ctx context.Context, format string, args ...interface{}) {
-	_log.Infoc(ctx, format, args...)
+	Default().Infoc(ctx, format, args...)
 }
 
 func Warnf(format string, args ...interface{}) {
-	_log.Warnf(format, args...)
+	Default().Warnf(format, args...)
 }
 
 func Warnc(ctx context.Context, format string, args ...interface{}) {
-	_log.Warnc(ctx, format, args...)
+	Default().Warnc(ctx, format, args...)
 }
 
 func DPanicf(format string, args ...interface{}) {
-	_log.DPanicf(format, args...)
+	Default().DPanicf(format, args...)
 }
 
 func Panicf(format string, args ...interface{}) {
-	_log.Panicf(format, args...)
+	Default().Panicf(format, args...)
 }
 
 func DPanicc(ctx context.Context, format string, args ...interface{}) {
-	_log.DPanicc(ctx, format, args...)
+	Default().DPanicc(ctx, format, args...)
 }
 
 func Panicc(ctx context.Context, format string, args ...interface{}) {
-	_log.Panicc(ctx, format, args...)
+	Default().Panicc(ctx, format, args...)
 }
 
 func Fatalf(format string, args ...interface{}) {
-	_log.Fatalf(format, args...)
+	Default().Fatalf(format, args...)
 }
 
 func Fatalc(ctx context.Context, format string, args ...interface{}) {
-	_log.Fatalc(ctx, format, args...)
+	Default().Fatalc(ctx, format, args...)
 }
 
 func WithFields(field Field) *zap.SugaredLogger {
-	return _log.WithFields(field)
+	return Default().WithFields(field)
+}
+
+func With(ctx context.Context, fields ...interface{}) Logger {
+	return Default().With(ctx, fields...)
+}
+
+func Sync() error {
+	return Default().Sync()
+}
+
+// Close closes logger if it can be closed, otherwise syncs it.
+func Close(logger Logger) error {
+	if c, ok := logger.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return logger.Sync()
+}
+
+func Mask(v interface{}) interface{} {
+	return Default().Mask(v)
+}
+
+func SetLevel(level zapcore.Level) {
+	Default().SetLevel(level)
+}
+
+func GetLevel() zapcore.Level {
+	return Default().GetLevel()
+}
+
+// Default returns the logger used by the package-level functions.
+func Default() Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return _log
+}
+
+// SetDefault replaces the logger used by the package-level functions.
+func SetDefault(logger Logger) {
+	defaultMu.Lock()
+	_log = logger
+	defaultMu.Unlock()
+}
+
+// ReplaceDefault sets logger as the default and returns a func to restore the old one.
+func ReplaceDefault(logger Logger) (restore func()) {
+	defaultMu.Lock()
+	old := _log
+	_log = logger
+	defaultMu.Unlock()
+
+	return func() {
+		SetDefault(old)
+	}
+}
+
+// setDefaultIfNil makes the first created logger the default,
+// so the package-level functions work without SetDefault.
+func setDefaultIfNil(logger Logger) {
+	defaultMu.Lock()
+	if _log == nil {
+		_log = logger
+	}
+	defaultMu.Unlock()
 }
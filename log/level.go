@@ -0,0 +1,136 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/spf13/cast"
+	"go.uber.org/zap/zapcore"
+)
+
+func (l *logger) SetLevel(level zapcore.Level) {
+	l.level.SetLevel(level)
+}
+
+func (l *logger) GetLevel() zapcore.Level {
+	return l.level.Level()
+}
+
+// watchLevel follows log_level if the config supports hot reload.
+func (l *logger) watchLevel() {
+	notifier, ok := l.conf.(config.Notifier)
+	if !ok {
+		return
+	}
+
+	notifier.OnChange("log_level", func(old, new interface{}) {
+		// 删除配置时保持当前级别
+		if new == nil {
+			return
+		}
+
+		level := ParseLevel(cast.ToString(new))
+		l.Infof("log level changed by config: %s => %s", l.GetLevel(), level)
+		l.SetLevel(level)
+	})
+}
+
+type levelPayload struct {
+	Level string `json:"level"`
+
+	// restore the old level after duration, e.g. 10m.
+	Duration string `json:"duration,omitempty"`
+}
+
+type levelHandler struct {
+	mu sync.Mutex
+
+	restore *time.Timer
+
+	oldLevel zapcore.Level
+}
+
+// LevelHandler gets or changes the level of the default logger,
+// the admin server serves it at /debug/loglevel.
+//
+//	curl :9002/debug/loglevel
+//	curl -X PUT :9002/debug/loglevel -d '{"level":"debug","duration":"10m"}'
+func LevelHandler() http.Handler {
+	return &levelHandler{}
+}
+
+func (lh *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := Default()
+	if logger == nil {
+		lh.reply(w, http.StatusServiceUnavailable, map[string]string{"error": "logger not initialized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		lh.reply(w, http.StatusOK, levelPayload{Level: logger.GetLevel().String()})
+	case http.MethodPut, http.MethodPost:
+		var payload levelPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			lh.reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(payload.Level)); err != nil {
+			lh.reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		var duration time.Duration
+		if payload.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(payload.Duration)
+			if err != nil {
+				lh.reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		lh.setLevel(logger, level, duration)
+		lh.reply(w, http.StatusOK, payload)
+	default:
+		lh.reply(w, http.StatusMethodNotAllowed,
+			map[string]string{"error": "only GET, PUT and POST are supported"})
+	}
+}
+
+func (lh *levelHandler) setLevel(logger Logger, level zapcore.Level, duration time.Duration) {
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+
+	old := logger.GetLevel()
+	if lh.restore != nil {
+		// a pending restore keeps the level before the first change.
+		if lh.restore.Stop() {
+			old = lh.oldLevel
+		}
+		lh.restore = nil
+	}
+
+	logger.Warnf("log level changed by http: %s => %s, duration: %v", logger.GetLevel(), level, duration)
+	logger.SetLevel(level)
+
+	if duration > 0 {
+		lh.oldLevel = old
+		lh.restore = time.AfterFunc(duration, func() {
+			logger.Warnf("log level restored: %s => %s", logger.GetLevel(), old)
+			logger.SetLevel(old)
+		})
+	}
+}
+
+func (lh *levelHandler) reply(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogger_SetLevel(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_level", "info")
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	assert.Equal(t, zapcore.InfoLevel, l.GetLevel())
+
+	l.SetLevel(zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.ErrorLevel, l.GetLevel())
+
+	// follow the config
+	conf.Set("log_level", "debug")
+	assert.Equal(t, zapcore.DebugLevel, l.GetLevel())
+
+	// 删除配置时保持当前级别
+	conf.Set("log_level", nil)
+	assert.Equal(t, zapcore.DebugLevel, l.GetLevel())
+}
+
+func TestLevelHandler(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_level", "info")
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	t.Cleanup(ReplaceDefault(l))
+
+	handler := LevelHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/loglevel", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"info"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/loglevel",
+		strings.NewReader(`{"level":"debug","duration":"50ms"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, zapcore.DebugLevel, l.GetLevel())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, zapcore.InfoLevel, l.GetLevel())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/loglevel",
+		strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -83,3 +83,19 @@ func TestNewLogger(t *testing.T) {
 End:
 	fmt.Println("task over")
 }
+
+// 配置有误时使用默认配置, 不影响启动
+func TestNewLogger_InvalidConfig(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "stdoot")
+	conf.Set("log_file", "./logs/invalid.log")
+
+	l := &logger{Config: &Config{}}
+	assert.NotNil(t, l.Config.fillWithDefaultConfig(conf))
+	assert.Equal(t, "stdout", l.Config.Output)
+	assert.Equal(t, "./logs/esim.log", l.Config.File)
+
+	assert.NotPanics(t, func() {
+		NewLogger(LoggerOptions{}.WithLoggerConf(conf)).Info("fallback")
+	})
+}
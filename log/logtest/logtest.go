@@ -0,0 +1,14 @@
+// Package logtest helps the tests which depend on the default logger.
+package logtest
+
+import (
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/log"
+)
+
+// SetDefault sets logger as the default logger until the test ends.
+func SetDefault(tb testing.TB, logger log.Logger) {
+	tb.Helper()
+	tb.Cleanup(log.ReplaceDefault(logger))
+}
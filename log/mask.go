@@ -0,0 +1,404 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// 脱敏的最大嵌套层数
+const maxMaskDepth = 10
+
+// 字段名包含这些词时整体替换, 否则保留首尾
+var fullMaskWords = []string{"password", "passwd", "pwd", "secret"}
+
+// masker hides the sensitive values before encoding:
+//   - the fields named by the field rules, e.g. card_no matches card_no, cardNo and CardNo;
+//   - the "key: value" or "key=value" pairs of the field rules in raw strings;
+//   - the matches of the regex rules in raw strings;
+//   - the struct fields tagged with `log:"mask"`.
+type masker struct {
+	fields map[string]bool
+
+	// 匹配原始字符串中的 key:value
+	fieldRe *regexp.Regexp
+
+	patterns []*regexp.Regexp
+}
+
+func newMasker(fields, patterns []string) (*masker, error) {
+	m := &masker{fields: make(map[string]bool, len(fields))}
+
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		m.fields[normalizeKey(field)] = true
+		names = append(names, strings.Join(
+			strings.FieldsFunc(regexp.QuoteMeta(field), isKeySep), "[_-]?"))
+	}
+
+	if len(names) > 0 {
+		m.fieldRe = regexp.MustCompile(`(?i)("?(?:` + strings.Join(names, "|") +
+			`)"?\s*[:=]\s*(?:\(string\)\s*\(len=\d+\)\s*)?"?)([^"\s,&}\]]+)`)
+	}
+
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("log: invalid mask pattern %q: %v", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+
+	return m, nil
+}
+
+func isKeySep(r rune) bool {
+	return r == '_' || r == '-'
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if isKeySep(r) {
+			return -1
+		}
+		return r
+	}, key))
+}
+
+func (m *masker) matchKey(key string) bool {
+	return key != "" && m.fields[normalizeKey(key)]
+}
+
+// maskValue keeps the head and the tail of val, e.g. 6222021234567890 => 6222********7890.
+func maskValue(key, val string) string {
+	rs := []rune(val)
+
+	keep := len(rs) / 4
+	if keep > 4 {
+		keep = 4
+	}
+
+	lower := strings.ToLower(key)
+	for _, word := range fullMaskWords {
+		if strings.Contains(lower, word) {
+			keep = 0
+			break
+		}
+	}
+
+	if len(rs) <= 6 {
+		keep = 0
+	}
+
+	return string(rs[:keep]) + strings.Repeat("*", len(rs)-2*keep) + string(rs[len(rs)-keep:])
+}
+
+// maskString masks the key-value pairs and the regex rules in a raw string.
+func (m *masker) maskString(s string) string {
+	if m.fieldRe != nil {
+		s = replaceSubmatch(m.fieldRe, s, func(sub []string) string {
+			return sub[1] + maskValue(sub[1], sub[2])
+		})
+	}
+
+	for _, re := range m.patterns {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			return maskValue("", match)
+		})
+	}
+
+	return s
+}
+
+func replaceSubmatch(re *regexp.Regexp, s string, repl func([]string) string) string {
+	idx := re.FindAllStringSubmatchIndex(s, -1)
+	if len(idx) == 0 {
+		return s
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, loc := range idx {
+		sub := make([]string, len(loc)/2)
+		for i := range sub {
+			if loc[2*i] >= 0 {
+				sub[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(repl(sub))
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// mask returns a masked copy of v, v itself is never changed.
+func (m *masker) mask(v interface{}) interface{} {
+	if m == nil || v == nil {
+		return v
+	}
+
+	return m.maskReflect(reflect.ValueOf(v), 0).Interface()
+}
+
+func (m *masker) maskReflect(rv reflect.Value, depth int) reflect.Value {
+	if depth > maxMaskDepth {
+		return rv
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return rv
+		}
+
+		out := reflect.New(rv.Type().Elem())
+		out.Elem().Set(m.maskReflect(rv.Elem(), depth+1))
+		return out
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+
+		out := reflect.New(rv.Type()).Elem()
+		out.Set(m.maskReflect(rv.Elem(), depth+1))
+		return out
+	case reflect.Struct:
+		return m.maskStruct(rv, depth)
+	case reflect.Map:
+		if rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+			return rv
+		}
+
+		out := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, val := iter.Key(), iter.Value()
+			if m.matchKey(key.String()) {
+				out.SetMapIndex(key, m.maskKeyValue(key.String(), val))
+			} else {
+				out.SetMapIndex(key, m.maskReflect(val, depth+1))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if rv.IsNil() || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv
+		}
+
+		out := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out.Index(i).Set(m.maskReflect(rv.Index(i), depth+1))
+		}
+		return out
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv
+		}
+
+		out := reflect.New(rv.Type()).Elem()
+		for i := 0; i < rv.Len(); i++ {
+			out.Index(i).Set(m.maskReflect(rv.Index(i), depth+1))
+		}
+		return out
+	case reflect.String:
+		return reflect.ValueOf(m.maskString(rv.String())).Convert(rv.Type())
+	default:
+		return rv
+	}
+}
+
+func (m *masker) maskStruct(rv reflect.Value, depth int) reflect.Value {
+	if rv.Type() == reflect.TypeOf(time.Time{}) {
+		return rv
+	}
+
+	out := reflect.New(rv.Type()).Elem()
+	out.Set(rv)
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fv := out.Field(i)
+		jsonName := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if field.Tag.Get("log") == "mask" || m.matchKey(field.Name) || m.matchKey(jsonName) {
+			fv.Set(m.maskKeyValue(field.Name, fv))
+			continue
+		}
+
+		fv.Set(m.maskReflect(fv, depth+1))
+	}
+
+	return out
+}
+
+// maskKeyValue masks the value of a sensitive key as maskField does,
+// the numbers held by interface{} become masked strings, the typed numbers can not
+// hold the mask and are zeroed, the lists are masked item by item.
+func (m *masker) maskKeyValue(key string, val reflect.Value) reflect.Value {
+	switch val.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(maskValue(key, val.String())).Convert(val.Type())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return reflect.Zero(val.Type())
+	case reflect.Ptr:
+		if val.IsNil() {
+			return val
+		}
+
+		out := reflect.New(val.Type().Elem())
+		out.Elem().Set(m.maskKeyValue(key, val.Elem()))
+		return out
+	case reflect.Interface:
+		if val.IsNil() {
+			return val
+		}
+
+		elem := val.Elem()
+		var masked reflect.Value
+		switch elem.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Ptr:
+			masked = m.maskKeyValue(key, elem)
+		default:
+			masked = reflect.ValueOf(maskValue(key, fmt.Sprint(elem.Interface())))
+		}
+		if !masked.Type().AssignableTo(val.Type()) {
+			return val
+		}
+
+		out := reflect.New(val.Type()).Elem()
+		out.Set(masked)
+		return out
+	case reflect.Slice:
+		if val.IsNil() {
+			return val
+		}
+
+		out := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		for i := 0; i < val.Len(); i++ {
+			out.Index(i).Set(m.maskKeyValue(key, val.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(val.Type()).Elem()
+		for i := 0; i < val.Len(); i++ {
+			out.Index(i).Set(m.maskKeyValue(key, val.Index(i)))
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+func (m *masker) maskField(f zapcore.Field) zapcore.Field {
+	if m.matchKey(f.Key) {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = maskValue(f.Key, f.String)
+			return f
+		case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+			return zapcore.Field{Key: f.Key, Type: zapcore.StringType,
+				String: maskValue(f.Key, strconv.FormatInt(f.Integer, 10))}
+		case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+			return zapcore.Field{Key: f.Key, Type: zapcore.StringType,
+				String: maskValue(f.Key, strconv.FormatUint(uint64(f.Integer), 10))}
+		}
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = m.maskString(f.String)
+	case zapcore.ReflectType:
+		f.Interface = m.mask(f.Interface)
+	}
+
+	return f
+}
+
+// maskEncoder masks the message and the fields before the inner encoder,
+// it works for both json and console formats.
+type maskEncoder struct {
+	zapcore.Encoder
+
+	m *masker
+}
+
+func newMaskEncoder(enc zapcore.Encoder, m *masker) zapcore.Encoder {
+	if m == nil {
+		return enc
+	}
+
+	return &maskEncoder{Encoder: enc, m: m}
+}
+
+func (e *maskEncoder) Clone() zapcore.Encoder {
+	return &maskEncoder{Encoder: e.Encoder.Clone(), m: e.m}
+}
+
+func (e *maskEncoder) AddString(key, val string) {
+	if e.m.matchKey(key) {
+		e.Encoder.AddString(key, maskValue(key, val))
+		return
+	}
+
+	e.Encoder.AddString(key, e.m.maskString(val))
+}
+
+func (e *maskEncoder) AddInt64(key string, val int64) {
+	if e.m.matchKey(key) {
+		e.Encoder.AddString(key, maskValue(key, strconv.FormatInt(val, 10)))
+		return
+	}
+
+	e.Encoder.AddInt64(key, val)
+}
+
+func (e *maskEncoder) AddUint64(key string, val uint64) {
+	if e.m.matchKey(key) {
+		e.Encoder.AddString(key, maskValue(key, strconv.FormatUint(val, 10)))
+		return
+	}
+
+	e.Encoder.AddUint64(key, val)
+}
+
+func (e *maskEncoder) AddReflected(key string, obj interface{}) error {
+	return e.Encoder.AddReflected(key, e.m.mask(obj))
+}
+
+func (e *maskEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ent.Message = e.m.maskString(ent.Message)
+
+	masked := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		masked[i] = e.m.maskField(f)
+	}
+
+	return e.Encoder.EncodeEntry(ent, masked)
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type payUser struct {
+	Name   string
+	Mobile string
+	Token  string `log:"mask"`
+	Secret string `log:"mask"`
+}
+
+type payRequest struct {
+	CardNo string `json:"card_no"`
+	Amount int64
+	User   *payUser
+	Extra  map[string]interface{}
+}
+
+func newTestMasker(t *testing.T, patterns ...string) *masker {
+	m, err := newMasker([]string{"card_no", "id_card", "mobile", "password"}, patterns)
+	assert.Nil(t, err)
+	return m
+}
+
+func TestMaskValue(t *testing.T) {
+	assert.Equal(t, "6222********7890", maskValue("card_no", "6222021234567890"))
+	assert.Equal(t, "13*******78", maskValue("mobile", "13812345678"))
+	assert.Equal(t, "********", maskValue("password", "abcd1234"))
+	assert.Equal(t, "***", maskValue("", "abc"))
+}
+
+func TestMasker_MaskString(t *testing.T) {
+	m := newTestMasker(t, `\b\d{17}[\dXx]\b`)
+
+	assert.Equal(t, `{"card_no":"6222********7890","name":"tom"}`,
+		m.maskString(`{"card_no":"6222021234567890","name":"tom"}`))
+	assert.Equal(t, `{CardNo:6222********7890 Amount:100}`,
+		m.maskString(`{CardNo:6222021234567890 Amount:100}`))
+	assert.Equal(t, `mobile=13*******78&password=******`,
+		m.maskString(`mobile=13812345678&password=123456`))
+	assert.Equal(t, `Mobile: (string) (len=11) "13*******78"`,
+		m.maskString(`Mobile: (string) (len=11) "13812345678"`))
+	assert.Equal(t, `id 1101**********123X`, m.maskString(`id 11010119900307123X`))
+	assert.Equal(t, "nothing here", m.maskString("nothing here"))
+
+	_, err := newMasker(nil, []string{"("})
+	assert.NotNil(t, err)
+}
+
+func TestMasker_Mask(t *testing.T) {
+	m := newTestMasker(t)
+
+	req := &payRequest{
+		CardNo: "6222021234567890",
+		Amount: 100,
+		User:   &payUser{Name: "tom", Mobile: "13812345678", Token: "t0ken-value1", Secret: "s3cr3t"},
+		Extra:  map[string]interface{}{"id_card": "110101199003071234", "memo": "ok"},
+	}
+
+	masked := m.mask(req).(*payRequest)
+	assert.Equal(t, "6222********7890", masked.CardNo)
+	assert.Equal(t, int64(100), masked.Amount)
+	assert.Equal(t, "tom", masked.User.Name)
+	assert.Equal(t, "13*******78", masked.User.Mobile)
+	assert.Equal(t, "t0k******ue1", masked.User.Token)
+	assert.Equal(t, "******", masked.User.Secret)
+	assert.Equal(t, "1101**********1234", masked.Extra["id_card"])
+	assert.Equal(t, "ok", masked.Extra["memo"])
+
+	// the origin is not changed
+	assert.Equal(t, "6222021234567890", req.CardNo)
+	assert.Equal(t, "13812345678", req.User.Mobile)
+	assert.Equal(t, "110101199003071234", req.Extra["id_card"])
+
+	var nilMasker *masker
+	assert.Equal(t, req, nilMasker.mask(req))
+}
+
+type cardInfo struct {
+	CardNo int64 `json:"card_no"`
+	Mobile []string
+}
+
+func TestMasker_MaskNumber(t *testing.T) {
+	m := newTestMasker(t)
+
+	// interface{} 中的数字按字符串脱敏, 有类型的数字置零
+	masked := m.mask(map[string]interface{}{"card_no": int64(6222021234567890), "amount": 100}).(map[string]interface{})
+	assert.Equal(t, "6222********7890", masked["card_no"])
+	assert.Equal(t, 100, masked["amount"])
+
+	card := m.mask(cardInfo{CardNo: 6222021234567890}).(cardInfo)
+	assert.Equal(t, int64(0), card.CardNo)
+
+	// 数组和敏感字段下的列表
+	cards := m.mask([2]cardInfo{{Mobile: []string{"13812345678"}}, {CardNo: 1}}).([2]cardInfo)
+	assert.Equal(t, []string{"13*******78"}, cards[0].Mobile)
+	assert.Equal(t, int64(0), cards[1].CardNo)
+
+	list := m.mask(map[string]interface{}{"mobile": []interface{}{"13812345678", 13912345678}}).(map[string]interface{})
+	assert.Equal(t, []interface{}{"13*******78", "13*******78"}, list["mobile"])
+
+	encConf := zap.NewProductionEncoderConfig()
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(newMaskEncoder(zapcore.NewJSONEncoder(encConf), m), zapcore.AddSync(buf), zap.DebugLevel)
+	zap.New(core).With(zap.Uint64("card_no", 6222021234567890)).Info("pay", zap.Int64("card_no", 6222021234567890))
+	assert.NotContains(t, buf.String(), "6222021234567890")
+}
+
+func TestMaskEncoder(t *testing.T) {
+	m := newTestMasker(t)
+	encConf := zap.NewProductionEncoderConfig()
+	encConf.TimeKey = ""
+
+	for _, enc := range []zapcore.Encoder{
+		zapcore.NewJSONEncoder(encConf),
+		zapcore.NewConsoleEncoder(encConf),
+	} {
+		buf := &bytes.Buffer{}
+		core := zapcore.NewCore(newMaskEncoder(enc, m), zapcore.AddSync(buf), zap.DebugLevel)
+		logger := zap.New(core).Sugar().With("params", `{"mobile":"13812345678"}`, "card_no", "6222021234567890")
+
+		logger.Infow(fmt.Sprintf("body %+v", payRequest{CardNo: "6222021234567890"}),
+			"password", "123456", "req", payRequest{CardNo: "6222021234567890"})
+
+		out := buf.String()
+		assert.NotContains(t, out, "6222021234567890")
+		assert.NotContains(t, out, "13812345678")
+		assert.NotContains(t, out, "123456")
+		assert.Contains(t, out, "6222********7890")
+	}
+}
+
+func TestLogger_Mask(t *testing.T) {
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(config.NewMemConfig()))
+	assert.Equal(t, "6222********7890", l.Mask(payRequest{CardNo: "6222021234567890"}).(payRequest).CardNo)
+
+	conf := config.NewMemConfig()
+	conf.Set("log_mask_off", true)
+	l = NewLogger(opt.WithLoggerConf(conf))
+	assert.Equal(t, "6222021234567890", l.Mask(payRequest{CardNo: "6222021234567890"}).(payRequest).CardNo)
+}
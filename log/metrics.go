@@ -18,6 +18,16 @@ var logMetricErrorCounter = metrics.CreateMetricCount(
 	[]string{meta.ServiceName, "caller"}...,
 )
 
+var logDroppedCounter = metrics.CreateMetricCount(
+	"log_dropped_stats",
+	[]string{"reason", "level"}...,
+)
+
+var logAsyncQueueGauge = metrics.CreateMetricGauge(
+	"log_async_queue_depth",
+	[]string{"writer"}...,
+)
+
 func addErrMetric(entry zapcore.Entry) error {
 	if entry.Level == zap.ErrorLevel {
 		logMetricErrorCounter.Inc(config.GetString("appname"), funcName(7))
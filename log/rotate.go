@@ -0,0 +1,351 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	RotateSize   = "size"
+	RotateDaily  = "daily"
+	RotateHourly = "hourly"
+)
+
+const (
+	defaultDailyPattern  = "2006-01-02"
+	defaultHourlyPattern = "2006-01-02-15"
+	compressSuffix       = ".gz"
+)
+
+// newFileWriter builds the writer of file by the rotate config,
+// size rotation is done by lumberjack.
+func (c *Config) newFileWriter(file string) zapcore.WriteSyncer {
+	if c.Rotate == RotateDaily || c.Rotate == RotateHourly {
+		return newRotateFile(file, c)
+	}
+
+	return sizeRotateFile{&lumberjack.Logger{
+		Filename:   file,
+		MaxSize:    c.MaxSize,
+		MaxBackups: c.BackupCount,
+		MaxAge:     c.MaxAge,
+		Compress:   c.Compress,
+	}}
+}
+
+// sizeRotateFile keeps the Close of lumberjack, which zapcore.AddSync hides.
+type sizeRotateFile struct {
+	*lumberjack.Logger
+}
+
+func (sizeRotateFile) Sync() error {
+	return nil
+}
+
+// rotateFile rotates the file every day or hour, and when it grows over maxSize.
+// The rotated file is named as filename.<pattern>, e.g. esim.log.2006-01-02,
+// the same name in one period is suffixed with .1, .2 ...
+type rotateFile struct {
+	filename   string
+	hourly     bool
+	pattern    string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	periodAt time.Time
+	nextAt   time.Time
+
+	// 串行压缩和清理
+	millMu sync.Mutex
+
+	now func() time.Time
+}
+
+func newRotateFile(filename string, c *Config) *rotateFile {
+	r := &rotateFile{
+		filename:   filename,
+		hourly:     c.Rotate == RotateHourly,
+		pattern:    c.RotatePattern,
+		maxSize:    int64(c.MaxSize) * 1024 * 1024,
+		maxBackups: c.BackupCount,
+		maxAge:     time.Duration(c.MaxAge) * 24 * time.Hour,
+		compress:   c.Compress,
+		now:        time.Now,
+	}
+
+	if r.pattern == "" {
+		r.pattern = defaultDailyPattern
+		if r.hourly {
+			r.pattern = defaultHourlyPattern
+		}
+	}
+
+	return r
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.file == nil {
+		if err := r.openExisting(now); err != nil {
+			return 0, err
+		}
+	}
+
+	if !now.Before(r.nextAt) || (r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize) {
+		if err := r.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *rotateFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Sync()
+}
+
+// Close closes the current file.
+func (r *rotateFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
+
+// openExisting appends to the existing file, it is rotated
+// at the first write if it belongs to an earlier period.
+func (r *rotateFile) openExisting(now time.Time) error {
+	info, err := os.Stat(r.filename)
+	if os.IsNotExist(err) {
+		return r.openNew(now)
+	} else if err != nil {
+		return fmt.Errorf("log: stat %s: %v", r.filename, err)
+	}
+
+	file, err := os.OpenFile(r.filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return r.openNew(now)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.periodAt = r.periodStart(info.ModTime())
+	r.nextAt = r.nextPeriod(r.periodAt)
+
+	return nil
+}
+
+func (r *rotateFile) openNew(now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(r.filename), 0755); err != nil {
+		return fmt.Errorf("log: make dir of %s: %v", r.filename, err)
+	}
+
+	file, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("log: open %s: %v", r.filename, err)
+	}
+
+	r.file = file
+	r.size = 0
+	r.periodAt = r.periodStart(now)
+	r.nextAt = r.nextPeriod(r.periodAt)
+
+	return nil
+}
+
+func (r *rotateFile) rotate(now time.Time) error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	backup := r.backupName(r.periodAt)
+	if err := os.Rename(r.filename, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("log: rename %s: %v", r.filename, err)
+	}
+
+	if err := r.openNew(now); err != nil {
+		return err
+	}
+
+	go r.mill(backup)
+
+	return nil
+}
+
+func (r *rotateFile) backupName(periodAt time.Time) string {
+	name := r.filename + "." + periodAt.Format(r.pattern)
+	backup := name
+	for i := 1; exists(backup) || exists(backup+compressSuffix); i++ {
+		backup = fmt.Sprintf("%s.%d", name, i)
+	}
+
+	return backup
+}
+
+func (r *rotateFile) periodStart(t time.Time) time.Time {
+	if r.hourly {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func (r *rotateFile) nextPeriod(periodAt time.Time) time.Time {
+	if r.hourly {
+		return periodAt.Add(time.Hour)
+	}
+
+	return periodAt.AddDate(0, 0, 1)
+}
+
+// mill compresses the rotated file and removes the old files.
+func (r *rotateFile) mill(backup string) {
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	if r.compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "log: compress %s: %v\n", backup, err)
+		}
+	}
+
+	if err := r.removeOld(); err != nil {
+		fmt.Fprintf(os.Stderr, "log: remove old files of %s: %v\n", r.filename, err)
+	}
+}
+
+// removeOld keeps at most maxBackups rotated files which are newer than maxAge.
+func (r *rotateFile) removeOld() error {
+	if r.maxBackups <= 0 && r.maxAge <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(r.filename + ".*")
+	if err != nil {
+		return err
+	}
+
+	type backupInfo struct {
+		name    string
+		modTime time.Time
+	}
+
+	backups := make([]backupInfo, 0, len(matches))
+	for _, name := range matches {
+		if !r.isBackup(name) {
+			continue
+		}
+
+		info, err := os.Stat(name)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		backups = append(backups, backupInfo{name: name, modTime: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	cutoff := r.now().Add(-r.maxAge)
+	for i, backup := range backups {
+		if (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && backup.modTime.Before(cutoff)) {
+			if err := os.Remove(backup.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isBackup reports whether name is filename.<pattern>[.N][.gz].
+func (r *rotateFile) isBackup(name string) bool {
+	suffix := strings.TrimSuffix(strings.TrimPrefix(name, r.filename+"."), compressSuffix)
+	if _, err := time.Parse(r.pattern, suffix); err == nil {
+		return true
+	}
+
+	if i := strings.LastIndex(suffix, "."); i > 0 {
+		if _, err := strconv.Atoi(suffix[i+1:]); err == nil {
+			_, err = time.Parse(r.pattern, suffix[:i])
+			return err == nil
+		}
+	}
+
+	return false
+}
+
+func compressFile(name string) error {
+	if strings.HasSuffix(name, compressSuffix) {
+		return nil
+	}
+
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(name + compressSuffix)
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRotateFile(t *testing.T, c *Config) (*rotateFile, *time.Time) {
+	dir, err := ioutil.TempDir("", "esim-log")
+	assert.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	now := time.Date(2020, 8, 1, 23, 59, 0, 0, time.Local)
+	r := newRotateFile(filepath.Join(dir, "esim.log"), c)
+	r.now = func() time.Time { return now }
+	t.Cleanup(func() { r.Close() })
+
+	return r, &now
+}
+
+func TestRotateFile_Daily(t *testing.T) {
+	r, now := newTestRotateFile(t, &Config{Rotate: RotateDaily, Compress: true})
+
+	_, err := r.Write([]byte("day 1\n"))
+	assert.Nil(t, err)
+
+	*now = now.Add(2 * time.Minute)
+	_, err = r.Write([]byte("day 2\n"))
+	assert.Nil(t, err)
+
+	// wait for the compression
+	assert.Eventually(t, func() bool {
+		return exists(r.filename + ".2020-08-01.gz")
+	}, time.Second, 10*time.Millisecond)
+
+	f, err := os.Open(r.filename + ".2020-08-01.gz")
+	assert.Nil(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	content, err := ioutil.ReadAll(gz)
+	assert.Nil(t, err)
+	assert.Equal(t, "day 1\n", string(content))
+
+	content, err = ioutil.ReadFile(r.filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "day 2\n", string(content))
+}
+
+func TestRotateFile_HourlyAndSize(t *testing.T) {
+	r, now := newTestRotateFile(t, &Config{Rotate: RotateHourly})
+	r.maxSize = 10
+
+	_, _ = r.Write([]byte("hour 23 a\n"))
+	_, _ = r.Write([]byte("hour 23 b\n"))
+	*now = now.Add(time.Hour)
+	_, _ = r.Write([]byte("hour 0\n"))
+
+	assert.True(t, exists(r.filename+".2020-08-01-23"))
+	assert.True(t, exists(r.filename+".2020-08-01-23.1"))
+	assert.False(t, exists(r.filename+".2020-08-01-23.gz"))
+}
+
+func TestRotateFile_RemoveOld(t *testing.T) {
+	r, now := newTestRotateFile(t, &Config{Rotate: RotateDaily, BackupCount: 2, MaxAge: 3})
+
+	dir := filepath.Dir(r.filename)
+	for i, day := range []string{"2020-07-31", "2020-07-30", "2020-07-29.gz", "2020-07-20"} {
+		name := r.filename + "." + day
+		assert.Nil(t, ioutil.WriteFile(name, []byte(day), 0644))
+		mod := now.AddDate(0, 0, -i-1)
+		if i == 3 {
+			mod = now.AddDate(0, 0, -12)
+		}
+		assert.Nil(t, os.Chtimes(name, mod, mod))
+	}
+	// not a backup
+	assert.Nil(t, ioutil.WriteFile(r.filename+".error", []byte("keep"), 0644))
+
+	assert.Nil(t, r.removeOld())
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimPrefix(f.Name(), "esim.log."))
+	}
+	assert.ElementsMatch(t, []string{"2020-07-31", "2020-07-30", "error"}, names)
+}
+
+func TestLogger_ErrorFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esim-log")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "file")
+	conf.Set("log_level", "info")
+	conf.Set("log_rotate", "daily")
+	conf.Set("log_file", filepath.Join(dir, "esim.log"))
+	conf.Set("log_error_file", filepath.Join(dir, "error.log"))
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	l.Infof("info message")
+	l.Errorf("error message")
+	assert.Nil(t, l.Sync())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "esim.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "info message")
+	assert.Contains(t, string(content), "error message")
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "error.log"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "info message")
+	assert.Contains(t, string(content), "error message")
+
+	// Close 关闭两个按天切割的文件
+	var files []*rotateFile
+	for _, c := range l.(*logger).closers {
+		if r, ok := c.(*rotateFile); ok {
+			files = append(files, r)
+		}
+	}
+	assert.Len(t, files, 2)
+
+	assert.Nil(t, Close(l))
+	for _, r := range files {
+		assert.Nil(t, r.file)
+	}
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	_numLevels        = zapcore.FatalLevel - zapcore.DebugLevel + 1
+	_countersPerLevel = 4096
+)
+
+// counters is a fixed-size table like zap's sampler, the keys are hashed into it,
+// so the dynamic messages can not grow the memory. The colliding keys share a counter.
+type counters [_countersPerLevel]sampleCounter
+
+func (cs *counters) get(key string) *sampleCounter {
+	return &cs[fnv32a(key)%_countersPerLevel]
+}
+
+// fnv32a is the inlined hash/fnv, it does not allocate.
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+
+	return hash
+}
+
+// sampler logs the first N entries of every message template and level
+// in each tick, and every Mth entry thereafter.
+type sampler struct {
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	counts     [_numLevels]counters
+}
+
+type sampleCounter struct {
+	resetAt int64
+	n       uint64
+}
+
+func newSampler(tick time.Duration, first, thereafter int) *sampler {
+	if first <= 0 {
+		return nil
+	}
+
+	if tick <= 0 {
+		tick = time.Second
+	}
+
+	return &sampler{
+		tick:       tick,
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+	}
+}
+
+// allow reports whether the entry should be written.
+// A nil sampler allows everything.
+func (s *sampler) allow(lvl zapcore.Level, template string) bool {
+	if s == nil {
+		return true
+	}
+
+	if lvl < zapcore.DebugLevel || lvl > zapcore.FatalLevel {
+		return true
+	}
+
+	n := s.counts[lvl-zapcore.DebugLevel].get(template).incCheckReset(time.Now(), s.tick)
+	if n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0) {
+		return true
+	}
+
+	logDroppedCounter.Inc("sampling", lvl.String())
+
+	return false
+}
+
+func (c *sampleCounter) incCheckReset(t time.Time, tick time.Duration) uint64 {
+	now := t.UnixNano()
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if resetAt > now {
+		return atomic.AddUint64(&c.n, 1)
+	}
+
+	atomic.StoreUint64(&c.n, 1)
+	if !atomic.CompareAndSwapInt64(&c.resetAt, resetAt, now+tick.Nanoseconds()) {
+		return atomic.AddUint64(&c.n, 1)
+	}
+
+	return 1
+}
+
+// RateLimiter allows at most limit entries per key in every interval,
+// the keys are hashed into a fixed table as the sampler does.
+//
+//	limiter := log.NewRateLimiter(10, time.Second)
+//	if limiter.Allow("grpc:" + method) {
+//		logger.Infoc(ctx, "...")
+//	}
+type RateLimiter struct {
+	limit    uint64
+	interval time.Duration
+	counts   counters
+}
+
+func NewRateLimiter(limit int, interval time.Duration) *RateLimiter {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &RateLimiter{
+		limit:    uint64(limit),
+		interval: interval,
+	}
+}
+
+// Allow reports whether an entry of key can be logged now,
+// the rejected entries are counted as dropped.
+func (r *RateLimiter) Allow(key string) bool {
+	if r.counts.get(key).incCheckReset(time.Now(), r.interval) <= r.limit {
+		return true
+	}
+
+	logDroppedCounter.Inc("ratelimit", "")
+
+	return false
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"hash/fnv"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSampler_Allow(t *testing.T) {
+	s := newSampler(time.Minute, 2, 3)
+
+	var allowed int
+	for i := 0; i < 11; i++ {
+		if s.allow(zap.InfoLevel, "order %s paid") {
+			allowed++
+		}
+	}
+	// 1, 2, 5, 8, 11
+	assert.Equal(t, 5, allowed)
+
+	// another level and template are counted apart
+	assert.True(t, s.allow(zap.WarnLevel, "order %s paid"))
+	assert.True(t, s.allow(zap.InfoLevel, "order %s refund"))
+
+	var nilSampler *sampler
+	assert.True(t, nilSampler.allow(zap.InfoLevel, "order %s paid"))
+	assert.Nil(t, newSampler(time.Second, 0, 10))
+}
+
+func TestSampler_Reset(t *testing.T) {
+	s := newSampler(20*time.Millisecond, 1, 0)
+	assert.True(t, s.allow(zap.InfoLevel, "tick"))
+	assert.False(t, s.allow(zap.InfoLevel, "tick"))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, s.allow(zap.InfoLevel, "tick"))
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_level", "info")
+	conf.Set("log_sample_initial", 1)
+	conf.Set("log_sample_thereafter", 0)
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf)).(*logger)
+	assert.Equal(t, time.Second, l.Config.SampleTick)
+	assert.NotNil(t, l.sampler)
+
+	assert.True(t, l.allow(zap.InfoLevel, "sampled"))
+	assert.False(t, l.allow(zap.InfoLevel, "sampled"))
+	// disabled entries do not use up the quota
+	assert.True(t, l.allow(zap.DebugLevel, "debug"))
+	assert.True(t, l.allow(zap.DebugLevel, "debug"))
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	r := NewRateLimiter(2, time.Minute)
+	assert.True(t, r.Allow("a"))
+	assert.True(t, r.Allow("a"))
+	assert.False(t, r.Allow("a"))
+	assert.True(t, r.Allow("b"))
+}
+
+func TestFnv32a(t *testing.T) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("order %s paid"))
+	assert.Equal(t, h.Sum32(), fnv32a("order %s paid"))
+}
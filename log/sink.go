@@ -0,0 +1,191 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkConfig is an output besides log_output, e.g.
+//
+//	log_sinks:
+//	  - name: collector
+//	    type: tcp
+//	    addr: 10.0.0.1:5140
+//	    level: info
+//	  - name: local
+//	    type: syslog
+//	    tag: esim
+type SinkConfig struct {
+	Name string `mapstructure:"name"`
+
+	// stdout|file|syslog|tcp|udp or a type registered by RegisterSink
+	Type string `mapstructure:"type"`
+
+	// 为空时跟随 log_level
+	Level string `mapstructure:"level"`
+
+	// json|text, 为空时使用 log_format, tcp 和 udp 固定为 json
+	Format string `mapstructure:"format"`
+
+	// tcp, udp 和 syslog 的地址, syslog 为空时写本机
+	Addr string `mapstructure:"addr"`
+
+	// file 的路径, 切割方式同 log_file
+	File string `mapstructure:"file"`
+
+	// syslog 的 tag
+	Tag string `mapstructure:"tag"`
+
+	// 自定义 sink 的参数
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+// SinkFactory builds the writer of a sink.
+// The writer can implement LevelWriter to know the level of each entry.
+type SinkFactory func(conf SinkConfig, logConf *Config) (zapcore.WriteSyncer, error)
+
+// LevelWriter writes an encoded entry with its level, e.g. syslog.
+type LevelWriter interface {
+	WriteLevel(lvl zapcore.Level, p []byte) (int, error)
+}
+
+var (
+	sinkMu        sync.RWMutex
+	sinkFactories = map[string]SinkFactory{
+		"stdout": func(SinkConfig, *Config) (zapcore.WriteSyncer, error) {
+			return zapcore.AddSync(os.Stdout), nil
+		},
+		"file": func(conf SinkConfig, logConf *Config) (zapcore.WriteSyncer, error) {
+			if conf.File == "" {
+				return nil, fmt.Errorf("log: file sink %s has no file", conf.Name)
+			}
+			return logConf.newFileWriter(conf.File), nil
+		},
+	}
+)
+
+// RegisterSink makes a sink type available in log_sinks,
+// a registered type is replaced.
+func RegisterSink(typ string, factory SinkFactory) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+
+	sinkFactories[typ] = factory
+}
+
+func getSinkFactory(typ string) (SinkFactory, bool) {
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+
+	factory, ok := sinkFactories[typ]
+	return factory, ok
+}
+
+func (l *logger) buildSinks() ([]zapcore.Core, error) {
+	cores := make([]zapcore.Core, 0, len(l.Config.Sinks))
+	for i, conf := range l.Config.Sinks {
+		if conf.Name == "" {
+			conf.Name = fmt.Sprintf("%s_%d", conf.Type, i)
+		}
+
+		factory, ok := getSinkFactory(conf.Type)
+		if !ok {
+			return nil, fmt.Errorf("log: unknown sink type %q of %s", conf.Type, conf.Name)
+		}
+
+		ws, err := factory(conf, l.Config)
+		if err != nil {
+			return nil, err
+		}
+
+		format := conf.Format
+		if conf.Type == "tcp" || conf.Type == "udp" {
+			format = "json"
+		} else if format == "" {
+			format = l.Config.Format
+		}
+
+		var enabler zapcore.LevelEnabler = l.level
+		if conf.Level != "" {
+			enabler = zap.NewAtomicLevelAt(ParseLevel(conf.Level))
+		}
+
+		ws = l.wrapWriter(conf.Name, ws)
+		cores = append(cores, &sinkCore{
+			LevelEnabler: enabler,
+			enc:          l.buildEncoder(format),
+			ws:           ws,
+			lw:           levelWriterOf(ws),
+		})
+	}
+
+	return cores, nil
+}
+
+// the async writer keeps the level for the LevelWriter it wraps.
+func levelWriterOf(ws zapcore.WriteSyncer) LevelWriter {
+	lw, _ := ws.(LevelWriter)
+	return lw
+}
+
+// sinkCore works as zapcore.ioCore, and passes the level to LevelWriter.
+type sinkCore struct {
+	zapcore.LevelEnabler
+
+	enc zapcore.Encoder
+	ws  zapcore.WriteSyncer
+	lw  LevelWriter
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &sinkCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          c.enc.Clone(),
+		ws:           c.ws,
+		lw:           c.lw,
+	}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+
+	return clone
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	if c.lw != nil {
+		_, err = c.lw.WriteLevel(ent.Level, buf.Bytes())
+	} else {
+		_, err = c.ws.Write(buf.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+
+	if ent.Level > zapcore.ErrorLevel {
+		return c.Sync()
+	}
+
+	return nil
+}
+
+func (c *sinkCore) Sync() error {
+	return c.ws.Sync()
+}
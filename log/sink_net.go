@@ -0,0 +1,187 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	netDialTimeout  = time.Second
+	netWriteTimeout = time.Second
+	netMinBackoff   = 100 * time.Millisecond
+	netMaxBackoff   = 30 * time.Second
+	netQueueSize    = 4096
+)
+
+func init() {
+	RegisterSink("tcp", newNetSink)
+	RegisterSink("udp", newNetSink)
+}
+
+func newNetSink(conf SinkConfig, _ *Config) (zapcore.WriteSyncer, error) {
+	if conf.Addr == "" {
+		return nil, fmt.Errorf("log: %s sink %s has no addr", conf.Type, conf.Name)
+	}
+
+	return newNetWriter(conf.Type, conf.Addr), nil
+}
+
+// netWriter sends the newline-delimited entries to addr in a background goroutine,
+// so it never blocks the caller for a broken connection. The entries wait in
+// a bounded queue and are dropped when it is full or while disconnected,
+// the dial interval backs off up to netMaxBackoff.
+type netWriter struct {
+	network string
+	addr    string
+
+	entries chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+
+	// 以下只在 run 协程中使用
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+
+	dial func(network, addr string) (net.Conn, error)
+}
+
+func newNetWriter(network, addr string) *netWriter {
+	w := &netWriter{
+		network: network,
+		addr:    addr,
+		entries: make(chan []byte, netQueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		dial: func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, netDialTimeout)
+		},
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	// zap 会复用 p
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	select {
+	case <-w.stop:
+		logDroppedCounter.Inc("sink_"+w.network, "")
+	case w.entries <- entry:
+	default:
+		logDroppedCounter.Inc("sink_"+w.network, "")
+	}
+
+	return len(p), nil
+}
+
+func (w *netWriter) run() {
+	defer close(w.done)
+
+	for {
+		select {
+		case entry := <-w.entries:
+			w.send(entry)
+		case <-w.stop:
+			w.drain()
+			if w.conn != nil {
+				w.conn.Close()
+				w.conn = nil
+			}
+			return
+		}
+	}
+}
+
+// drain sends the queued entries on the current connection for up to netWriteTimeout.
+func (w *netWriter) drain() {
+	deadline := time.Now().Add(netWriteTimeout)
+	for w.conn != nil && time.Now().Before(deadline) {
+		select {
+		case entry := <-w.entries:
+			_ = w.conn.SetWriteDeadline(deadline)
+			if _, err := w.conn.Write(entry); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (w *netWriter) send(entry []byte) {
+	if w.conn == nil && !w.connect() {
+		logDroppedCounter.Inc("sink_"+w.network, "")
+		return
+	}
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+	if _, err := w.conn.Write(entry); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.retryAt = time.Time{}
+
+		// 重连后重试一次
+		if !w.connect() {
+			logDroppedCounter.Inc("sink_"+w.network, "")
+			return
+		}
+
+		_ = w.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+		if _, err = w.conn.Write(entry); err != nil {
+			w.conn.Close()
+			w.conn = nil
+			logDroppedCounter.Inc("sink_"+w.network, "")
+		}
+	}
+}
+
+// connect dials addr if the backoff is over.
+func (w *netWriter) connect() bool {
+	now := time.Now()
+	if now.Before(w.retryAt) {
+		return false
+	}
+
+	conn, err := w.dial(w.network, w.addr)
+	if err != nil {
+		if w.backoff < netMinBackoff {
+			w.backoff = netMinBackoff
+		} else if w.backoff *= 2; w.backoff > netMaxBackoff {
+			w.backoff = netMaxBackoff
+		}
+		w.retryAt = now.Add(w.backoff)
+
+		return false
+	}
+
+	w.conn = conn
+	w.backoff = 0
+
+	return true
+}
+
+func (w *netWriter) Sync() error {
+	return nil
+}
+
+// Close sends the queued entries for up to netWriteTimeout and closes the connection,
+// the later entries are dropped.
+func (w *netWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+
+	return nil
+}
@@ -0,0 +1,71 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"log/syslog"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	RegisterSink("syslog", newSyslogSink)
+}
+
+// newSyslogSink writes to the local syslog when addr is empty,
+// otherwise to addr over udp.
+func newSyslogSink(conf SinkConfig, _ *Config) (zapcore.WriteSyncer, error) {
+	network := ""
+	if conf.Addr != "" {
+		network = "udp"
+	}
+
+	w, err := syslog.Dial(network, conf.Addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, conf.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogWriter{w: w}, nil
+}
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// WriteLevel maps the level to the syslog severity.
+func (s *syslogWriter) WriteLevel(lvl zapcore.Level, p []byte) (int, error) {
+	msg := string(p)
+
+	var err error
+	switch {
+	case lvl >= zapcore.DPanicLevel:
+		err = s.w.Crit(msg)
+	case lvl == zapcore.ErrorLevel:
+		err = s.w.Err(msg)
+	case lvl == zapcore.WarnLevel:
+		err = s.w.Warning(msg)
+	case lvl == zapcore.InfoLevel:
+		err = s.w.Info(msg)
+	default:
+		err = s.w.Debug(msg)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *syslogWriter) Sync() error {
+	return nil
+}
+
+func (s *syslogWriter) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,221 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+type memSink struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	levels []zapcore.Level
+}
+
+func (m *memSink) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buf.Write(p)
+}
+
+func (m *memSink) WriteLevel(lvl zapcore.Level, p []byte) (int, error) {
+	m.mu.Lock()
+	m.levels = append(m.levels, lvl)
+	m.mu.Unlock()
+	return m.Write(p)
+}
+
+func (m *memSink) Sync() error { return nil }
+
+func TestLogger_CustomSink(t *testing.T) {
+	sink := &memSink{}
+	RegisterSink("mem", func(conf SinkConfig, _ *Config) (zapcore.WriteSyncer, error) {
+		assert.Equal(t, "v", conf.Options["k"])
+		return sink, nil
+	})
+
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "none")
+	conf.Set("log_level", "debug")
+	conf.Set("log_sinks", []interface{}{
+		map[string]interface{}{"name": "mem", "type": "mem", "level": "warn",
+			"format": "json", "options": map[string]interface{}{"k": "v"}},
+	})
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	l.Infof("info message")
+	l.Warnf("warn message")
+	l.With(context.TODO(), "order", "o001").Errorf("error message")
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, []zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel}, sink.levels)
+
+	out := sink.buf.String()
+	assert.NotContains(t, out, "info message")
+	assert.Contains(t, out, `"msg":"warn message"`)
+	assert.Contains(t, out, `"order":"o001"`)
+}
+
+// gatedSink blocks the writes until gate is closed.
+type gatedSink struct {
+	memSink
+	gate chan struct{}
+}
+
+func (g *gatedSink) WriteLevel(lvl zapcore.Level, p []byte) (int, error) {
+	<-g.gate
+	return g.memSink.WriteLevel(lvl, p)
+}
+
+// log_async 时 LevelWriter 也经过异步写入, 且保留级别
+func TestLogger_AsyncLevelSink(t *testing.T) {
+	sink := &gatedSink{gate: make(chan struct{})}
+	RegisterSink("mem_async", func(SinkConfig, *Config) (zapcore.WriteSyncer, error) {
+		return sink, nil
+	})
+
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "none")
+	conf.Set("log_async", true)
+	conf.Set("log_sinks", []interface{}{
+		map[string]interface{}{"name": "mem_async", "type": "mem_async", "format": "json"},
+	})
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	// sink 阻塞时不等待
+	l.Infof("info message")
+	l.Warnf("warn message")
+	close(sink.gate)
+	assert.Nil(t, Close(l))
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel}, sink.levels)
+	assert.Contains(t, sink.buf.String(), `"msg":"warn message"`)
+}
+
+func TestLogger_UnknownSink(t *testing.T) {
+	conf := config.NewMemConfig()
+	conf.Set("log_sinks", []interface{}{map[string]interface{}{"type": "unknown"}})
+
+	opt := LoggerOptions{}
+	assert.Panics(t, func() { NewLogger(opt.WithLoggerConf(conf)) })
+}
+
+func TestLogger_TCPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "none")
+	conf.Set("log_format", "text")
+	conf.Set("log_sinks", []interface{}{
+		map[string]interface{}{"name": "collector", "type": "tcp", "addr": ln.Addr().String()},
+	})
+
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(conf))
+	l.Infof("ship %d", 1)
+
+	select {
+	case line := <-lines:
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "ship 1", entry["msg"])
+	case <-time.After(time.Second):
+		t.Fatal("no entry received")
+	}
+}
+
+func TestNetWriter_Backoff(t *testing.T) {
+	var dials int
+	// 不启动 run, 直接调用 send
+	w := &netWriter{network: "tcp", addr: "127.0.0.1:1"}
+	w.dial = func(string, string) (net.Conn, error) {
+		dials++
+		return nil, errors.New("refused")
+	}
+
+	for i := 0; i < 10; i++ {
+		w.send([]byte("x\n"))
+	}
+	// the later entries wait for the backoff
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, netMinBackoff, w.backoff)
+
+	w.retryAt = time.Time{}
+	w.send([]byte("x\n"))
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 2*netMinBackoff, w.backoff)
+
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() { _, _ = bufio.NewReader(server).ReadString('\n') }()
+
+	w.retryAt = time.Time{}
+	w.dial = func(string, string) (net.Conn, error) { return client, nil }
+	w.send([]byte("x\n"))
+	assert.NotNil(t, w.conn)
+	assert.Equal(t, time.Duration(0), w.backoff)
+	assert.Nil(t, w.conn.Close())
+}
+
+// 连接卡住时 Write 不阻塞调用方
+func TestNetWriter_NonBlocking(t *testing.T) {
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+
+	w := newNetWriter("tcp", "127.0.0.1:1")
+	w.dial = func(string, string) (net.Conn, error) {
+		close(dialing)
+		<-release
+		return nil, errors.New("timeout")
+	}
+
+	_, _ = w.Write([]byte("x\n"))
+	<-dialing
+
+	beg := time.Now()
+	for i := 0; i < netQueueSize*2; i++ {
+		n, err := w.Write([]byte("x\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+	}
+	assert.Less(t, int64(time.Since(beg)), int64(netDialTimeout))
+
+	close(release)
+	assert.Nil(t, w.Close())
+
+	n, err := w.Write([]byte("x\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
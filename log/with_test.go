@@ -0,0 +1,74 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogger_With(t *testing.T) {
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(config.NewMemConfig()))
+
+	ctx := meta.NewContext(context.Background(), meta.MD{
+		meta.AppID:     "app",
+		meta.MerID:     "m001",
+		meta.RequestNo: "r001",
+	})
+
+	child := l.With(ctx, "order", "o001").(*logger)
+	assert.Equal(t, []interface{}{meta.AppID, "app", meta.RequestNo, "r001",
+		meta.MerID, "m001", "order", "o001"}, child.fields)
+
+	// the bound keys are not logged twice
+	assert.Empty(t, child.ctxArgs(ctx))
+
+	grandson := child.With(context.Background(), "step", 2).(*logger)
+	assert.Equal(t, append(child.fields, "step", 2), grandson.fields)
+	assert.Empty(t, l.(*logger).fields)
+
+	grandson.Infoc(ctx, "with fields")
+}
+
+func TestLogger_CtxArgs(t *testing.T) {
+	opt := LoggerOptions{}
+	l := NewLogger(opt.WithLoggerConf(config.NewMemConfig())).(*logger)
+
+	ctx := meta.NewContext(context.Background(), meta.MD{
+		meta.TranCd: "T01",
+		meta.MerID:  "m001",
+	})
+	assert.Equal(t, []interface{}{meta.TranCd, "T01", meta.MerID, "m001"}, l.ctxArgs(ctx))
+	assert.Empty(t, l.ctxArgs(context.Background()))
+}
+
+// Error 也要带上 With 绑定的字段
+func TestLogger_WithError(t *testing.T) {
+	sink := &memSink{}
+	RegisterSink("mem_error", func(conf SinkConfig, _ *Config) (zapcore.WriteSyncer, error) {
+		return sink, nil
+	})
+
+	conf := config.NewMemConfig()
+	conf.Set("log_output", "none")
+	conf.Set("log_sinks", []interface{}{
+		map[string]interface{}{"name": "mem_error", "type": "mem_error", "format": "json"},
+	})
+
+	opt := LoggerOptions{}
+	ctx := meta.NewContext(context.Background(), meta.MD{meta.MerID: "m001"})
+	NewLogger(opt.WithLoggerConf(conf)).With(ctx, "order", "o001").Error("error message")
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	out := sink.buf.String()
+	assert.Contains(t, out, `"msg":"error message"`)
+	assert.Contains(t, out, `"order":"o001"`)
+	assert.Contains(t, out, `"merid":"m001"`)
+}
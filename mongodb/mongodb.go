@@ -2,11 +2,13 @@ package mongodb
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/container"
 	"github.com/Hyingerrr/mirco-esim/log"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -24,12 +26,15 @@ const (
 	keyCtx mgoCtxKey = iota + 1
 )
 
-var clientOnce sync.Once
+var clientMu sync.Mutex
 var onceClient *Client
 
 type Client struct {
 	Mgos map[string]*mongo.Client
 
+	// mgos 的配置 key
+	mgosKey string
+
 	conf config.Config
 
 	logger log.Logger
@@ -50,27 +55,75 @@ type Option func(c *Client)
 type ClientOptions struct{}
 
 func NewClient(os ...Option) *Client {
-	clientOnce.Do(func() {
-		onceClient = &Client{
-			Mgos: make(map[string]*mongo.Client),
-		}
+	c, err := newDefaultClient(os...)
+	if err != nil {
+		log.Panicf("[mongodb] %s", err.Error())
+	}
 
-		for _, o := range os {
-			o(onceClient)
-		}
+	return c
+}
 
-		if onceClient.conf == nil {
-			onceClient.conf = config.NewNullConfig()
-		}
+// NewNamedClient builds a new client of the named cluster,
+// its mgos are under mongodb_clusters.<name> in the same form as mgos.
+// The default name is the same as NewClient.
+func NewNamedClient(name string, os ...Option) *Client {
+	c, err := newNamedClient(name, os...)
+	if err != nil {
+		log.Panicf("[mongodb] %s", err.Error())
+	}
 
-		if onceClient.logger == nil {
-			onceClient.logger = log.NewLogger()
+	return c
+}
+
+func newNamedClient(name string, os ...Option) (*Client, error) {
+	if name == "" || name == container.DefaultName {
+		return newDefaultClient(os...)
+	}
+
+	return newClient("mongodb_clusters."+name, os...)
+}
+
+func newDefaultClient(os ...Option) (*Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	// 构建失败时不缓存, 下次调用重试
+	if onceClient == nil {
+		c, err := newClient("mgos", os...)
+		if err != nil {
+			return nil, err
 		}
+		onceClient = c
+	}
+
+	return onceClient, nil
+}
+
+func newClient(mgosKey string, os ...Option) (*Client, error) {
+	c := &Client{
+		mgosKey: mgosKey,
+		Mgos:    make(map[string]*mongo.Client),
+	}
+
+	for _, o := range os {
+		o(c)
+	}
+
+	if c.conf == nil {
+		c.conf = config.NewNullConfig()
+	}
+
+	if c.logger == nil {
+		c.logger = log.NewLogger()
+	}
 
-		onceClient.init()
-	})
+	if err := c.init(); err != nil {
+		// 断开已建立的连接
+		c.Close()
+		return nil, err
+	}
 
-	return onceClient
+	return c, nil
 }
 
 func (ClientOptions) WithConf(conf config.Config) Option {
@@ -102,11 +155,11 @@ type MgoConfig struct {
 	URI string `json:"uri" yaml:"uri"`
 }
 
-func (c *Client) init() {
+func (c *Client) init() error {
 	mgoConfigs := make([]MgoConfig, 0)
-	err := c.conf.UnmarshalKey("mgos", &mgoConfigs)
+	err := c.conf.UnmarshalKey(c.mgosKey, &mgoConfigs)
 	if err != nil {
-		c.logger.Panicf("Fatal error config file: %s \n", err.Error())
+		return fmt.Errorf("unmarshal %s: %s", c.mgosKey, err.Error())
 	}
 
 	if len(c.mgoConfig) > 0 {
@@ -174,7 +227,7 @@ func (c *Client) init() {
 
 		client, err := mongo.NewClient(clientOptions)
 		if err != nil {
-			c.logger.Panicf("New mongo client error: %s , uri: %s \n", err.Error(), mgo.URI)
+			return fmt.Errorf("new mongo client error: %s , uri: %s", err.Error(), mgo.URI)
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
@@ -182,17 +235,20 @@ func (c *Client) init() {
 
 		err = client.Connect(ctx)
 		if err != nil {
-			c.logger.Panicf("Conn mongo error: %s , uri: %s \n", err.Error(), mgo.URI)
+			return fmt.Errorf("conn mongo error: %s , uri: %s", err.Error(), mgo.URI)
 		}
 
 		err = client.Ping(ctx, readpref.Primary())
 		if err != nil {
-			c.logger.Panicf("Ping mongo error: %s , uri: %s \n", err.Error(), mgo.URI)
+			_ = client.Disconnect(ctx)
+			return fmt.Errorf("ping mongo error: %s , uri: %s", err.Error(), mgo.URI)
 		}
 
 		c.setMgo(mgo.Db, client)
 		c.logger.Infof("[mongodb] %s init success", mgo.Db)
 	}
+
+	return nil
 }
 
 func (c *Client) initMonitorMulLevelEvent(dbName string) MgoEvent {
@@ -262,7 +318,8 @@ func (c *Client) Ping() []error {
 	return errs
 }
 
-// Close close all connection.
+// Close close all connection, NewClient builds a new client after
+// the default client is closed.
 func (c *Client) Close() {
 	var err error
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
@@ -274,6 +331,12 @@ func (c *Client) Close() {
 			c.logger.Errorf(err.Error())
 		}
 	}
+
+	clientMu.Lock()
+	if onceClient == c {
+		onceClient = nil
+	}
+	clientMu.Unlock()
 }
 
 func (c *Client) GetCtx(ctx context.Context) context.Context {
@@ -3,7 +3,6 @@ package mongodb
 import (
 	"context"
 	"os"
-	"sync"
 	"testing"
 
 	"github.com/Hyingerrr/mirco-esim/config"
@@ -111,7 +110,7 @@ func TestGetColl(t *testing.T) {
 }
 
 func TestWithMonitorEvent(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	conf := config.NewMemConfig()
 	conf.Set("debug", true)
@@ -151,7 +150,7 @@ func TestWithMonitorEvent(t *testing.T) {
 }
 
 func TestMulEvent(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	conf := config.NewMemConfig()
 	conf.Set("debug", true)
@@ -0,0 +1,49 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/core/health"
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/google/wire"
+)
+
+// Kind of the mongodb clients in the container registry.
+const Kind = "mongodb"
+
+// Register sets the factory of the mongodb clients in r, e.g. container.Instances(),
+// call it before Named.
+func Register(r *container.Registry) {
+	r.Provide(Kind, func(name string) (interface{}, error) {
+		opts := ClientOptions{}
+		return newNamedClient(name, opts.WithConf(config.Default()), opts.WithLogger(log.Default()))
+	})
+}
+
+// ProviderSet injects the default client.
+var ProviderSet = wire.NewSet(ProvideClient)
+
+func ProvideClient() *Client {
+	return Named(container.DefaultName)
+}
+
+// Named returns the cached client of the named cluster.
+func Named(name string) *Client {
+	return container.MustGet(Kind, name).(*Client)
+}
+
+// Probe builds the named client and pings it, for App.RegisterProbe.
+// A failed build is retried by the next check.
+func Probe(name string) health.Checker {
+	return health.CheckerFunc(func(ctx context.Context) error {
+		c, err := container.Get(Kind, name)
+		if err != nil {
+			return err
+		}
+
+		return health.ErrorsChecker(c.(*Client).Ping).Check(ctx)
+	})
+}
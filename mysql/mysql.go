@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -14,13 +15,16 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-var clientOnce sync.Once
+var clientMu sync.Mutex
 
 var onceClient *Client
 
 type Client struct {
 	gdbs map[string]*gorm.DB
 
+	// dbs 的配置 key
+	dbsKey string
+
 	sqlDbs map[string]*sql.DB
 
 	dbConfigs []DbConfig
@@ -47,22 +51,72 @@ type DbConfig struct {
 }
 
 func NewClient(options ...Option) *Client {
-	clientOnce.Do(func() {
-		onceClient = &Client{
-			gdbs:        make(map[string]*gorm.DB),
-			sqlDbs:      make(map[string]*sql.DB),
-			stateTicker: 10 * time.Second,
-			closeChan:   make(chan bool, 1),
-		}
+	c, err := newDefaultClient(options...)
+	if err != nil {
+		logx.Panicf("[db] %s", err.Error())
+	}
+
+	return c
+}
+
+// NewNamedClient builds a new client of the named cluster,
+// its dbs are under mysql_clusters.<name> in the same form as dbs.
+// The default name is the same as NewClient.
+func NewNamedClient(name string, options ...Option) *Client {
+	c, err := newNamedClient(name, options...)
+	if err != nil {
+		logx.Panicf("[db] %s", err.Error())
+	}
+
+	return c
+}
+
+func newNamedClient(name string, options ...Option) (*Client, error) {
+	if name == "" || name == container.DefaultName {
+		return newDefaultClient(options...)
+	}
+
+	return newClient("mysql_clusters."+name, options...)
+}
 
-		for _, option := range options {
-			option(onceClient)
+func newDefaultClient(options ...Option) (*Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	// 构建失败时不缓存, 下次调用重试
+	if onceClient == nil {
+		c, err := newClient("dbs", options...)
+		if err != nil {
+			return nil, err
 		}
+		onceClient = c
+	}
+
+	return onceClient, nil
+}
+
+func newClient(dbsKey string, options ...Option) (*Client, error) {
+	c := &Client{
+		dbsKey:      dbsKey,
+		gdbs:        make(map[string]*gorm.DB),
+		sqlDbs:      make(map[string]*sql.DB),
+		stateTicker: 10 * time.Second,
+		closeChan:   make(chan bool, 1),
+	}
 
-		onceClient.init()
-	})
+	for _, option := range options {
+		option(c)
+	}
 
-	return onceClient
+	if err := c.init(); err != nil {
+		// 关闭已打开的 db
+		c.Close()
+		return nil, err
+	}
+
+	go c.Stats()
+
+	return c, nil
 }
 
 func WithDbConfig(dbConfigs []DbConfig) Option {
@@ -84,11 +138,11 @@ func WithDB(db *sql.DB) Option {
 }
 
 // initializes Client.
-func (c *Client) init() {
+func (c *Client) init() error {
 	dbConfigs := make([]DbConfig, 0)
-	err := config.UnmarshalKey("dbs", &dbConfigs)
+	err := config.UnmarshalKey(c.dbsKey, &dbConfigs)
 	if err != nil {
-		logx.Panicf("Fatal error config file: %s \n", err.Error())
+		return fmt.Errorf("unmarshal %s: %s", c.dbsKey, err.Error())
 	}
 
 	if len(c.dbConfigs) > 0 {
@@ -104,11 +158,12 @@ func (c *Client) init() {
 			DB, err = gorm.Open("mysql", dbConfig.Dsn)
 		}
 		if err != nil {
-			logx.Panicf("[db] %s init error : %s", dbConfig.Db, err.Error())
+			return fmt.Errorf("%s init error : %s", dbConfig.Db, err.Error())
 		}
 
 		if err = DB.DB().Ping(); err != nil {
-			logx.Panicf("[db] %s ping error : %s", dbConfig.Db, err.Error())
+			DB.Close()
+			return fmt.Errorf("%s ping error : %s", dbConfig.Db, err.Error())
 		}
 
 		DB.DB().SetMaxIdleConns(dbConfig.MaxIdle)
@@ -121,9 +176,10 @@ func (c *Client) init() {
 			DB.LogMode(true)
 		}
 
-		go c.Stats()
 		logx.Infof("[mysql] %s init success", dbConfig.Db)
 	}
+
+	return nil
 }
 
 func (c *Client) setDb(dbName string, gdb *gorm.DB, db *sql.DB) {
@@ -176,6 +232,8 @@ func (c *Client) Ping() []error {
 	return errs
 }
 
+// Close closes all the dbs, NewClient builds a new client after
+// the default client is closed.
 func (c *Client) Close() {
 	var err error
 	for _, db := range c.gdbs {
@@ -184,6 +242,12 @@ func (c *Client) Close() {
 			logx.Errorf(err.Error())
 		}
 	}
+
+	clientMu.Lock()
+	if onceClient == c {
+		onceClient = nil
+	}
+	clientMu.Unlock()
 }
 
 func (c *Client) Stats() {
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"os"
-	"sync"
 	"testing"
 	"time"
 
@@ -139,7 +138,7 @@ func TestInitAndSingleInstance(t *testing.T) {
 }
 
 func TestProxyPatternWithTwoInstance(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	_ = config.NewMemConfig()
@@ -170,7 +169,7 @@ func TestProxyPatternWithTwoInstance(t *testing.T) {
 }
 
 func TestMulProxyPatternWithOneInstance(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	_ = config.NewMemConfig()
@@ -192,7 +191,7 @@ func TestMulProxyPatternWithOneInstance(t *testing.T) {
 }
 
 func TestMulProxyPatternWithTwoInstance(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	_ = config.NewMemConfig()
@@ -225,7 +224,7 @@ func TestMulProxyPatternWithTwoInstance(t *testing.T) {
 }
 
 func BenchmarkParallelGetDB(b *testing.B) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -252,7 +251,7 @@ func BenchmarkParallelGetDB(b *testing.B) {
 }
 
 func TestDummyProxy_Exec(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	_ = config.NewMemConfig()
@@ -274,7 +273,7 @@ func TestDummyProxy_Exec(t *testing.T) {
 }
 
 func TestClient_GetStats(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 
@@ -304,7 +303,7 @@ func TestClient_GetStats(t *testing.T) {
 
 //nolint:dupl
 func TestClient_TxCommit(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	client := NewClient(
@@ -333,7 +332,7 @@ func TestClient_TxCommit(t *testing.T) {
 
 //nolint:dupl
 func TestClient_TxRollBack(t *testing.T) {
-	clientOnce = sync.Once{}
+	onceClient = nil
 
 	clientOptions := ClientOptions{}
 	client := NewClient(
@@ -0,0 +1,56 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/Hyingerrr/mirco-esim/pkg/security/pkcs12"
+
+	"github.com/pkg/errors"
+)
+
+// LoadPKCS12KeyPair 从PFX证书加载 tls.Certificate, 包含证书链
+func LoadPKCS12KeyPair(pfxFile, password string) (tls.Certificate, error) {
+	pfxBuf, err := ioutil.ReadFile(pfxFile)
+	if err != nil {
+		return tls.Certificate{}, errors.WithMessagef(err, "读取证书文件失败[%s]", pfxFile)
+	}
+
+	return PKCS12KeyPair(pfxBuf, password)
+}
+
+func PKCS12KeyPair(pfxData []byte, password string) (tls.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(pfxData, password)
+	if err != nil {
+		return tls.Certificate{}, errors.Errorf("PFX证书加载失败[%s]", err)
+	}
+
+	var pemData []byte
+	for _, b := range blocks {
+		pemData = append(pemData, pem.EncodeToMemory(b)...)
+	}
+
+	cert, err := tls.X509KeyPair(pemData, pemData)
+	if err != nil {
+		return tls.Certificate{}, errors.Errorf("PFX证书转换失败[%s]", err)
+	}
+
+	return cert, nil
+}
+
+// LoadCertPool 加载PEM格式的CA证书, 文件中可以有多个证书
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	caBuf, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "读取CA证书失败[%s]", caFile)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBuf) {
+		return nil, errors.Errorf("CA证书中没有合法的PEM证书[%s]", caFile)
+	}
+
+	return pool, nil
+}
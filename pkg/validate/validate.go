@@ -11,13 +11,14 @@ import (
 	"github.com/pkg/errors"
 )
 
-//验证服务接口
+// 验证服务接口
 type ValidateRepo interface {
 	SetTagName(name string)
 	ValidateStruct(i interface{}) error
+	ValidateStructAll(i interface{}) []error
 }
 
-//验证实例
+// 验证实例
 type Validate struct {
 	validate *validator.Validate
 	trans    ut.Translator
@@ -37,7 +38,7 @@ func NewValidateRepo() ValidateRepo {
 	return v
 }
 
-//设置校验返回信息
+// 设置校验返回信息
 func (v *Validate) SetTagName(name string) {
 	v.validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		tagName := strings.SplitN(fld.Tag.Get(name), ",", 2)[0]
@@ -60,3 +61,27 @@ func (v *Validate) ValidateStruct(i interface{}) error {
 	}
 	return nil
 }
+
+// 校验所有字段, 返回每个不合法字段的错误
+func (v *Validate) ValidateStructAll(i interface{}) []error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*validator.InvalidValidationError); ok {
+		return []error{errors.Wrapf(err, "结构体规则配置校验失败:[%s]", err)}
+	}
+
+	fieldErrs := err.(validator.ValidationErrors)
+	errs := make([]error, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		// 去掉顶层结构体名
+		ns := fieldErr.Namespace()
+		if in := strings.Index(ns, "."); in >= 0 {
+			ns = ns[in+1:]
+		}
+		errs = append(errs, errors.New(ns+": "+fieldErr.Translate(v.trans)))
+	}
+	return errs
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Hyingerrr/mirco-esim/config"
@@ -14,13 +15,16 @@ import (
 )
 
 var (
-	poolOnce   sync.Once
+	clientMu   sync.Mutex
 	onceClient *Client
 )
 
 type Client struct {
 	client *redis.Pool
 
+	// 具名集群的配置前缀
+	keyPrefix string
+
 	proxyNum int
 
 	proxyInses []interface{}
@@ -29,108 +33,110 @@ type Client struct {
 
 	closeChan chan bool
 
-	redisMaxActive int
-
-	redisMaxIdle int
-
-	redisIdleTimeout int
-
-	redisHost string
-
-	redisPort string
+	// 1 表示已关闭; 不用 sync.Once, Client 可能被值拷贝
+	closed int32
 
-	redisPassword string
-
-	redisReadTimeOut int64
-
-	redisWriteTimeOut int64
+	conf Config
+}
 
-	redisConnTimeOut int64
+// Config is bound from the keys below, under redis_clusters.<name> for the named clusters.
+type Config struct {
+	Host     string `mapstructure:"redis_host" default:"0.0.0.0"`
+	Port     string `mapstructure:"redis_port" default:"6379"`
+	Password string `mapstructure:"redis_password"`
+	DBIndex  int    `mapstructure:"redis_db_index" validate:"gte=0"` // 默认0
 
-	dbIndex int // 默认0
+	MaxActive   int `mapstructure:"redis_max_active" default:"500" validate:"gte=1"`
+	MaxIdle     int `mapstructure:"redis_max_idle" default:"100" validate:"gte=0"`
+	IdleTimeout int `mapstructure:"redis_idle_time_out" default:"600"` // s
 
-	isTracer bool
+	// ms
+	ReadTimeout  int64 `mapstructure:"redis_read_time_out" default:"300"`
+	WriteTimeout int64 `mapstructure:"redis_write_time_out" default:"300"`
+	ConnTimeout  int64 `mapstructure:"redis_conn_time_out" default:"300"`
 
-	isMetric bool
+	Tracer  bool `mapstructure:"redis_tracer"`
+	Metrics bool `mapstructure:"redis_metrics"`
 }
 
 type Option func(c *Client)
 
 func NewClient(options ...Option) *Client {
-	poolOnce.Do(func() {
-		onceClient = &Client{
-			stateTicker: 10 * time.Second,
-			closeChan:   make(chan bool, 1),
-		}
-
-		for _, option := range options {
-			option(onceClient)
-		}
+	c, err := newDefaultClient(options...)
+	if err != nil {
+		logx.Panicf("[redis] %s", err.Error())
+	}
 
-		onceClient.redisMaxActive = config.GetInt("redis_max_active")
-		if onceClient.redisMaxActive == 0 {
-			onceClient.redisMaxActive = 500
-		}
+	return c
+}
 
-		onceClient.redisMaxIdle = config.GetInt("redis_max_idle")
-		if onceClient.redisMaxIdle == 0 {
-			onceClient.redisMaxIdle = 100
-		}
+// NewNamedClient builds a new client of the named cluster,
+// its keys are under redis_clusters.<name>, e.g. redis_clusters.cache.redis_host.
+// The default name is the same as NewClient.
+func NewNamedClient(name string, options ...Option) *Client {
+	c, err := newNamedClient(name, options...)
+	if err != nil {
+		logx.Panicf("[redis] %s", err.Error())
+	}
 
-		onceClient.redisIdleTimeout = config.GetInt("redis_idle_time_out")
-		if onceClient.redisIdleTimeout == 0 {
-			onceClient.redisIdleTimeout = 600
-		}
+	return c
+}
 
-		onceClient.redisHost = config.GetString("redis_host")
-		if onceClient.redisHost == "" {
-			onceClient.redisHost = "0.0.0.0"
-		}
+func newNamedClient(name string, options ...Option) (*Client, error) {
+	if name == "" || name == container.DefaultName {
+		return newDefaultClient(options...)
+	}
 
-		onceClient.redisPort = config.GetString("redis_port")
-		if onceClient.redisPort == "" {
-			onceClient.redisPort = "6379"
-		}
+	return newClient("redis_clusters."+name, options...)
+}
 
-		onceClient.redisPassword = config.GetString("redis_password")
-		onceClient.dbIndex = config.GetInt("redis_db_index")
+func newDefaultClient(options ...Option) (*Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
 
-		onceClient.redisReadTimeOut = config.GetInt64("redis_read_time_out")
-		if onceClient.redisReadTimeOut == 0 {
-			onceClient.redisReadTimeOut = 300
+	// 构建失败时不缓存, 下次调用重试
+	if onceClient == nil {
+		c, err := newClient("", options...)
+		if err != nil {
+			return nil, err
 		}
+		onceClient = c
+	}
 
-		onceClient.redisWriteTimeOut = config.GetInt64("redis_write_time_out")
-		if onceClient.redisWriteTimeOut == 0 {
-			onceClient.redisWriteTimeOut = 300
-		}
+	return onceClient, nil
+}
 
-		onceClient.redisConnTimeOut = config.GetInt64("redis_conn_time_out")
-		if onceClient.redisConnTimeOut == 0 {
-			onceClient.redisConnTimeOut = 300
-		}
+func newClient(keyPrefix string, options ...Option) (*Client, error) {
+	c := &Client{
+		keyPrefix:   keyPrefix,
+		stateTicker: 10 * time.Second,
+		closeChan:   make(chan bool, 1),
+	}
 
-		onceClient.isTracer = config.GetBool("redis_tracer")
-		onceClient.isMetric = config.GetBool("redis_metrics")
+	for _, option := range options {
+		option(c)
+	}
 
-		onceClient.initPool()
+	if err := config.Bind(c.keyPrefix, &c.conf); err != nil {
+		return nil, err
+	}
 
-		if config.GetString("runmode") == "pro" {
-			// conn success ？
-			rc := onceClient.client.Get()
-			if rc.Err() != nil {
-				logx.Panicf(rc.Err().Error())
-			}
-			rc.Close()
+	c.initPool()
+
+	if config.GetString("runmode") == "pro" {
+		// conn success ？
+		if err := c.Ping(); err != nil {
+			c.client.Close()
+			return nil, err
 		}
+	}
 
-		go onceClient.Stats()
+	go c.Stats()
 
-		logx.Infof("[redis] init success %s : %s",
-			onceClient.redisHost, onceClient.redisPort)
-	})
+	logx.Infof("[redis] init success %s : %s",
+		c.conf.Host, c.conf.Port)
 
-	return onceClient
+	return c, nil
 }
 
 func WithStateTicker(stateTicker time.Duration) Option {
@@ -142,32 +148,33 @@ func WithStateTicker(stateTicker time.Duration) Option {
 // initClient Initialize the pool of connections.
 func (c *Client) initPool() {
 	c.client = &redis.Pool{
-		MaxIdle:     c.redisMaxIdle,
-		MaxActive:   c.redisMaxActive,
-		IdleTimeout: time.Duration(c.redisIdleTimeout) * time.Second,
+		MaxIdle:     c.conf.MaxIdle,
+		MaxActive:   c.conf.MaxActive,
+		IdleTimeout: time.Duration(c.conf.IdleTimeout) * time.Second,
 		Dial: func() (redis.Conn, error) {
-			conn, err := redis.Dial("tcp", c.redisHost+":"+c.redisPort,
-				redis.DialReadTimeout(time.Duration(c.redisReadTimeOut)*time.Millisecond),
-				redis.DialWriteTimeout(time.Duration(c.redisWriteTimeOut)*time.Millisecond),
-				redis.DialConnectTimeout(time.Duration(c.redisConnTimeOut)*time.Millisecond))
+			conn, err := redis.Dial("tcp", c.conf.Host+":"+c.conf.Port,
+				redis.DialReadTimeout(time.Duration(c.conf.ReadTimeout)*time.Millisecond),
+				redis.DialWriteTimeout(time.Duration(c.conf.WriteTimeout)*time.Millisecond),
+				redis.DialConnectTimeout(time.Duration(c.conf.ConnTimeout)*time.Millisecond))
 
 			if err != nil {
-				logx.Panicf("redis.Dial err: %s", err.Error())
+				logx.Errorf("redis.Dial err: %s", err.Error())
 				return nil, err
 			}
 
-			if c.redisPassword != "" {
-				if _, err = conn.Do("AUTH", c.redisPassword); err != nil {
-					err = conn.Close()
-					logx.Panicf("redis.AUTH err: %s", err)
+			if c.conf.Password != "" {
+				if _, err = conn.Do("AUTH", c.conf.Password); err != nil {
+					conn.Close()
+					logx.Errorf("redis.AUTH err: %s", err.Error())
 					return nil, err
 				}
 			}
 
 			// select db
-			_, err = conn.Do("SELECT", c.dbIndex)
+			_, err = conn.Do("SELECT", c.conf.DBIndex)
 			if err != nil {
-				logx.Panicf("Select err: %s", err.Error())
+				conn.Close()
+				logx.Errorf("Select err: %s", err.Error())
 				return nil, err
 			}
 
@@ -185,17 +192,33 @@ func (c *Client) GetRedisConn() redis.Conn {
 	return c.client.Get()
 }
 
+// Close closes the pool once, NewClient builds a new client after
+// the default client is closed.
 func (c *Client) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	clientMu.Lock()
+	if onceClient == c {
+		onceClient = nil
+	}
+	clientMu.Unlock()
+
 	err := c.client.Close()
 	c.closeChan <- true
 
 	return err
 }
 
+// Ping sends PING by a conn of the pool, the dial errors are returned too.
 func (c *Client) Ping() error {
 	conn := c.client.Get()
+	defer conn.Close()
 
-	return conn.Err()
+	_, err := conn.Do("PING")
+
+	return err
 }
 
 func (c *Client) Stats() {
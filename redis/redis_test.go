@@ -52,7 +52,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestGetProxyConn(t *testing.T) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	redisClent := NewClient(
 		redisClientOptions.WithProxy(
@@ -76,7 +76,7 @@ func TestGetProxyConn(t *testing.T) {
 }
 
 func TestGetNotProxyConn(t *testing.T) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	memConfig := config.NewMemConfig()
 	memConfig.Set("debug", true)
@@ -105,7 +105,7 @@ func TestGetNotProxyConn(t *testing.T) {
 }
 
 func TestMonitorProxy_Do(t *testing.T) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	memConfig := config.NewMemConfig()
 	memConfig.Set("debug", true)
@@ -136,7 +136,7 @@ func TestMonitorProxy_Do(t *testing.T) {
 }
 
 func TestMulLevelProxy_Do(t *testing.T) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	memConfig := config.NewMemConfig()
 	memConfig.Set("debug", true)
@@ -173,7 +173,7 @@ func TestMulLevelProxy_Do(t *testing.T) {
 }
 
 func Benchmark_MulGo_Do(b *testing.B) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	memConfig := config.NewMemConfig()
 	memConfig.Set("debug", true)
@@ -217,7 +217,7 @@ func Benchmark_MulGo_Do(b *testing.B) {
 }
 
 func TestRedisClient_Stats(t *testing.T) {
-	poolOnce = sync.Once{}
+	onceClient = nil
 	redisClientOptions := ClientOptions{}
 	memConfig := config.NewMemConfig()
 	memConfig.Set("debug", true)
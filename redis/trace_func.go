@@ -27,8 +27,8 @@ func (c *Client) Do(ctx context.Context, command string, args ...interface{}) (r
 	redisConn := c.GetRedisConn()
 	defer redisConn.Close()
 
-	if !c.isTracer {
-		if c.isMetric {
+	if !c.conf.Tracer {
+		if c.conf.Metrics {
 			reply, err = c.DoWithMetric(redisConn, command, args...)
 		} else {
 			reply, err = redisConn.Do(command, args...)
@@ -46,12 +46,12 @@ func (c *Client) Do(ctx context.Context, command string, args ...interface{}) (r
 	defer span.Finish()
 
 	ext.DBType.Set(span, "redis")
-	ext.DBInstance.Set(span, strconv.Itoa(tc.dbIndex))
+	ext.DBInstance.Set(span, strconv.Itoa(tc.conf.DBIndex))
 	ext.PeerService.Set(span, "redis")
-	ext.PeerHostname.Set(span, tc.redisHost)
+	ext.PeerHostname.Set(span, tc.conf.Host)
 	ext.SpanKindRPCClient.Set(span)
 
-	if c.isMetric {
+	if c.conf.Metrics {
 		reply, err = c.DoWithMetric(redisConn, command, args...)
 	} else {
 		reply, err = redisConn.Do(command, args...)
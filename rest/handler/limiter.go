@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Hyingerrr/mirco-esim/core/limiter"
+	"github.com/Hyingerrr/mirco-esim/core/meta"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnmatchedRoute is the route of the requests matching no route, e.g. 404.
+const UnmatchedRoute = "unmatched"
+
+// Limiter rejects the request with 429 by the rules of m, the rules match the route,
+// e.g. /users/:id, or UnmatchedRoute if no route matches.
+// Use it after MetadataHandler to limit by appid or merid.
+func Limiter(m *limiter.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		// 按路由模板限流, 避免每个 path 一个限流器和一个指标
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+
+		done, rule, ok := m.Allow(limiter.Request{
+			Method: route,
+			AppID:  meta.String(ctx, meta.AppID),
+			MerID:  meta.String(ctx, meta.MerID),
+		})
+		if !ok {
+			logx.Warnc(ctx, "rate limited by %s: %s", rule, route)
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		defer done()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Hyingerrr/mirco-esim/core/limiter"
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestLimiter_Unmatched(t *testing.T) {
+	m := limiter.NewManager(limiter.ManagerOptions{}.WithRules(
+		limiter.Rule{Match: UnmatchedRoute, Type: limiter.TypeSlidingWindow, Limit: 1},
+		limiter.Rule{Match: "/users/:id", Type: limiter.TypeSlidingWindow, Limit: 1},
+	))
+
+	router := gin.New()
+	router.Use(Limiter(m))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// 不同 id 按同一个路由限流
+	assert.Equal(t, http.StatusOK, serve("/users/1"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/users/2"))
+
+	// 不同的 404 路径共用一个限流器
+	assert.Equal(t, http.StatusNotFound, serve("/scan/a"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/scan/b"))
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/container"
 	"github.com/Hyingerrr/mirco-esim/log"
 
 	mq_http_sdk "github.com/aliyunmq/mq-http-go-sdk"
@@ -30,51 +31,68 @@ type MQClient struct {
 
 func NewMQClient(options ...Option) *MQClient {
 	poolOnce.Do(func() {
-		onceClient = &MQClient{}
-		for _, option := range options {
-			option(onceClient)
-		}
-
-		if onceClient.conf == nil {
-			onceClient.conf = config.NewNullConfig()
-		}
-
-		if onceClient.logger == nil {
-			onceClient.logger = log.NewLogger()
-		}
-
-		onceClient.endpoint = onceClient.conf.GetString("aliyun_endpoint")
-		if onceClient.endpoint == "" {
-			onceClient.logger.Panicf("aliyun_endpoint is null! please confirm")
-		}
-
-		onceClient.accessKey = onceClient.conf.GetString("aliyun_access_key")
-		if onceClient.accessKey == "" {
-			onceClient.logger.Panicf("aliyun_access_key is null! please confirm")
-		}
-
-		onceClient.secretKey = onceClient.conf.GetString("aliyun_secrect_key")
-		if onceClient.secretKey == "" {
-			onceClient.logger.Panicf("aliyun_secrect_key is null! please confirm")
-		}
-
-		onceClient.instanceId = onceClient.conf.GetString("aliyun_instance_id")
-		if onceClient.instanceId == "" {
-			onceClient.logger.Panicf("aliyun_instance_id is null! please confirm")
-		}
-
-		onceClient.mqClient = mq_http_sdk.NewAliyunMQClient(onceClient.endpoint,
-			onceClient.accessKey,
-			onceClient.secretKey,
-			"")
-
-		onceClient.logger.Infof("[rocket mq] init success %s",
-			onceClient.endpoint)
+		onceClient = newMQClient("", options...)
 	})
 
 	return onceClient
 }
 
+// NewNamedMQClient builds a new client of the named instance,
+// its keys are under rocketmq_clusters.<name>, e.g. rocketmq_clusters.order.aliyun_endpoint.
+// The default name is the same as NewMQClient.
+func NewNamedMQClient(name string, options ...Option) *MQClient {
+	if name == "" || name == container.DefaultName {
+		return NewMQClient(options...)
+	}
+
+	return newMQClient("rocketmq_clusters."+name+".", options...)
+}
+
+func newMQClient(keyPrefix string, options ...Option) *MQClient {
+	mc := &MQClient{}
+	for _, option := range options {
+		option(mc)
+	}
+
+	if mc.conf == nil {
+		mc.conf = config.NewNullConfig()
+	}
+
+	if mc.logger == nil {
+		mc.logger = log.NewLogger()
+	}
+
+	mc.endpoint = mc.conf.GetString(keyPrefix + "aliyun_endpoint")
+	if mc.endpoint == "" {
+		mc.logger.Panicf("aliyun_endpoint is null! please confirm")
+	}
+
+	mc.accessKey = mc.conf.GetString(keyPrefix + "aliyun_access_key")
+	if mc.accessKey == "" {
+		mc.logger.Panicf("aliyun_access_key is null! please confirm")
+	}
+
+	mc.secretKey = mc.conf.GetString(keyPrefix + "aliyun_secrect_key")
+	if mc.secretKey == "" {
+		mc.logger.Panicf("aliyun_secrect_key is null! please confirm")
+	}
+
+	mc.instanceId = mc.conf.GetString(keyPrefix + "aliyun_instance_id")
+	if mc.instanceId == "" {
+		mc.logger.Panicf("aliyun_instance_id is null! please confirm")
+	}
+
+	mc.mqClient = mq_http_sdk.NewAliyunMQClient(mc.endpoint,
+		mc.accessKey,
+		mc.secretKey,
+		"")
+
+	mc.logger.Infof("[rocket mq] init success %s",
+		mc.endpoint)
+
+	return mc
+}
+
 func (mc *MQClient) Consumer(topicName string, groupID string, messageTag string) mq_http_sdk.MQConsumer {
 	return mc.mqClient.GetConsumer(
 		mc.instanceId, topicName, groupID, messageTag)
@@ -0,0 +1,33 @@
+package rocketmq
+
+import (
+	"github.com/Hyingerrr/mirco-esim/config"
+	"github.com/Hyingerrr/mirco-esim/container"
+	"github.com/Hyingerrr/mirco-esim/log"
+
+	"github.com/google/wire"
+)
+
+// Kind of the rocketmq clients in the container registry.
+const Kind = "rocketmq"
+
+// Register sets the factory of the rocketmq clients in r, e.g. container.Instances(),
+// call it before Named.
+func Register(r *container.Registry) {
+	r.Provide(Kind, func(name string) (interface{}, error) {
+		opts := MQClientOptions{}
+		return NewNamedMQClient(name, opts.WithConf(config.Default()), opts.WithLogger(log.Default())), nil
+	})
+}
+
+// ProviderSet injects the default client.
+var ProviderSet = wire.NewSet(ProvideMQClient)
+
+func ProvideMQClient() *MQClient {
+	return Named(container.DefaultName)
+}
+
+// Named returns the cached client of the named instance.
+func Named(name string) *MQClient {
+	return container.MustGet(Kind, name).(*MQClient)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Hyingerrr/mirco-esim/config"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "config tools",
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt [plaintext]",
+	Short: "encrypt a config value to ENC(...)",
+	Long: `加密配置项，输出 ENC(...) 直接写入配置文件，运行时自动解密。
+密钥优先级：--key > --key_file > ESIM_CONFIG_KEY > ESIM_CONFIG_KEY_FILE
+不传 plaintext 时从标准输入读取，避免明文留在 shell 历史中。
+
+  esim config encrypt --key_file ./config.key 'root:123456@tcp(127.0.0.1:3306)/esim'
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := encryptKey(cmd)
+		if err != nil {
+			return err
+		}
+
+		var plaintext string
+		if len(args) > 0 {
+			plaintext = args[0]
+		} else {
+			plaintext, err = bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && plaintext == "" {
+				return err
+			}
+			plaintext = strings.TrimRight(plaintext, "\r\n")
+		}
+
+		ciphertext, err := config.Encrypt(plaintext, key)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(ciphertext)
+		return nil
+	},
+}
+
+func encryptKey(cmd *cobra.Command) ([]byte, error) {
+	if key, _ := cmd.Flags().GetString("key"); key != "" {
+		return config.ParseSecretKey(key)
+	}
+
+	if keyFile, _ := cmd.Flags().GetString("key_file"); keyFile != "" {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return config.ParseSecretKey(strings.TrimSpace(string(content)))
+	}
+
+	return config.LoadSecretKey()
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configEncryptCmd)
+
+	configEncryptCmd.Flags().StringP("key", "k", "", "AES 密钥, 16/24/32 字节或 base64")
+
+	configEncryptCmd.Flags().StringP("key_file", "", "", "AES 密钥文件")
+}
@@ -69,6 +69,34 @@ redis_conn_time_out : 500
 #prometheus http addr
 prometheus_http_addr : 9002
 
+# 启动探测
+boot_timeout: 30s  # 所有探测的总时限
+boot_retries: 3  # 失败后的重试次数
+boot_retry_interval: 500ms  # 首次重试间隔，之后翻倍
+boot_degraded: false  # 关键组件探测失败时是否继续启动
+
+# 熔断, grpc_client_breaker/http_client_breaker 开启
+breaker_window: 10s  # 滚动窗口
+breaker_buckets: 10
+breaker_min_requests: 20  # 窗口内请求数不足时不熔断
+breaker_error_rate: 0.5
+breaker_slow_call: 0s  # 超过为慢请求，0 不检查
+breaker_slow_call_rate: 0.5
+breaker_open_timeout: 5s  # 熔断多久后半开
+breaker_half_open_requests: 5  # 半开时的探测请求数
+
+# 限流, grpc_server_limiter 或 handler.Limiter 开启, 修改后自动生效
+# key: method|appid|merid, type: token_bucket|sliding_window|adaptive
+#limiter_rules:
+#  - match: /order.Order/*  # 前缀匹配, * 匹配所有
+#    key: merid
+#    type: token_bucket
+#    rate: 100  # 每秒
+#    burst: 200
+#  - match: "*"
+#    type: adaptive  # 按耗时自动调整并发数
+#    max_concurrency: 500
+
 # logger
 log_output: stdout  # 日志位置，file 文件|both 文件和终端|stdout 终端
 log_file: ./logs/{{.ServerName}}.log  # 文件地址，建议写绝对路径
@@ -80,7 +108,19 @@ log_max_size: 100   # 单个文件最大size
 log_max_age: 15   # 保留旧文件的最大天数
 log_backup_count: 10  # 保留旧文件的最大个数
 log_compress: true  # 是否压缩/归档旧文件
+log_rotate: size  # 切割方式 size 按大小|daily 按天|hourly 按小时
+log_error_file: ./logs/{{.ServerName}}.error.log  # error日志单独输出
+#log_sinks:  # 额外的输出 stdout|file|syslog|tcp|udp
+#  - name: collector
+#    type: tcp
+#    addr: 127.0.0.1:5140
+#    level: info
 log_err_stats: true # 是否统计error日志
+log_sample_initial: 0  # 采样: 每秒同一模板先输出的条数，0 不采样
+log_sample_thereafter: 100  # 采样: 之后每多少条输出一条
+log_mask_fields: card_no,id_card,mobile,password  # 脱敏字段名
+log_async: false  # 是否异步写日志
+log_async_policy: block  # 异步缓冲区满时 block 阻塞|drop_oldest 丢弃最旧|drop_newest 丢弃最新
 
 # 微信报警 配置样例
 wx_web_hook: e0c3df32-547b-4699-a887-67c5ae8ea877  # wx群ID
@@ -106,8 +146,28 @@ grpc_server_metrics : {{.Monitoring}}
 grpc_server_debug: {{.Monitoring}}
 # 单位ms handle
 grpc_server_timeout: 5000
+# 单位ms 整个流的时限，0 不限制
+grpc_server_stream_timeout: 0
 # 启动字段验证
 grpc_server_validate: true
+# TLS, 证书文件变化后自动重新加载
+grpc_server_tls: false
+#grpc_server_tls_cert_file: ./conf/tls/server.crt
+#grpc_server_tls_key_file: ./conf/tls/server.key
+#grpc_server_tls_pfx_file: ./conf/tls/server.pfx  # pkcs12 证书，优先于 cert/key
+#grpc_server_tls_pfx_password:
+#grpc_server_tls_ca_file: ./conf/tls/ca.crt  # 验证客户端证书
+#grpc_server_tls_client_auth: require  # none|request|verify_if_given|require
+#grpc_server_tls_reload_interval: 60  # 单位s
+# 注册中心, 使用 WithRegistrar 时生效; 地址为空时取本机ip
+#grpc_server_register_host:
+# 单位s 心跳超时, 每 ttl/3 续约一次
+grpc_server_register_ttl: 15
+grpc_server_register_weight: 1
+#grpc_server_register_metadata:
+#  zone: sh
+# 按 limiter_rules 限流, 超限返回 RESOURCE_EXHAUSTED
+grpc_server_limiter: false
 
 #grpc 客户端
 #开启慢检查 true/false
@@ -122,6 +182,40 @@ grpc_client_metrics : {{.Monitoring}}
 grpc_client_debug: {{.Monitoring}}
 # 单位ms handle
 grpc_client_timeout: 5000
+# 单位ms 整个流的时限，0 不限制
+grpc_client_stream_timeout: 0
+# TLS, 配置 cert/key 即为双向认证
+grpc_client_tls: false
+#grpc_client_tls_ca_file: ./conf/tls/ca.crt
+#grpc_client_tls_cert_file: ./conf/tls/client.crt
+#grpc_client_tls_key_file: ./conf/tls/client.key
+#grpc_client_tls_server_name: localhost
+# 负载均衡 pick_first/round_robin/weighted_round_robin/consistent_hash(按 merid)
+grpc_client_balancer: round_robin
+# 单位s dns-refresh 重新解析的间隔
+grpc_client_resolve_interval: 30
+# 按 target 指定负载均衡, target 支持 static/dns-refresh/file/registry
+#grpc_client_targets:
+#  - target: static:///10.0.0.1:50051,10.0.0.2:50051=3
+#    balancer: weighted_round_robin
+#  - target: file:///conf/order.endpoints
+#    balancer: consistent_hash
+# 按 target+方法熔断
+grpc_client_breaker: false
+# 按方法重试, method 支持 /pkg.Service/Method, /pkg.Service/* 和 *
+# codes 默认 UNAVAILABLE,RESOURCE_EXHAUSTED; 时间单位ms
+#grpc_client_retries:
+#  - method: /order.Order/*
+#    max_attempts: 3
+#    codes: [UNAVAILABLE, RESOURCE_EXHAUSTED]
+#    initial_backoff: 50
+#    max_backoff: 1000
+#    multiplier: 2
+#    jitter: 0.2
+#    per_try_timeout: 300
+#  - method: /order.Order/Query
+#    hedging: true  # 只用于幂等的读
+#    hedging_delay: 100
 
 #mysql
 #开启慢检查 true/false
@@ -149,6 +243,8 @@ mgo_metrics : {{.Monitoring}}
 http_client_tracer : {{.Monitoring}}
 #启动metric bool
 http_client_metrics : {{.Monitoring}}
+#按 host+path 熔断 bool
+http_client_breaker: false
 
 # http server
 #开启 tracer bool
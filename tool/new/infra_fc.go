@@ -53,6 +53,7 @@ var infraSet = wire.NewSet(
 func NewInfra() *Infra {
 	infraOnce.Do(func() {
 		esim  := container.NewEsim()
+		mysql.Register(container.Instances())
 		onceInfra = initInfra(esim, provideGrpcClient())
 	})
 
@@ -62,15 +63,19 @@ func NewInfra() *Infra {
 func NewStubsInfra(grpcClient *grpc.Client) *Infra {
 	infraOnce.Do(func() {
 		esim  := container.NewEsim()
+		mysql.Register(container.Instances())
 		onceInfra = initInfra(esim, grpcClient)
 	})
 
 	return onceInfra
 }
 
-// Close close the infra when app stop
+// Close close the infra when app stop,
+// DB is from container.Instances and closed by App.
 func (infraer *Infra) Close()  {
-	infraer.DB.Close()
+	if infraer.GrpcClient != nil {
+		infraer.GrpcClient.Close()
+	}
 }
 
 func (infraer *Infra) HealthCheck() []error {
@@ -86,7 +91,7 @@ func (infraer *Infra) HealthCheck() []error {
 
 
 func provideDb() *mysql.Client {
-	return mysqlClent := mysql.NewClient()
+	return mysql.ProvideClient()
 }
 
 
@@ -7,10 +7,6 @@ var (
 		Content: `package {{.PackageName}}
 
 import (
-	"os"
-	"os/signal"
-	"syscall"
-	"github.com/Hyingerrr/mirco-esim/transports"
 	"{{.ProPath}}{{.ServerName}}/internal/infra"
 	"github.com/Hyingerrr/mirco-esim/container"
 	"github.com/Hyingerrr/mirco-esim/core/xenv"
@@ -18,9 +14,7 @@ import (
 )
 
 type App struct{
-	*container.Esim
-
-	trans []transports.Transports
+	*container.App
 
 	Infra *infra.Infra
 
@@ -59,7 +53,7 @@ func NewApp(options ...Option) *App {
 		return conf
 	})
 
-	app.Esim = container.NewEsim()
+	app.App = container.NewApp()
 
 	return app
 }
@@ -70,34 +64,16 @@ func (AppOptions) WithConfPath(confPath string) Option {
 	}
 }
 
-func (app *App) Start()  {
-	for _, tran := range app.trans {
-		tran.Start()
-	}
-}
-
-func (app *App) RegisterTran(tran transports.Transports) {
-	app.trans = append(app.trans, tran)
-}
-
+// AwaitSignal 收到信号后按注册的逆序关闭 transports 和 infra,
+// container.Instances 中的 client 由 App 关闭
 func (app *App) AwaitSignal() {
-	c := make(chan os.Signal, 1)
-	signal.Reset(syscall.SIGTERM, syscall.SIGINT)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
-
-	s := <-c
-	app.Esim.Logger.Infof("receive a signal %s", s.String())
-	app.stop()
-
-	close(c)
-}
-
-func (app *App) stop()  {
-	for _, tran := range app.trans {
-		tran.GracefulShutDown()
+	if app.Infra != nil {
+		app.RegisterCloser(app.Infra)
 	}
 
-	app.Infra.Close()
+	app.App.AwaitSignal()
+	// 阻塞关闭的组件已记录在日志中
+	_ = app.Shutdown()
 }
 `,
 	}
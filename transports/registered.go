@@ -0,0 +1,37 @@
+package transports
+
+import (
+	"github.com/Hyingerrr/mirco-esim/core/registry"
+	logx "github.com/Hyingerrr/mirco-esim/log"
+)
+
+type registered struct {
+	Transports
+
+	keeper *registry.Keeper
+}
+
+// Registered registers the instance after tran starts, and deregisters
+// it before tran shuts down, e.g. for an http server:
+//
+//	ins := registry.Instance{Service: "order-http", Host: ip, Port: 8080}
+//	app.RegisterTran(transports.Registered(ginServer, registry.NewKeeper(registrar, ins, 0)))
+func Registered(tran Transports, keeper *registry.Keeper) Transports {
+	return &registered{Transports: tran, keeper: keeper}
+}
+
+func (r *registered) Start() {
+	r.Transports.Start()
+
+	if err := r.keeper.Start(); err != nil {
+		logx.Errorf("register %s: %s", r.keeper.Instance().ID, err.Error())
+	}
+}
+
+func (r *registered) GracefulShutDown() {
+	if err := r.keeper.Stop(); err != nil {
+		logx.Errorf("deregister %s: %s", r.keeper.Instance().ID, err.Error())
+	}
+
+	r.Transports.GracefulShutDown()
+}